@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorNameSplitting(t *testing.T) {
+
+	fmt.Println("------------------------ TestAuthorNameSplitting ------------------------")
+
+	assert.Equal(t, []string{"Jenny Town"}, parsers.SplitAuthorNames("Jenny Town, Senior Fellow"))
+	assert.Equal(t, []string{"Jenny Town", "Martyn Williams"}, parsers.SplitAuthorNames("Jenny Town and Martyn Williams"))
+	assert.Equal(t, []string{"Jenny Town", "Martyn Williams"}, parsers.SplitAuthorNames("By Dr. Jenny Town & Martyn Williams"))
+	assert.Equal(t, []string{"Jenny Town", "Martyn Williams", "Peter Makowsky"}, parsers.SplitAuthorNames("Jenny Town, Martyn Williams and Peter Makowsky"))
+	assert.Equal(t, []string{"Jenny Town"}, parsers.SplitAuthorNames("Jenny Town and jenny town"))
+	assert.Empty(t, parsers.SplitAuthorNames("  "))
+}
+
+func TestAuthorNameNormalisation(t *testing.T) {
+
+	fmt.Println("------------------------ TestAuthorNameNormalisation ------------------------")
+
+	assert.Equal(t, "jenny town", parsers.NormaliseAuthorName("  Jenny   TOWN "))
+	assert.Equal(t, "j town", parsers.NormaliseAuthorName("Dr. J. Town, Senior Fellow"))
+	assert.Equal(t, "jenny town", parsers.NormaliseAuthorName("Prof Jenny Town PhD"))
+	assert.Equal(t, "jenny town", parsers.NormaliseAuthorName("Jenny Town (38 North)"))
+	assert.Equal(t, "Jenny Town", parsers.CleanAuthorName("Ms. Jenny Town - Director"))
+}
+
+func TestAuthorCandidateMatching(t *testing.T) {
+
+	fmt.Println("------------------------ TestAuthorCandidateMatching ------------------------")
+
+	jennyTown := parsers.RssAuthor{Id: "1", Name: "Jenny Town", Email: "jtown@38north.org", NormalisedName: "jenny town"}
+	martynWilliams := parsers.RssAuthor{Id: "2", Name: "Martyn Williams", NormalisedName: "martyn williams", Aliases: []string{"m williams"}}
+
+	matched, ok := parsers.MatchAuthorCandidate("Dr. Jenny Town", "", []parsers.RssAuthor{jennyTown, martynWilliams})
+	assert.True(t, ok)
+	assert.Equal(t, "1", matched.Id)
+
+	// An email match wins over the name:
+	matched, ok = parsers.MatchAuthorCandidate("JT", "JTown@38north.org", []parsers.RssAuthor{martynWilliams, jennyTown})
+	assert.True(t, ok)
+	assert.Equal(t, "1", matched.Id)
+
+	// Aliases are matched like names:
+	matched, ok = parsers.MatchAuthorCandidate("M. Williams", "", []parsers.RssAuthor{jennyTown, martynWilliams})
+	assert.True(t, ok)
+	assert.Equal(t, "2", matched.Id)
+
+	// The same name with a different email is a different person:
+	_, ok = parsers.MatchAuthorCandidate("Jenny Town", "jenny@example.com", []parsers.RssAuthor{jennyTown})
+	assert.False(t, ok)
+
+	// An initial is matched to the full first name when only one author fits:
+	matched, ok = parsers.MatchAuthorCandidate("J. Town", "", []parsers.RssAuthor{jennyTown, martynWilliams})
+	assert.True(t, ok)
+	assert.Equal(t, "1", matched.Id)
+
+	// But not when it is ambiguous:
+	jamesTown := parsers.RssAuthor{Id: "3", Name: "James Town", NormalisedName: "james town"}
+	_, ok = parsers.MatchAuthorCandidate("J. Town", "", []parsers.RssAuthor{jennyTown, jamesTown})
+	assert.False(t, ok)
+
+	_, ok = parsers.MatchAuthorCandidate("Peter Makowsky", "", []parsers.RssAuthor{jennyTown, martynWilliams})
+	assert.False(t, ok)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharsetDetection(t *testing.T) {
+
+	fmt.Println("------------------------ TestCharsetDetection ------------------------")
+
+	testCases := []struct {
+		Fixture     string
+		ContentType string
+		Charset     string
+		Source      string
+	}{
+		{Fixture: "windows_1252.rss", ContentType: "application/rss+xml", Charset: "windows-1252", Source: parsers.CharsetFromXmlDeclaration},
+		{Fixture: "shift_jis.rss", ContentType: "application/rss+xml", Charset: "shift_jis", Source: parsers.CharsetFromXmlDeclaration},
+		{Fixture: "euc_kr.html", ContentType: "text/html", Charset: "euc-kr", Source: parsers.CharsetFromMeta},
+		{Fixture: "euc_kr.html", ContentType: "text/html; charset=EUC-KR", Charset: "euc-kr", Source: parsers.CharsetFromHeader},
+		{Fixture: "euc_kr_undeclared.html", ContentType: "text/html", Charset: "euc-kr", Source: parsers.CharsetFromSniffing},
+	}
+
+	for _, testCase := range testCases {
+
+		body, err := os.ReadFile("../data/charset/" + testCase.Fixture)
+		assert.NoError(t, err)
+
+		charsetName, source := parsers.DetectCharset(body, testCase.ContentType)
+		assert.Equal(t, testCase.Charset, charsetName, testCase.Fixture)
+		assert.Equal(t, testCase.Source, source, testCase.Fixture)
+	}
+
+	// UTF-8 wins over a declaration that doesn't match the bytes:
+	charsetName, source := parsers.DetectCharset([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><rss><title>Café</title></rss>`), "")
+	assert.Equal(t, "utf-8", charsetName)
+	assert.Equal(t, parsers.CharsetFromSniffing, source)
+
+	charsetName, source = parsers.DetectCharset([]byte("\xef\xbb\xbf<rss></rss>"), "text/xml; charset=windows-1252")
+	assert.Equal(t, "utf-8", charsetName)
+	assert.Equal(t, parsers.CharsetFromBom, source)
+}
+
+func TestCharsetDecoding(t *testing.T) {
+
+	fmt.Println("------------------------ TestCharsetDecoding ------------------------")
+
+	// Feeds are transcoded and their XML declaration rewritten before they are parsed:
+	for fixture, expectedTitle := range map[string]string{
+		"windows_1252.rss": "Café “Quotes” — déjà vu",
+		"shift_jis.rss":    "北朝鮮のミサイル発射について",
+	} {
+		body, err := os.ReadFile("../data/charset/" + fixture)
+		assert.NoError(t, err)
+
+		decoded, _, err := parsers.DecodeToUtf8(body, "application/rss+xml")
+		assert.NoError(t, err)
+		assert.Contains(t, string(decoded), `encoding="UTF-8"`, fixture)
+
+		feed, warnings, err := parsers.ParseFeed(decoded)
+		assert.NoError(t, err, fixture)
+		assert.Empty(t, warnings, fixture)
+		assert.Equal(t, expectedTitle, feed.Title, fixture)
+		assert.Equal(t, expectedTitle, feed.Items[0].Title, fixture)
+	}
+
+	// Pages have their <meta> charset rewritten so that the stored page is read as UTF-8:
+	body, err := os.ReadFile("../data/charset/euc_kr.html")
+	assert.NoError(t, err)
+
+	decoded, charsetName, err := parsers.DecodeToUtf8(body, "text/html")
+	assert.NoError(t, err)
+	assert.Equal(t, "euc-kr", charsetName)
+	assert.Contains(t, string(decoded), `<meta charset="utf-8">`)
+	assert.Contains(t, string(decoded), "<title>북한 미사일 발사에 대한 분석</title>")
+
+	// UTF-8 documents are returned as they are:
+	utf8Page := []byte(`<html><head><meta charset="utf-8"><title>Café</title></head></html>`)
+	decoded, charsetName, err = parsers.DecodeToUtf8(utf8Page, "text/html")
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8", charsetName)
+	assert.Equal(t, utf8Page, decoded)
+}
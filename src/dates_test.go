@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFeedDate(t *testing.T) {
+
+	fmt.Println("------------------------ TestParseFeedDate ------------------------")
+
+	expected := time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC)
+
+	equivalentDates := []string{
+		"Fri, 20 Oct 2023 14:33:10 +0000",
+		"Friday, 20 Oct 2023 14:33:10 GMT",
+		"20 Oct 2023 10:33:10 EDT",
+		"2023-10-20T14:33:10Z",
+		"2023-10-20T23:33:10+09:00",
+		"2023-10-20 14:33:10",
+		"  2023-10-20T14:33:10  ",
+	}
+	for _, date := range equivalentDates {
+		parsedDate, err := parsers.ParseFeedDate(date)
+		assert.Nil(t, err, date)
+		assert.True(t, expected.Equal(parsedDate), date)
+		assert.Equal(t, time.UTC, parsedDate.Location(), date)
+	}
+
+	dayOnly := time.Date(2023, 10, 20, 0, 0, 0, 0, time.UTC)
+	for _, date := range []string{"2023-10-20", "October 20th, 2023", "Oct 20, 2023", "20 October 2023"} {
+		parsedDate, err := parsers.ParseFeedDate(date)
+		assert.Nil(t, err, date)
+		assert.True(t, dayOnly.Equal(parsedDate), date)
+	}
+
+	_, err := parsers.ParseFeedDate("sometime last week")
+	assert.NotNil(t, err)
+	_, err = parsers.ParseFeedDate("")
+	assert.NotNil(t, err)
+}
+
+func TestFeedItemTime(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedItemTime ------------------------")
+
+	korea := time.FixedZone("KST", 9*60*60)
+	parsed := time.Date(2023, 10, 20, 23, 33, 10, 0, korea)
+
+	// Times gofeed parsed are used as they are, converted to UTC:
+	itemTime := parsers.FeedItemTime(&parsed, "ignored")
+	assert.Equal(t, time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC), itemTime)
+
+	// Otherwise the raw string is parsed with the fallback layouts:
+	itemTime = parsers.FeedItemTime(nil, "Oct 20, 2023")
+	assert.Equal(t, time.Date(2023, 10, 20, 0, 0, 0, 0, time.UTC), itemTime)
+
+	assert.True(t, parsers.FeedItemTime(nil, "").IsZero())
+	assert.True(t, parsers.FeedItemTime(nil, "not a date").IsZero())
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGazetteerEntities(t *testing.T) {
+
+	fmt.Println("------------------------ TestGazetteerEntities ------------------------")
+
+	gazetteer, err := parsers.LoadGazetteer("../data/entities/gazetteer.json")
+	assert.Nil(t, err)
+
+	text := "Kim Jong-un met Vladimir Putin at the Vostochny Cosmodrome, KCNA reported. " +
+		"The DPRK leader said the Hwasong-18 and a new Hwasong-19 ICBM would be deployed. " +
+		"Foreign Minister Choe Son Hui and Defense Minister Kang Sun Nam On Tuesday joined Kim Jong Un. " +
+		"Officials from the Ministry of National Defense in Seoul and the US briefed the Korean Central News Agency. " +
+		"Let us know what you think about COVID-19."
+
+	mentions, err := gazetteer.ExtractEntities(text)
+	assert.Nil(t, err)
+	mentionsByName := map[string]parsers.EntityMention{}
+	for _, mention := range mentions {
+		mentionsByName[mention.Name] = mention
+	}
+
+	// Variants of a name are counted as the entity of the gazetteer:
+	assert.Equal(t, parsers.EntityMention{Name: "Kim Jong Un", Type: parsers.EntityPerson, Count: 2, Aliases: []string{"Kim Jong-un"}}, mentionsByName["Kim Jong Un"])
+	assert.Equal(t, parsers.EntityMention{Name: "Korean Central News Agency", Type: parsers.EntityOrganization, Count: 2, Aliases: []string{"KCNA"}}, mentionsByName["Korean Central News Agency"])
+	assert.Equal(t, 1, mentionsByName["North Korea"].Count)
+	assert.Equal(t, parsers.EntityPlace, mentionsByName["Vostochny Cosmodrome"].Type)
+	assert.Equal(t, parsers.EntityWeaponSystem, mentionsByName["Hwasong-18"].Type)
+	assert.Equal(t, parsers.EntityWeaponSystem, mentionsByName["Intercontinental Ballistic Missile"].Type)
+
+	// Titles of people the gazetteer knows don't create a second entity:
+	assert.Equal(t, 1, mentionsByName["Choe Son Hui"].Count)
+
+	// Short acronyms only match when they are written in capitals ("US" but not "us"):
+	assert.Equal(t, 1, mentionsByName["United States"].Count)
+
+	// Entities that aren't in the gazetteer are found by the rules:
+	assert.Equal(t, parsers.EntityMention{Name: "Kang Sun Nam", Type: parsers.EntityPerson, Count: 1, Aliases: []string{}}, mentionsByName["Kang Sun Nam"])
+	assert.Equal(t, parsers.EntityOrganization, mentionsByName["Ministry of National Defense"].Type)
+	assert.Equal(t, parsers.EntityWeaponSystem, mentionsByName["Hwasong-19"].Type)
+	assert.NotContains(t, mentionsByName, "COVID-19")
+
+	// The most mentioned entities come first:
+	assert.Equal(t, 2, mentions[0].Count)
+
+	assert.Equal(t, "kim jong un", parsers.NormaliseEntityName("Kim Jong-un"))
+	assert.Equal(t, "democratic people s republic of korea", parsers.NormaliseEntityName("Democratic People’s Republic of Korea"))
+
+	// The text of an article is its title, description and the body of its page, without the page's chrome:
+	page := parsers.ArticlePage{Body: []byte(`<html><body><nav>KCNA Watch</nav><article><p>Kim met</p><p>Putin.</p></article></body></html>`)}
+	articleText := parsers.ArticleText(parsers.RssEntry{Title: "Summit", Description: "<p>At <b>Vostochny</b></p>", Content: "<p>Ignored</p>"}, &page)
+	assert.Equal(t, "Summit\nAt Vostochny\nKim met Putin.", articleText)
+	articleText = parsers.ArticleText(parsers.RssEntry{Title: "Summit", Content: "<p>From the feed</p>"}, nil)
+	assert.Equal(t, "Summit\n\nFrom the feed", articleText)
+}
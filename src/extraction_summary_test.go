@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractionSummaryCounts(t *testing.T) {
+
+	fmt.Println("------------------------ TestExtractionSummaryCounts ------------------------")
+
+	summary := parsers.RssFeedExtractionSummary{
+		Counts: parsers.RssExtractionCounts{DurationMs: 120},
+		RssEntries: []parsers.RssEntryExtractionSummary{
+			{Status: parsers.StatusCreated, Authors: []parsers.RssAuthorExtractionSummary{
+				{Status: parsers.StatusCreated},
+				{Status: parsers.StatusLinked},
+			}},
+			{Status: parsers.StatusCreated, Authors: []parsers.RssAuthorExtractionSummary{
+				{Status: parsers.StatusLinked},
+			}},
+			{Status: parsers.StatusUpdated},
+			{Status: parsers.StatusSkippedExisting},
+			{Status: parsers.StatusSkippedDuplicate},
+			{Status: parsers.StatusErrorDb},
+		},
+	}
+	summary.CountEntries()
+
+	assert.Equal(t, parsers.RssExtractionCounts{
+		ItemsSeen:      6,
+		ItemsCreated:   2,
+		ItemsUpdated:   1,
+		ItemsSkipped:   2,
+		ItemsFailed:    1,
+		AuthorsCreated: 1,
+		AuthorsLinked:  2,
+		DurationMs:     120,
+	}, summary.Counts)
+
+	assert.True(t, parsers.StatusErrorFetch.IsError())
+	assert.False(t, parsers.StatusNotModified.IsError())
+}
+
+func TestExtractionSummaryErrors(t *testing.T) {
+
+	fmt.Println("------------------------ TestExtractionSummaryErrors ------------------------")
+
+	summary := parsers.RssFeedExtractionSummary{
+		RssEntries: []parsers.RssEntryExtractionSummary{
+			{Title: "First Article", Status: parsers.StatusCreated, Authors: []parsers.RssAuthorExtractionSummary{
+				{Name: "Jenny Town", Status: parsers.StatusErrorDb, Error: "connection reset"},
+			}},
+			{Title: "Second Article", Status: parsers.StatusErrorDb, Error: "transaction rolled back"},
+			{Title: "Third Article", Status: parsers.StatusSkippedExisting},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"First Article (Jenny Town): connection reset",
+		"Second Article: transaction rolled back",
+	}, parsers.SummaryErrors(summary))
+	assert.Equal(t, []string{}, parsers.SummaryErrors(parsers.RssFeedExtractionSummary{}))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedHealthBackoff(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedHealthBackoff ------------------------")
+
+	config := parsers.FeedHealthConfig{BaseBackoff: 15 * time.Minute, MaxBackoff: 2 * time.Hour, DisableAfter: 5}
+	failedAt := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, failedAt.Add(15*time.Minute), parsers.NextAttemptAfterFailure(1, failedAt, config))
+	assert.Equal(t, failedAt.Add(30*time.Minute), parsers.NextAttemptAfterFailure(2, failedAt, config))
+	assert.Equal(t, failedAt.Add(time.Hour), parsers.NextAttemptAfterFailure(3, failedAt, config))
+	assert.Equal(t, failedAt.Add(2*time.Hour), parsers.NextAttemptAfterFailure(4, failedAt, config))
+	assert.Equal(t, failedAt.Add(2*time.Hour), parsers.NextAttemptAfterFailure(40, failedAt, config))
+}
+
+func TestFeedHealthReason(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedHealthReason ------------------------")
+
+	_, unhealthy := parsers.RssFeedHealthReason(parsers.RssFeed{Title: "38 North"})
+	assert.False(t, unhealthy)
+
+	report, unhealthy := parsers.RssFeedHealthReason(parsers.RssFeed{
+		Title:               "38 North",
+		ConsecutiveFailures: 2,
+		LastFailure:         time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		NextAttempt:         time.Date(2023, 10, 1, 12, 30, 0, 0, time.UTC),
+		LastSuccess:         time.Date(2023, 9, 30, 18, 0, 0, 0, time.UTC),
+		LastError:           "request to rss feed returned status code: 404",
+	})
+	assert.True(t, unhealthy)
+	assert.Equal(t, "failing", report.Status)
+	assert.Equal(t,
+		"2 consecutive failures, next attempt at 2023-10-01T12:30:00Z. Last failed at 2023-10-01T12:00:00Z: request to rss feed returned status code: 404. Last succeeded at 2023-09-30T18:00:00Z",
+		report.Reason,
+	)
+
+	report, unhealthy = parsers.RssFeedHealthReason(parsers.RssFeed{Title: "38 North", Disabled: true, ConsecutiveFailures: 10})
+	assert.True(t, unhealthy)
+	assert.Equal(t, "disabled", report.Status)
+	assert.Contains(t, report.Reason, "Has never succeeded")
+}
+
+func TestRssFeedIsDue(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssFeedIsDue ------------------------")
+
+	now := time.Date(2023, 10, 1, 18, 5, 0, 0, time.UTC)
+
+	// Scheduled for 18:00 and last ingested yesterday:
+	assert.True(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "18:00", LastSuccess: now.AddDate(0, 0, -1)}, now))
+	// Already ingested since 18:00 today:
+	assert.False(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "18:00", LastSuccess: now.Add(-time.Minute)}, now))
+	// Not yet 19:00 today, and ingested after 19:00 yesterday:
+	assert.False(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "19:00", LastSuccess: now.Add(-20 * time.Hour)}, now))
+	// Never ingested:
+	assert.True(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "19:00"}, now))
+	// No schedule:
+	assert.False(t, parsers.RssFeedIsDue(parsers.RssFeed{}, now))
+
+	// Backing off after a failure:
+	assert.False(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "18:00", NextAttempt: now.Add(time.Minute)}, now))
+	assert.True(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "18:00", NextAttempt: now.Add(-time.Minute), LastFailure: now}, now))
+
+	// Disabled:
+	assert.False(t, parsers.RssFeedIsDue(parsers.RssFeed{ExecuteTime: "18:00", Disabled: true}, now))
+}
+
+func TestSourceIsDue(t *testing.T) {
+
+	fmt.Println("------------------------ TestSourceIsDue ------------------------")
+
+	now := time.Date(2023, 10, 1, 18, 5, 0, 0, time.UTC)
+	newsletterNode := func(props map[string]any) parsers.SourceNode {
+		props["name"] = "38 North Weekly"
+		return parsers.SourceNodeFromNode(neo4j.Node{ElementId: "4:newsletter:1", Labels: []string{"Newsletter", "Source"}, Props: props})
+	}
+
+	// Sources of every type are scheduled and backed off from the properties of their node:
+	assert.True(t, parsers.SourceIsDue(newsletterNode(map[string]any{"scheduled_time": "18:00"}), now))
+	assert.False(t, parsers.SourceIsDue(newsletterNode(map[string]any{"scheduled_time": "18:00", "last_success": now.Add(-time.Minute)}), now))
+	assert.False(t, parsers.SourceIsDue(newsletterNode(map[string]any{"scheduled_time": "18:00", "disabled": true}), now))
+	assert.False(t, parsers.SourceIsDue(newsletterNode(map[string]any{"scheduled_time": "18:00", "next_attempt": now.Add(time.Minute)}), now))
+	assert.False(t, parsers.SourceIsDue(newsletterNode(map[string]any{}), now))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"knowledge_base/parsers"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedParseRepairs(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedParseRepairs ------------------------")
+
+	testCases := []struct {
+		Fixture     string
+		Items       int
+		Description string
+		Warning     string
+	}{
+		{Fixture: "bom.rss", Items: 1, Description: "Café opens", Warning: "byte order mark"},
+		{Fixture: "wrong_encoding.rss", Items: 1, Description: "Café opens", Warning: `declared the encoding "iso-8859-1" but is valid UTF-8`},
+		{Fixture: "unknown_encoding.rss", Items: 1, Description: "Café opens", Warning: `unknown encoding "utf8x"`},
+		{Fixture: "invalid_utf8.rss", Items: 1, Description: "Caf� opens", Warning: "invalid UTF-8"},
+		{Fixture: "leading_text.rss", Items: 1, Description: "Fine", Warning: "text before the start of the feed"},
+		{Fixture: "truncated.rss", Items: 2, Description: "First", Warning: "after its last complete item"},
+	}
+
+	for _, testCase := range testCases {
+
+		body, err := os.ReadFile("../data/rss/broken/" + testCase.Fixture)
+		assert.NoError(t, err)
+
+		feed, warnings, err := parsers.ParseFeed(body)
+		if !assert.NoError(t, err, testCase.Fixture) {
+			continue
+		}
+		assert.Equal(t, "Broken Feed", feed.Title, testCase.Fixture)
+		assert.Len(t, feed.Items, testCase.Items, testCase.Fixture)
+		assert.Equal(t, testCase.Description, feed.Items[0].Description, testCase.Fixture)
+		assert.Len(t, warnings, 1, testCase.Fixture)
+		for _, warning := range warnings {
+			assert.Contains(t, warning, testCase.Warning, testCase.Fixture)
+		}
+	}
+}
+
+func TestFeedParseBadEntities(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedParseBadEntities ------------------------")
+
+	body, err := os.ReadFile("../data/rss/broken/bad_entities.rss")
+	assert.NoError(t, err)
+
+	feed, warnings, err := parsers.ParseFeed(body)
+	assert.NoError(t, err)
+	assert.Len(t, feed.Items, 2)
+	// The description is html so gofeed leaves the escaped characters as they are:
+	assert.Equal(t, "AT&T &mdash; profits &lt; costs and 3 &lt; 4", feed.Items[0].Description)
+	// CDATA sections are left alone:
+	assert.Equal(t, "<p>Kept as 3 < 4</p>", feed.Items[1].Description)
+	assert.Equal(t, []string{
+		"Removed 1 control characters that XML does not allow",
+		"Removed 1 references to characters that XML does not allow",
+		"Escaped 1 unescaped \"<\" characters",
+	}, warnings)
+}
+
+func TestFeedParseErrors(t *testing.T) {
+
+	fmt.Println("------------------------ TestFeedParseErrors ------------------------")
+
+	body, err := os.ReadFile("../data/rss/broken/html_error_page.html")
+	assert.NoError(t, err)
+
+	feed, _, err := parsers.ParseFeed(body)
+	assert.Nil(t, feed)
+	assert.True(t, errors.Is(err, parsers.ErrFeedParse))
+	assert.ErrorContains(t, err, "not an rss, atom or json feed")
+
+	// Truncated before the first item was complete so there is nothing to recover:
+	feed, _, err = parsers.ParseFeed([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Broken</title><item><title>Cut`))
+	assert.Nil(t, feed)
+	assert.True(t, errors.Is(err, parsers.ErrFeedParse))
+
+	// Feeds that parse are returned without warnings:
+	body, err = os.ReadFile("../data/rss/38_north_test.rss")
+	assert.NoError(t, err)
+	feed, warnings, err := parsers.ParseFeed(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "38 North", feed.Title)
+	assert.Empty(t, warnings)
+}
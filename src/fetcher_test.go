@@ -0,0 +1,173 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"knowledge_base/parsers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFetcherConfig() parsers.FetcherConfig {
+	config := parsers.DefaultFetcherConfig
+	config.UserAgent = "knowledge_base_test/1.0"
+	config.RetryBaseDelay = time.Millisecond
+	config.MaxRetryDelay = 50 * time.Millisecond
+	config.RequestTimeout = 5 * time.Second
+	config.MaxBodyBytes = 1024
+	config.MaxRedirects = 3
+	config.RespectRobots = false
+	config.DomainDelay = 0
+	return config
+}
+
+func TestFetcherRetries(t *testing.T) {
+
+	fmt.Println("------------------------ TestFetcherRetries ------------------------")
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, r.Header.Get("User-Agent"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := parsers.NewFetcher(testFetcherConfig())
+	resp, err := fetcher.Get(context.Background(), server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "knowledge_base_test/1.0", string(body))
+	assert.Equal(t, int32(3), requests.Load())
+
+	// Client errors are returned straight away and a server that keeps failing is given up on:
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer notFound.Close()
+
+	requests.Store(0)
+	resp, err = fetcher.Get(context.Background(), notFound.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	requests.Store(0)
+	resp, err = fetcher.Get(context.Background(), failing.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(testFetcherConfig().MaxRetries+1), requests.Load())
+}
+
+func TestFetcherDecompression(t *testing.T) {
+
+	fmt.Println("------------------------ TestFetcherDecompression ------------------------")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gzipWriter := gzip.NewWriter(w)
+			fmt.Fprint(gzipWriter, "gzipped feed")
+			gzipWriter.Close()
+		case "/brotli":
+			w.Header().Set("Content-Encoding", "br")
+			brotliWriter := brotli.NewWriter(w)
+			fmt.Fprint(brotliWriter, "brotli feed")
+			brotliWriter.Close()
+		default:
+			fmt.Fprint(w, r.Header.Get("Accept-Encoding"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := parsers.NewFetcher(testFetcherConfig())
+	for path, expected := range map[string]string{
+		"/gzip":   "gzipped feed",
+		"/brotli": "brotli feed",
+		"/plain":  "gzip, deflate, br",
+	} {
+		resp, err := fetcher.Get(context.Background(), server.URL+path)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(body), path)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"), path)
+	}
+}
+
+func TestFetcherLimits(t *testing.T) {
+
+	fmt.Println("------------------------ TestFetcherLimits ------------------------")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			// Rejected from its Content-Length before the body is read:
+			w.Header().Set("Content-Length", "4096")
+			fmt.Fprint(w, strings.Repeat("a", 4096))
+		case "/large_gzip":
+			// Small on the wire but larger than the limit once decompressed:
+			w.Header().Set("Content-Encoding", "gzip")
+			gzipWriter := gzip.NewWriter(w)
+			fmt.Fprint(gzipWriter, strings.Repeat("a", 4096))
+			gzipWriter.Close()
+		case "/exact":
+			fmt.Fprint(w, strings.Repeat("a", 1024))
+		case "/redirect":
+			http.Redirect(w, r, "/redirect", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := parsers.NewFetcher(testFetcherConfig())
+
+	_, err := fetcher.Get(context.Background(), server.URL+"/large")
+	assert.True(t, errors.Is(err, parsers.ErrBodyTooLarge))
+
+	resp, err := fetcher.Get(context.Background(), server.URL+"/large_gzip")
+	assert.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.True(t, errors.Is(err, parsers.ErrBodyTooLarge))
+
+	resp, err = fetcher.Get(context.Background(), server.URL+"/exact")
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Len(t, body, 1024)
+
+	_, err = fetcher.Get(context.Background(), server.URL+"/redirect")
+	assert.ErrorContains(t, err, "stopped after 3 redirects")
+}
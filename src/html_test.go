@@ -36,7 +36,8 @@ func TestHTMLContentExtraction(t *testing.T) {
 	fmt.Println("Table Paths", testComponent.Tables)
 	fmt.Println("Snapshot Paths:", testComponent.Snapshot)
 
-	assert.Equal(t, len(testComponent.Images), 4)
+	// The page has 4 images but shows the same logo twice. Duplicate image urls are downloaded once:
+	assert.Equal(t, len(testComponent.Images), 3)
 
 }
 
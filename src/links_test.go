@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractArticleLinks(t *testing.T) {
+
+	fmt.Println("------------------------ TestExtractArticleLinks ------------------------")
+
+	pageBytes, err := os.ReadFile("../data/links/article_links.html")
+	assert.Nil(t, err)
+
+	// Only links in the body of the article are kept. Links to the same page are recorded once and links to
+	// the article itself, fragments and non http(s) links are dropped:
+	links, err := parsers.ExtractArticleLinks("https://www.38north.org/2023/09/kim-putin-summit/", pageBytes)
+	assert.Nil(t, err)
+	assert.Equal(t, []parsers.ArticleLink{
+		{
+			Url:          "https://www.38north.org/2023/09/sohae-expansion/?utm_source=newsletter",
+			CanonicalUrl: "https://www.38north.org/2023/09/sohae-expansion",
+			Domain:       "38north.org",
+			AnchorText:   "the expansion at Sohae",
+			Count:        1,
+		},
+		{
+			Url:          "https://en.wikipedia.org/wiki/Vostochny_Cosmodrome#History",
+			CanonicalUrl: "https://en.wikipedia.org/wiki/Vostochny_Cosmodrome",
+			Domain:       "en.wikipedia.org",
+			AnchorText:   "Vostochny Cosmodrome",
+			Count:        1,
+		},
+		{
+			Url:          "https://www.reuters.com/world/asia-pacific/kim-putin-2023-09-13/",
+			CanonicalUrl: "https://www.reuters.com/world/asia-pacific/kim-putin-2023-09-13",
+			Domain:       "reuters.com",
+			AnchorText:   "Reuters",
+			Count:        2,
+		},
+		{
+			Url:          "https://www.kcna.kp/en/article/q/1234.kcmsd",
+			CanonicalUrl: "https://www.kcna.kp/en/article/q/1234.kcmsd",
+			Domain:       "kcna.kp",
+			AnchorText:   "KCNA photo",
+			Count:        1,
+		},
+	}, links)
+
+	// Pages without an <article> fall back to their <body>:
+	links, err = parsers.ExtractArticleLinks("https://example.com/post", []byte(`<html><body><a href="/other">Other</a></body></html>`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(links))
+	assert.Equal(t, "https://example.com/other", links[0].Url)
+
+	links, err = parsers.ExtractArticleLinks("https://example.com/post", []byte(`<html><body><p>No links</p></body></html>`))
+	assert.Nil(t, err)
+	assert.Empty(t, links)
+}
@@ -3,15 +3,22 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"knowledge_base/parsers"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
@@ -107,162 +114,146 @@ type ErrorMsg struct {
 
 func (e *Env) getRssFeeds(c *gin.Context) {
 
-	results, err := neo4j.ExecuteQuery(
-		e.Ctx,
-		e.Neo4jDriver,
-		"MATCH (feeds:Rss_Feed:Source) return feeds",
-		nil,
-		neo4j.EagerResultTransformer,
-		neo4j.ExecuteQueryWithDatabase("neo4j"))
-
+	rssFeedArray, err := parsers.GetAllRssSources(e.Ctx, e.Neo4jDriver)
 	if err != nil {
-		log.Fatal(err)
 		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
 	}
 
-	var rssFeedArray = []parsers.RssFeed{}
-	for _, record := range results.Records {
+	c.IndentedJSON(http.StatusOK, rssFeedArray)
+}
+func (e *Env) postRssFeeds(c *gin.Context) {
+
+	var newRssFeeds parsers.RssFeeds
+	err := c.BindJSON(&newRssFeeds)
+	if err != nil {
+		log.Println("Error in creating newRssFeed object", err)
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
 
-		nodeDict := record.AsMap()
+	// If validation is requested, every feed is fetched and parsed before anything is written so that a
+	// single bad url doesn't leave a partially registered batch. Website urls are swapped for the feeds
+	// that they advertise and missing metadata is filled in from the feed itself:
+	if c.Query("validate") == "true" {
+		for i := 0; i < len(newRssFeeds.Entries); i++ {
 
-		for _, nodeKey := range nodeDict {
+			discoveredRssFeed, err := parsers.DiscoverRssFeed(newRssFeeds.Entries[i].Url)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+				return
+			}
 
-			switch node := nodeKey.(type) {
-			case neo4j.Node:
+			newRssFeeds.Entries[i] = parsers.MergeDiscoveredRssFeed(newRssFeeds.Entries[i], discoveredRssFeed)
+		}
+	}
 
-				nodeProps := node.GetProperties()
+	var insertedRssFeeds []parsers.RssFeed
 
-				rssFeed := parsers.RssFeed{Id: node.ElementId}
+	for i := 0; i < len(newRssFeeds.Entries); i++ {
 
-				if url, ok := nodeProps["url"].(string); ok {
-					rssFeed.Url = url
-				} else {
-					log.Fatal("No Url for Node", node.ElementId)
-				}
-				if title, ok := nodeProps["name"].(string); ok {
-					rssFeed.Title = title
-				} else {
-					log.Fatal("No name for Node", node.ElementId)
-				}
+		insertedRssFeed, _, err := parsers.CreateRssSource(newRssFeeds.Entries[i], e.Ctx, e.Neo4jDriver)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+			return
+		}
 
-				if scheduledTime, ok := nodeProps["scheduled_time"].(string); ok {
-					rssFeed.ExecuteTime = scheduledTime
-				} else {
-					rssFeed.ExecuteTime = ""
-				}
+		insertedRssFeeds = append(insertedRssFeeds, insertedRssFeed)
+	}
 
-				if etag, ok := nodeProps["etag"].(string); ok {
-					rssFeed.Etag = etag
-				} else {
-					rssFeed.Etag = ""
-				}
+	c.IndentedJSON(http.StatusOK, insertedRssFeeds)
+}
 
-				if lastUpdated, ok := nodeProps["last_updated"].(string); ok {
-					rssFeed.LastUpdate = lastUpdated
-				} else {
-					rssFeed.LastUpdate = ""
-				}
+// Imports the feeds from an OPML subscription list. The OPML document can either be sent as the raw
+// request body or as an "opml" file in a multipart form. Feeds that already exist (by name or url) are
+// skipped and every feed in the document is reported on:
+func (e *Env) importRssFeeds(c *gin.Context) {
 
-				rssFeedArray = append(rssFeedArray, rssFeed)
-			}
+	var opmlReader io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		opmlFile, err := c.FormFile("opml")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+			return
 		}
+		openedFile, err := opmlFile.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+			return
+		}
+		defer openedFile.Close()
+		opmlReader = openedFile
 	}
-	c.IndentedJSON(http.StatusOK, rssFeedArray)
-}
-func (e *Env) postRssFeeds(c *gin.Context) {
 
-	var newRssFeeds parsers.RssFeeds
-	err := c.BindJSON(&newRssFeeds)
+	importedRssFeeds, err := parsers.ParseOpml(opmlReader)
 	if err != nil {
-		log.Fatal("Error in creating newRssFeed object", err)
-		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
 	}
 
-	var insertedRssFeeds []parsers.RssFeed
+	var importSummary parsers.OpmlImportSummary
+	importSummary.Feeds = []parsers.OpmlImportResult{}
 
-	for i := 0; i < len(newRssFeeds.Entries); i++ {
+	for _, rssFeed := range importedRssFeeds {
+
+		importResult := parsers.OpmlImportResult{Title: rssFeed.Title, Url: rssFeed.Url, Tags: rssFeed.Tags}
 
-		rssFeed := newRssFeeds.Entries[i]
-
-		result, err := neo4j.ExecuteQuery(
-			e.Ctx,
-			e.Neo4jDriver,
-			`MERGE (rss_feed:Rss_Feed:Source {name: $name})
-			ON CREATE SET
-				rss_feed.url = $url,
-				rss_feed.scheduled_time = $scheduled_time,
-				rss_feed.etag = $etag,
-				rss_feed.last_updated = $last_updated,
-				rss_feed.created = timestamp()
-			RETURN rss_feed`,
-			map[string]any{
-				"name":           rssFeed.Title,
-				"url":            rssFeed.Url,
-				"scheduled_time": rssFeed.ExecuteTime,
-				"etag":           rssFeed.Etag,
-				"last_updated":   rssFeed.LastUpdate,
-			},
-			neo4j.EagerResultTransformer,
-			neo4j.ExecuteQueryWithDatabase("neo4j"))
+		exists, err := parsers.RssSourceExists(rssFeed.Title, rssFeed.Url, e.Ctx, e.Neo4jDriver)
 		if err != nil {
-			log.Fatal(err)
-			c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+			importResult.Status = "error"
+			importResult.Error = err.Error()
+			importSummary.Errors++
+			importSummary.Feeds = append(importSummary.Feeds, importResult)
+			continue
+		}
+		if exists {
+			importResult.Status = "skipped"
+			importResult.Error = "an rss source with this name or url already exists"
+			importSummary.Skipped++
+			importSummary.Feeds = append(importSummary.Feeds, importResult)
+			continue
 		}
 
-		fmt.Printf(
-			"Created %v nodes in %+v. \n",
-			result.Summary.Counters().NodesCreated(),
-			result.Summary.ResultAvailableAfter(),
-		)
-
-		for _, record := range result.Records {
-
-			nodeDict := record.AsMap()
-
-			for _, nodeKey := range nodeDict {
-				switch node := nodeKey.(type) {
-				case neo4j.Node:
-
-					nodeProps := node.GetProperties()
-
-					rssFeed := parsers.RssFeed{Id: node.ElementId}
-
-					if url, ok := nodeProps["url"].(string); ok {
-						rssFeed.Url = url
-					} else {
-						log.Fatal("No Url for Node", node.ElementId)
-					}
-					if title, ok := nodeProps["name"].(string); ok {
-						rssFeed.Title = title
-					} else {
-						log.Fatal("No name for Node", node.ElementId)
-					}
-
-					if scheduledTime, ok := nodeProps["scheduled_time"].(string); ok {
-						rssFeed.ExecuteTime = scheduledTime
-					} else {
-						rssFeed.ExecuteTime = ""
-					}
-
-					if etag, ok := nodeProps["etag"].(string); ok {
-						rssFeed.Etag = etag
-					} else {
-						rssFeed.Etag = ""
-					}
-
-					if lastUpdated, ok := nodeProps["last_updated"].(string); ok {
-						rssFeed.LastUpdate = lastUpdated
-					} else {
-						rssFeed.LastUpdate = ""
-					}
-
-					insertedRssFeeds = append(insertedRssFeeds, rssFeed)
-				}
-			}
+		insertedRssFeed, created, err := parsers.CreateRssSource(rssFeed, e.Ctx, e.Neo4jDriver)
+		if err != nil {
+			importResult.Status = "error"
+			importResult.Error = err.Error()
+			importSummary.Errors++
+			importSummary.Feeds = append(importSummary.Feeds, importResult)
+			continue
+		}
+
+		importResult.Id = insertedRssFeed.Id
+		if created {
+			importResult.Status = "created"
+			importSummary.Created++
+		} else {
+			importResult.Status = "skipped"
+			importSummary.Skipped++
 		}
+		importSummary.Feeds = append(importSummary.Feeds, importResult)
 	}
 
-	c.IndentedJSON(http.StatusOK, insertedRssFeeds)
+	c.IndentedJSON(http.StatusOK, importSummary)
+}
+
+// Exports every rss source and its tags as an OPML subscription list:
+func (e *Env) exportRssFeeds(c *gin.Context) {
+
+	rssFeeds, err := parsers.GetAllRssSources(e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	opmlBytes, err := parsers.BuildOpml("knowledge_base rss feeds", rssFeeds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="rss_feeds.opml"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", opmlBytes)
 }
 
 func (e *Env) extractRssFeedEntries(c *gin.Context) {
@@ -281,6 +272,139 @@ func (e *Env) extractRssFeedEntries(c *gin.Context) {
 	c.JSON(http.StatusCreated, SummaryResponse)
 }
 
+// Registers the local mailboxes (mbox files or Maildir directories) that newsletters are delivered to:
+func (e *Env) postNewsletters(c *gin.Context) {
+
+	var newNewsletters []parsers.NewsletterSource
+	err := c.BindJSON(&newNewsletters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	insertedNewsletters := []parsers.NewsletterSource{}
+	for _, newsletter := range newNewsletters {
+		insertedNewsletter, err := parsers.CreateNewsletterSource(newsletter, e.Ctx, e.Neo4jDriver)
+		if errors.Is(err, parsers.ErrMailboxOutsideRoot) {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+			return
+		}
+		insertedNewsletters = append(insertedNewsletters, insertedNewsletter)
+	}
+
+	c.IndentedJSON(http.StatusOK, insertedNewsletters)
+}
+
+func (e *Env) getNewsletters(c *gin.Context) {
+
+	newsletters, err := parsers.GetNewsletterSources(e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, newsletters)
+}
+
+// Lists every source of every type along with its type (eg: rss or newsletter):
+func (e *Env) getSources(c *gin.Context) {
+
+	sources, err := parsers.GetAllSources(e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, sources)
+}
+
+// Ingests a source of any type (eg: {"type": "newsletter", "name": "Money Stuff"}):
+func (e *Env) ingestSource(c *gin.Context) {
+
+	type SourceToIngest struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+
+	var source SourceToIngest
+	err := c.BindJSON(&source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+	if _, ok := parsers.SourceTypes[source.Type]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: fmt.Sprintf("unknown source type %q, expected one of %v", source.Type, parsers.SourceTypeNames())})
+		return
+	}
+
+	SummaryResponse, _ := parsers.IngestSource(source.Type, source.Name, e.Ctx, e.Neo4jDriver)
+	c.JSON(http.StatusCreated, SummaryResponse)
+}
+
+// Archives a single article submitted by hand (eg: {"url": "...", "notes": "...", "tags": ["..."],
+// "submitted_by": "..."}). Responds with 201 for a new article and 200 when the url had already been
+// archived and the user was connected to the existing article:
+func (e *Env) submitArticle(c *gin.Context) {
+
+	var submission parsers.ManualArticleSubmission
+	err := c.BindJSON(&submission)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	summary, err := parsers.SubmitManualArticle(submission, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrInvalidSubmission):
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+	case summary.Status == parsers.StatusErrorFetch:
+		c.JSON(http.StatusBadGateway, summary)
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, summary)
+	case summary.Status == parsers.StatusCreated:
+		c.JSON(http.StatusCreated, summary)
+	default:
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// Lists the articles posted between the from and to query parameters. Both accept RFC3339 timestamps or
+// plain dates (eg: 2023-10-01). from defaults to the beginning of time and to, which is exclusive, to now:
+func (e *Env) getRssEntries(c *gin.Context) {
+
+	from := time.Time{}
+	to := time.Now().UTC()
+
+	if fromParam := c.Query("from"); fromParam != "" {
+		parsedFrom, err := parsers.ParseFeedDate(fromParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+			return
+		}
+		from = parsedFrom
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		parsedTo, err := parsers.ParseFeedDate(toParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+			return
+		}
+		to = parsedTo
+	}
+
+	rssEntries, err := parsers.GetRssArticlesByDateRange(from, to, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rssEntries)
+}
+
 type RssUrlEntry struct {
 	Id string `uri:"id"`
 }
@@ -289,16 +413,649 @@ func (e *Env) getRssEntry(c *gin.Context) {
 	var urlEntry RssUrlEntry
 	err := c.ShouldBindUri(&urlEntry)
 	if err != nil {
-		log.Fatal(err)
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	rssEntry, err := parsers.GetRssArticleById(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rssEntry)
+}
+
+// Lists the previous versions of an article along with what changed between each version:
+func (e *Env) getRssEntryRevisions(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	revisionHistory, err := parsers.GetRssArticleRevisions(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, revisionHistory)
+	}
+}
+
+// Fetches the page of an article and fills in the metadata it is missing from the page's meta tags,
+// OpenGraph, Twitter card and JSON-LD:
+func (e *Env) enrichRssEntryMetadata(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	rssEntry, err := parsers.EnrichArticleMetadata(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	var sourceErr *parsers.SourceError
+	switch {
+	case err == nil:
+		c.IndentedJSON(http.StatusOK, rssEntry)
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case errors.Is(err, parsers.ErrNoArticlePage):
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+	case errors.As(err, &sourceErr):
+		c.JSON(http.StatusBadGateway, ErrorMsg{Error: err.Error()})
+	default:
 		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
 	}
+}
 
-	fmt.Println(urlEntry.Id)
+// Lists the pages an article links to and the articles that link to it:
+func (e *Env) getRssEntryLinks(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
 
+	articleLinks, err := parsers.GetArticleLinks(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, articleLinks)
+	}
+}
+
+// Records the links of an article from its captured page, or its live page if it hasn't been captured:
+func (e *Env) refreshRssEntryLinks(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	links, err := parsers.RefreshArticleLinks(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	var sourceErr *parsers.SourceError
+	switch {
+	case err == nil:
+		c.IndentedJSON(http.StatusOK, links)
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case errors.Is(err, parsers.ErrNoArticlePage):
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+	case errors.As(err, &sourceErr):
+		c.JSON(http.StatusBadGateway, ErrorMsg{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	}
+}
+
+// Lists the external sites (or pages, with by=page) that the most articles link to. limit defaults to 25:
+func (e *Env) getMostCitedSources(c *gin.Context) {
+
+	limit := 25
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = parsedLimit
+	}
+	byPage := false
+	switch c.DefaultQuery("by", "domain") {
+	case "domain":
+	case "page":
+		byPage = true
+	default:
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: "by must be domain or page"})
+		return
+	}
+
+	sources, err := parsers.GetMostCitedSources(byPage, limit, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, sources)
+}
+
+// Lists the sources of every type that are failing or have been disabled along with the reason why:
+func (e *Env) getRssFeedHealth(c *gin.Context) {
+
+	healthReports, err := parsers.GetUnhealthySources(e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, healthReports)
+}
+
+// Lists the ingest runs of an rss feed, newest first:
+func (e *Env) getRssFeedRuns(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	ingestRuns, err := parsers.GetIngestRunsForSource(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, ingestRuns)
+}
+
+type RssFeedIgnoreRobotsRequest struct {
+	IgnoreRobots bool `json:"ignore_robots"`
+}
+
+// Sets whether an rss feed skips the robots.txt check. Only for sources that we have permission to archive:
+func (e *Env) setRssFeedIgnoreRobots(c *gin.Context) {
+
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	var ignoreRobotsRequest RssFeedIgnoreRobotsRequest
+	if err := c.BindJSON(&ignoreRobotsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	rssFeed, err := parsers.SetRssSourceIgnoreRobots(urlEntry.Id, ignoreRobotsRequest.IgnoreRobots, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rssFeed)
+}
+
+// Starts a backfill of an rss feed's past articles from its sitemaps or archive pages (see
+// parsers.BackfillOptions). The backfill runs in the background, its progress is read from /backfills/:id:
+func (e *Env) startRssFeedBackfill(c *gin.Context) {
+
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	// An empty body backfills from the sitemaps listed in the site's robots.txt:
+	var backfillOptions parsers.BackfillOptions
+	if err := c.ShouldBindJSON(&backfillOptions); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	backfillJob, err := parsers.StartBackfillJob(urlEntry.Id, backfillOptions, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(backfillErrorStatus(err), ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusAccepted, backfillJob)
+}
+
+// Lists the backfills of an rss feed, newest first:
+func (e *Env) getRssFeedBackfills(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	backfillJobs, err := parsers.GetBackfillJobsForSource(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, backfillJobs)
+}
+
+func (e *Env) getBackfill(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	backfillJob, err := parsers.GetBackfillJob(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, backfillJob)
+}
+
+// Continues a backfill that stopped before it finished from where it got to:
+func (e *Env) resumeBackfill(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	backfillJob, err := parsers.ResumeBackfillJob(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(backfillErrorStatus(err), ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusAccepted, backfillJob)
+}
+
+func backfillErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, parsers.ErrInvalidBackfill):
+		return http.StatusBadRequest
+	case errors.Is(err, parsers.ErrBackfillRunning):
+		return http.StatusConflict
+	}
+	return http.StatusNotFound
+}
+
+type AuthorMergeRequest struct {
+	SourceId string `json:"source_id"`
+	TargetId string `json:"target_id"`
+}
+
+// Merges two authors that turned out to be the same person. Every article written by the source author is
+// connected to the target author and the source author is removed:
+func (e *Env) mergeAuthors(c *gin.Context) {
+
+	var mergeRequest AuthorMergeRequest
+	if err := c.BindJSON(&mergeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+	if mergeRequest.SourceId == "" || mergeRequest.TargetId == "" || mergeRequest.SourceId == mergeRequest.TargetId {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: "source_id and target_id must be two different author ids"})
+		return
+	}
+
+	mergedAuthor, err := parsers.MergeAuthors(mergeRequest.SourceId, mergeRequest.TargetId, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, mergedAuthor)
+}
+
+type AuthorWithArticles struct {
+	Author   parsers.RssAuthor  `json:"author"`
+	Articles []parsers.RssEntry `json:"articles"`
+}
+
+func (e *Env) getAuthor(c *gin.Context) {
+
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	author, articles, err := parsers.GetAuthorWithArticles(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, AuthorWithArticles{Author: author, Articles: articles})
+}
+
+// Lists the entities mentioned by an article, the most mentioned first:
+func (e *Env) getRssEntryEntities(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	entities, err := parsers.GetArticleEntities(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, entities)
+	}
+}
+
+// Extracts the entities mentioned by an article again, replacing the ones that were found before:
+func (e *Env) refreshRssEntryEntities(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	entities, err := parsers.RefreshArticleEntities(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, entities)
+	}
+}
+
+// Lists the entities mentioned by the most articles. Filtered by the type query parameter (eg: person,
+// organization, place or weapon_system), limit defaults to 50:
+func (e *Env) getEntities(c *gin.Context) {
+
+	limit := 50
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = parsedLimit
+	}
+
+	entities, err := parsers.GetEntities(c.Query("type"), limit, e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, entities)
+}
+
+type EntityWithArticles struct {
+	Entity   parsers.Entity          `json:"entity"`
+	Articles []parsers.EntityArticle `json:"articles"`
+}
+
+func (e *Env) getEntity(c *gin.Context) {
+
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	entity, articles, err := parsers.GetEntityWithArticles(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrEntityNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, EntityWithArticles{Entity: entity, Articles: articles})
+	}
+}
+
+type EntityMergeRequest struct {
+	SourceId string `json:"source_id"`
+	TargetId string `json:"target_id"`
+}
+
+// Merges two entities that turned out to be the same. Every article that mentions the source entity is
+// connected to the target entity and the source entity's names become aliases of the target:
+func (e *Env) mergeEntities(c *gin.Context) {
+
+	var mergeRequest EntityMergeRequest
+	if err := c.BindJSON(&mergeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+	if mergeRequest.SourceId == "" || mergeRequest.TargetId == "" || mergeRequest.SourceId == mergeRequest.TargetId {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: "source_id and target_id must be two different entity ids"})
+		return
+	}
+
+	mergedEntity, err := parsers.MergeEntities(mergeRequest.SourceId, mergeRequest.TargetId, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrEntityNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, mergedEntity)
+	}
+}
+
+type EntityAliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// Adds an alias to an entity so that later mentions of the alias are connected to it:
+func (e *Env) addEntityAlias(c *gin.Context) {
+
+	var urlEntry RssUrlEntry
+	if err := c.ShouldBindUri(&urlEntry); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+	var aliasRequest EntityAliasRequest
+	if err := c.BindJSON(&aliasRequest); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+	if parsers.NormaliseEntityName(aliasRequest.Alias) == "" {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: "alias must contain letters or digits"})
+		return
+	}
+
+	entity, err := parsers.AddEntityAlias(urlEntry.Id, aliasRequest.Alias, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrEntityNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, entity)
+	}
+}
+
+// Lists the topics an article is about:
+func (e *Env) getRssEntryTopics(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	topics, err := parsers.GetArticleTopics(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, topics)
+	}
+}
+
+// Tags an article with the topics whose rules match it again, replacing the topics it was tagged with:
+func (e *Env) refreshRssEntryTopics(c *gin.Context) {
+	var urlEntry RssUrlEntry
+	err := c.ShouldBindUri(&urlEntry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	topics, err := parsers.RefreshArticleTopics(urlEntry.Id, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, topics)
+	}
+}
+
+// Lists every topic along with the number of articles about it:
+func (e *Env) getTopics(c *gin.Context) {
+
+	topics, err := parsers.GetTopics(e.Ctx, e.Neo4jDriver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, topics)
+}
+
+type TopicWithArticles struct {
+	Topic    parsers.Topic      `json:"topic"`
+	Articles []parsers.RssEntry `json:"articles"`
+}
+
+// Lists the articles about a topic, the most recently posted first. limit defaults to 50 and offset to 0:
+func (e *Env) getTopicArticles(c *gin.Context) {
+
+	limit := 50
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = parsedLimit
+	}
+	offset := 0
+	if offsetParam := c.Query("offset"); offsetParam != "" {
+		parsedOffset, err := strconv.Atoi(offsetParam)
+		if err != nil || parsedOffset < 0 {
+			c.JSON(http.StatusBadRequest, ErrorMsg{Error: "offset must be zero or a positive integer"})
+			return
+		}
+		offset = parsedOffset
+	}
+
+	topic, articles, err := parsers.GetTopicArticles(c.Param("name"), limit, offset, e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrTopicNotFound):
+		c.JSON(http.StatusNotFound, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, TopicWithArticles{Topic: topic, Articles: articles})
+	}
+}
+
+// Reloads the topic rules and tags every article with them again. Used after the rules have been changed:
+func (e *Env) retagTopics(c *gin.Context) {
+
+	summary, err := parsers.RetagAllArticles(e.Ctx, e.Neo4jDriver)
+	switch {
+	case errors.Is(err, parsers.ErrRetagRunning):
+		c.JSON(http.StatusConflict, ErrorMsg{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorMsg{Error: err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, summary)
+	}
 }
 
 func main() {
 
+	migrateDates := flag.Bool("migrate-dates", false, "convert date properties stored as strings or timestamps into neo4j datetimes and exit")
+	schedulerInterval := flag.Duration("scheduler-interval", time.Minute, "how often the scheduler checks for sources of every type that are due, 0 disables the scheduler")
+	flag.IntVar(&parsers.FeedHealth.DisableAfter, "disable-after", parsers.FeedHealth.DisableAfter, "consecutive failures after which an rss feed is disabled, 0 never disables a feed")
+	flag.DurationVar(&parsers.FeedHealth.BaseBackoff, "backoff", parsers.FeedHealth.BaseBackoff, "delay before a failed rss feed is retried, doubled after every consecutive failure")
+	flag.DurationVar(&parsers.FeedHealth.MaxBackoff, "max-backoff", parsers.FeedHealth.MaxBackoff, "longest delay before a failed rss feed is retried")
+	fetcherConfig := parsers.DefaultFetcherConfig
+	flag.StringVar(&fetcherConfig.UserAgent, "user-agent", fetcherConfig.UserAgent, "User-Agent sent with every request for feeds, pages and images")
+	flag.DurationVar(&fetcherConfig.RequestTimeout, "request-timeout", fetcherConfig.RequestTimeout, "longest time a single request for a feed, page or image may take")
+	flag.Int64Var(&fetcherConfig.MaxBodyBytes, "max-body-bytes", fetcherConfig.MaxBodyBytes, "largest response body that will be downloaded")
+	flag.BoolVar(&fetcherConfig.RespectRobots, "respect-robots", fetcherConfig.RespectRobots, "skip urls that a site's robots.txt disallows")
+	flag.DurationVar(&fetcherConfig.DomainDelay, "domain-delay", fetcherConfig.DomainDelay, "shortest time between two requests to the same site, longer if its robots.txt sets a Crawl-delay")
+	flag.IntVar(&fetcherConfig.MaxDomainConcurrency, "domain-concurrency", fetcherConfig.MaxDomainConcurrency, "most requests made to the same site at once")
+	flag.BoolVar(&parsers.MediaDownloads.Enabled, "download-media", false, "download the audio and video enclosures of feeds into object storage")
+	flag.StringVar(&parsers.MediaDownloads.Bucket, "media-bucket", parsers.MediaDownloads.Bucket, "object storage bucket that downloaded media is stored in")
+	flag.Int64Var(&parsers.MediaDownloads.MaxBytes, "max-media-bytes", parsers.MediaDownloads.MaxBytes, "largest audio or video file that will be downloaded")
+	flag.StringVar(&parsers.PageCaptures.Bucket, "html-bucket", parsers.PageCaptures.Bucket, "object storage bucket that captured article pages are stored in")
+	flag.StringVar(&parsers.Newsletters.MailRoot, "mail-root", "", "directory that the mailboxes of newsletters are read from, no newsletter can be read until it is set")
+	minioEndpoint := flag.String("minio-endpoint", "localhost:9000", "object storage endpoint, credentials are read from MINIO_ROOT_USER and MINIO_ROOT_PASSWORD")
+	flag.BoolVar(&parsers.EntityExtraction.Enabled, "extract-entities", false, "extract the entities mentioned by every new article as it is ingested")
+	entityExtractor := flag.String("entity-extractor", "gazetteer", "name of the backend used to extract the entities mentioned by articles")
+	gazetteerPath := flag.String("gazetteer", "../data/entities/gazetteer.json", "json file of the entities and aliases the gazetteer entity extractor looks for")
+	flag.StringVar(&parsers.TopicTagging.RulesPath, "topics", "../data/topics/topics.json", "json file of the topics and the rules that tag articles with them, reloaded by POST /topics/retag")
+	flag.Parse()
+
+	parsers.DefaultFetcher = parsers.NewFetcher(fetcherConfig)
+
+	// Without its file the gazetteer only finds entities with its rules:
+	gazetteer, err := parsers.LoadGazetteer(*gazetteerPath)
+	if err != nil {
+		log.Println("Unable to load the gazetteer, entities will only be found by rules:", err)
+	} else {
+		parsers.RegisterEntityExtractor("gazetteer", gazetteer)
+	}
+	extractor, ok := parsers.EntityExtractors[*entityExtractor]
+	if !ok {
+		log.Fatalf("Unknown entity extractor %q", *entityExtractor)
+	}
+	parsers.EntityExtraction.Extractor = extractor
+
+	// Articles are only tagged with topics once there are rules for them:
+	if _, err := parsers.ReloadTopicRules(); err != nil {
+		log.Println("Unable to load the topic rules, articles won't be tagged with topics:", err)
+	}
+
+	// The client doesn't connect until it is used so it is always created for page captures, media is only
+	// downloaded when it is enabled:
+	minioClient, err := minio.New(*minioEndpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("MINIO_ROOT_USER"), os.Getenv("MINIO_ROOT_PASSWORD"), ""),
+		Secure: false,
+	})
+	if err != nil {
+		log.Fatal("Unable to create minio client", err)
+	}
+	parsers.PageCaptures.Client = minioClient
+	if parsers.MediaDownloads.Enabled {
+		parsers.MediaDownloads.Client = minioClient
+	}
+
 	dbPath := "./test.db"
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -344,14 +1101,80 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err = parsers.CreateIndexes(ctx, driver); err != nil {
+		log.Fatal(err)
+	}
+
+	if *migrateDates {
+		migrationSummary, err := parsers.MigrateDatesToDatetime(ctx, driver)
+		if err != nil {
+			log.Fatal("Error in migrating date properties to datetimes", err)
+		}
+		fmt.Printf("Migrated dates on %d nodes and %d relationships. \n", migrationSummary.NodesUpdated, migrationSummary.RelationshipsUpdated)
+		for _, unparseable := range migrationSummary.Unparseable {
+			fmt.Println("Unable to parse date:", unparseable)
+		}
+		return
+	}
+
+	if *schedulerInterval > 0 {
+		go parsers.RunScheduler(*schedulerInterval, ctx, driver)
+	}
+
+	resumedBackfills, err := parsers.ResumeInterruptedBackfillJobs(ctx, driver)
+	if err != nil {
+		fmt.Println("Unable to resume the backfills that were running when the server stopped:", err)
+	}
+	for _, backfillJob := range resumedBackfills {
+		fmt.Println("Resumed backfill", backfillJob.Id, "of source", backfillJob.SourceId)
+	}
+
 	env := &Env{db: db, Neo4jDriver: driver, Ctx: ctx}
 	router := gin.Default()
 
 	router.GET("/rss_feeds", env.getRssFeeds)
 	router.POST("/rss_feeds", env.postRssFeeds)
+	router.POST("/rss_feeds/import", env.importRssFeeds)
+	router.GET("/rss_feeds/export.opml", env.exportRssFeeds)
 	router.POST("/rss_feeds/ingest/", env.extractRssFeedEntries)
-
+	router.GET("/rss_feeds/health", env.getRssFeedHealth)
+	router.GET("/rss_feeds/:id/runs", env.getRssFeedRuns)
+	router.PUT("/rss_feeds/:id/ignore_robots", env.setRssFeedIgnoreRobots)
+	router.POST("/rss_feeds/:id/backfill", env.startRssFeedBackfill)
+	router.GET("/rss_feeds/:id/backfills", env.getRssFeedBackfills)
+	router.GET("/backfills/:id", env.getBackfill)
+	router.POST("/backfills/:id/resume", env.resumeBackfill)
+
+	router.GET("/newsletters", env.getNewsletters)
+	router.POST("/newsletters", env.postNewsletters)
+	router.GET("/sources", env.getSources)
+	router.POST("/sources/ingest", env.ingestSource)
+
+	router.POST("/articles", env.submitArticle)
+
+	router.GET("/rss_entries", env.getRssEntries)
 	router.GET("/rss_entries/:id", env.getRssEntry)
+	router.GET("/rss_entries/:id/revisions", env.getRssEntryRevisions)
+	router.POST("/rss_entries/:id/metadata", env.enrichRssEntryMetadata)
+	router.GET("/rss_entries/:id/links", env.getRssEntryLinks)
+	router.POST("/rss_entries/:id/links", env.refreshRssEntryLinks)
+	router.GET("/rss_entries/:id/entities", env.getRssEntryEntities)
+	router.POST("/rss_entries/:id/entities", env.refreshRssEntryEntities)
+	router.GET("/rss_entries/:id/topics", env.getRssEntryTopics)
+	router.POST("/rss_entries/:id/topics", env.refreshRssEntryTopics)
+	router.GET("/links/most_cited", env.getMostCitedSources)
+
+	router.GET("/authors/:id", env.getAuthor)
+	router.POST("/authors/merge", env.mergeAuthors)
+
+	router.GET("/entities", env.getEntities)
+	router.GET("/entities/:id", env.getEntity)
+	router.POST("/entities/merge", env.mergeEntities)
+	router.POST("/entities/:id/aliases", env.addEntityAlias)
+
+	router.GET("/topics", env.getTopics)
+	router.POST("/topics/retag", env.retagTopics)
+	router.GET("/topics/:name/articles", env.getTopicArticles)
 
 	router.Run("localhost:8080")
 
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualArticlePage(t *testing.T) {
+
+	fmt.Println("------------------------ TestManualArticlePage ------------------------")
+
+	defer useTestDefaultFetcher()()
+
+	pageBytes, err := os.ReadFile("../data/manual/jsonld_article.html")
+	assert.Nil(t, err)
+	pngBytes := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sohae-expansion":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(pageBytes)
+		case "/images/pad.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Relative images are resolved against the page and downloaded once. Images that fail are skipped:
+	htmlContent := parsers.HtmlContent{Url: server.URL + "/sohae-expansion"}
+	defer htmlContent.RemoveTempFiles()
+	err = htmlContent.LoadHtmlPage()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, htmlContent.StatusCode)
+	assert.Equal(t, []string{server.URL + "/images/pad.png"}, htmlContent.ImageUrls)
+	assert.Equal(t, 1, len(htmlContent.Images))
+	imageBytes, err := os.ReadFile(htmlContent.Images[0])
+	assert.Nil(t, err)
+	assert.Equal(t, pngBytes, imageBytes)
+
+	// Values missing from the meta tags are read from the JSON-LD Article:
+	page, err := parsers.ArticlePageFromHtmlContent(htmlContent)
+	assert.Nil(t, err)
+	assert.Equal(t, "Inside the Sohae Expansion | 38 North", page.Title)
+	assert.Equal(t, "New construction at the Sohae Satellite Launching Station.", page.Description)
+	assert.Equal(t, "Jenny Town and Martyn Williams", page.Author)
+	assert.Equal(t, "https://www.38north.org/wp-content/uploads/2023/08/sohae.jpg", page.ImageUrl)
+	assert.Equal(t, time.Date(2023, 8, 30, 9, 0, 0, 0, time.UTC), page.DatePublished)
+	assert.Equal(t, time.Date(2023, 8, 31, 10, 15, 0, 0, time.UTC), page.DateModified)
+
+	// Bylines are split into authors and the submission's tags become categories:
+	item := parsers.ManualSourceItem(page, []string{"Satellites"})
+	assert.Equal(t, server.URL+"/sohae-expansion", item.Entry.Url)
+	assert.Equal(t, "Jenny Town and Martyn Williams", item.Entry.Creator)
+	assert.Equal(t, []string{"Satellites"}, item.Entry.Categories)
+	assert.Equal(t, []parsers.RssAuthor{{Name: "Jenny Town"}, {Name: "Martyn Williams"}}, item.Authors)
+	assert.NotNil(t, item.Page)
+
+	pagePath := htmlContent.HtmlPage
+	htmlContent.RemoveTempFiles()
+	_, err = os.Stat(pagePath)
+	assert.True(t, os.IsNotExist(err))
+
+	missing := parsers.HtmlContent{Url: server.URL + "/missing"}
+	err = missing.LoadHtmlPage()
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+	assert.Equal(t, "", missing.HtmlPage)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractPageMetadata(t *testing.T) {
+
+	fmt.Println("------------------------ TestExtractPageMetadata ------------------------")
+
+	pageBytes, err := os.ReadFile("../data/metadata/article_cards.html")
+	assert.Nil(t, err)
+
+	// Twitter cards stand in for missing OpenGraph tags and JSON-LD references to a Person or Organization
+	// elsewhere in the @graph are followed:
+	metadata, err := parsers.ExtractPageMetadata("https://www.38north.org/2023/11/solid-fuel-engine/", pageBytes)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://www.38north.org/2023/11/solid-fuel-engine/?utm_source=rss", metadata.CanonicalUrl)
+	assert.Equal(t, "North Korea Tests New Solid-Fuel Engine", metadata.Title)
+	assert.Equal(t, "Satellite imagery shows a new engine test at Sohae.", metadata.Description)
+	assert.Equal(t, []string{"Jenny Town"}, metadata.Authors)
+	assert.Equal(t, "Jenny Town", metadata.Byline)
+	assert.Equal(t, time.Date(2023, 11, 21, 14, 0, 0, 0, time.UTC), metadata.DatePublished.UTC())
+	assert.Equal(t, time.Date(2023, 11, 22, 16, 45, 0, 0, time.UTC), metadata.DateModified.UTC())
+	assert.Equal(t, "Missiles", metadata.Section)
+	assert.Equal(t, []string{"Sohae", "Solid Fuel"}, metadata.Keywords)
+	assert.Equal(t, "https://www.38north.org/wp-content/uploads/2023/11/engine-test.jpg", metadata.LeadImage)
+	assert.Equal(t, "38 North, a program of the Stimson Center", metadata.Publisher)
+	assert.Equal(t, map[string]string{
+		"canonical_url":  parsers.MetadataFromHtml,
+		"title":          parsers.MetadataFromTwitter,
+		"description":    parsers.MetadataFromTwitter,
+		"authors":        parsers.MetadataFromJsonLd,
+		"date_published": parsers.MetadataFromJsonLd,
+		"date_modified":  parsers.MetadataFromOpenGraph,
+		"section":        parsers.MetadataFromOpenGraph,
+		"keywords":       parsers.MetadataFromOpenGraph,
+		"lead_image":     parsers.MetadataFromTwitter,
+		"publisher":      parsers.MetadataFromJsonLd,
+	}, metadata.Sources)
+
+	// Values the feed supplied are kept and the page fills in the rest:
+	rssEntry := parsers.RssEntry{
+		Url:          "https://feeds.38north.org/~r/38north/~3/solid-fuel-engine",
+		CanonicalUrl: parsers.CanonicalizeUrl("https://feeds.38north.org/~r/38north/~3/solid-fuel-engine"),
+		Title:        "North Korea Tests New Engine",
+		DatePosted:   time.Date(2023, 11, 21, 15, 0, 0, 0, time.UTC),
+	}
+	parsers.MergeEntryMetadata(&rssEntry, metadata)
+	assert.Equal(t, parsers.CanonicalizeUrl(metadata.CanonicalUrl), rssEntry.CanonicalUrl)
+	assert.Equal(t, "North Korea Tests New Engine", rssEntry.Title)
+	assert.Equal(t, "Satellite imagery shows a new engine test at Sohae.", rssEntry.Description)
+	assert.Equal(t, "Jenny Town", rssEntry.Creator)
+	assert.Equal(t, time.Date(2023, 11, 21, 15, 0, 0, 0, time.UTC), rssEntry.DatePosted)
+	assert.Equal(t, "Missiles", rssEntry.Section)
+	assert.Equal(t, []string{"Sohae", "Solid Fuel"}, rssEntry.Keywords)
+	assert.Equal(t, "38 North, a program of the Stimson Center", rssEntry.Publisher)
+	assert.Equal(t, parsers.MetadataFromRss, rssEntry.MetadataSources["title"])
+	assert.Equal(t, parsers.MetadataFromRss, rssEntry.MetadataSources["date_published"])
+	assert.Equal(t, parsers.MetadataFromTwitter, rssEntry.MetadataSources["description"])
+	assert.Equal(t, parsers.MetadataFromJsonLd, rssEntry.MetadataSources["authors"])
+	assert.Equal(t, parsers.MetadataFromHtml, rssEntry.MetadataSources["canonical_url"])
+
+	// Pages without any metadata leave the article as it is:
+	emptyMetadata, err := parsers.ExtractPageMetadata("https://example.com/", []byte("<html><body><p>Text</p></body></html>"))
+	assert.Nil(t, err)
+	assert.Empty(t, emptyMetadata.Sources)
+	emptyEntry := parsers.RssEntry{Url: "https://example.com/", CanonicalUrl: "https://example.com/"}
+	parsers.MergeEntryMetadata(&emptyEntry, emptyMetadata)
+	assert.Equal(t, "https://example.com/", emptyEntry.CanonicalUrl)
+	assert.Empty(t, emptyEntry.MetadataSources)
+}
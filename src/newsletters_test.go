@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMailbox(t *testing.T) {
+
+	fmt.Println("------------------------ TestReadMailbox ------------------------")
+
+	// mbox files hold several messages. Messages without a text or html body are skipped with a warning:
+	messages, warnings, err := parsers.ReadMailbox("../data/newsletters/newsletters.mbox")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, 1, len(warnings))
+	assert.Contains(t, warnings[0], "picture@example.com has no text or html body")
+
+	// Encoded headers, quoted-printable bodies and escaped "From " lines are decoded:
+	weekly := messages[0]
+	assert.Equal(t, "weekly-2023-10-23@mail.38north.org", weekly.MessageId)
+	assert.Equal(t, "Weekly Briefing: Sohae — October 23", weekly.Subject)
+	assert.Equal(t, "Jenny Town — 38 North", weekly.FromName)
+	assert.Equal(t, "newsletter@mail.38north.org", weekly.FromEmail)
+	assert.Equal(t, time.Date(2023, 10, 23, 0, 0, 0, 0, time.UTC), weekly.Date)
+	assert.Equal(t, "38 North Weekly <weekly.38north.org>", weekly.ListId)
+	assert.Equal(t, "utf-8", weekly.Charset)
+	assert.Contains(t, weekly.TextBody, "This week at Sohae — new construction.\nFrom the archives")
+	assert.Contains(t, weekly.HtmlBody, `<a href="https://mail.38north.org/view/weekly-2023-10-23">View this email in your browser</a>`)
+
+	// Links are kept once each with their anchor text. Links that aren't http(s) are dropped:
+	assert.Equal(t, []parsers.RssLink{
+		{Href: "https://mail.38north.org/view/weekly-2023-10-23", Title: "View this email in your browser"},
+		{Href: "https://www.38north.org/2023/10/sohae/", Title: "new construction"},
+		{Href: "https://www.38north.org/unsubscribe", Title: "Unsubscribe"},
+	}, weekly.Links)
+
+	// Messages without a Message-ID are identified by a hash. Plain text links are found in the text body:
+	digest := messages[1]
+	assert.True(t, strings.HasPrefix(digest.MessageId, "sha1-"))
+	assert.Equal(t, "", digest.FromName)
+	assert.Equal(t, "digest@example.com", digest.FromEmail)
+	assert.Equal(t, "", digest.HtmlBody)
+	assert.Equal(t, []parsers.RssLink{
+		{Href: "https://example.com/one"},
+		{Href: "https://example.com/two"},
+	}, digest.Links)
+
+	messages, _, err = parsers.ReadMailbox("../data/newsletters/newsletters.mbox")
+	assert.Nil(t, err)
+	assert.Equal(t, digest.MessageId, messages[1].MessageId)
+
+	// Maildir messages are read from new/ and cur/ in the order they were delivered. Bodies in other
+	// charsets are transcoded:
+	messages, warnings, err = parsers.ReadMailbox("../data/newsletters/maildir")
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "Money Stuff: Earlier", messages[0].Subject)
+	assert.Equal(t, "Money Stuff: Café bonds", messages[1].Subject)
+	assert.Equal(t, "Matt Levine", messages[1].FromName)
+	assert.Contains(t, messages[1].HtmlBody, "Café bonds.")
+	assert.Equal(t, "windows-1252", messages[1].Charset)
+
+	_, _, err = parsers.ReadMailbox("../data/newsletters/missing.mbox")
+	assert.Error(t, err)
+	_, _, err = parsers.ReadMailbox("../data/newsletters")
+	assert.Error(t, err)
+}
+
+func TestNewsletterEntryFromMail(t *testing.T) {
+
+	fmt.Println("------------------------ TestNewsletterEntryFromMail ------------------------")
+
+	messages, _, err := parsers.ReadMailbox("../data/newsletters/newsletters.mbox")
+	assert.Nil(t, err)
+
+	rssEntry := parsers.RssEntryFromMailMessage(messages[0])
+	assert.Equal(t, "weekly-2023-10-23@mail.38north.org", rssEntry.Guid)
+	assert.Equal(t, "mid:weekly-2023-10-23@mail.38north.org", rssEntry.Url)
+	assert.Equal(t, rssEntry.Url, rssEntry.CanonicalUrl)
+	assert.Equal(t, "Weekly Briefing: Sohae — October 23", rssEntry.Title)
+	assert.Equal(t, "Jenny Town — 38 North", rssEntry.Creator)
+	assert.Equal(t, messages[0].HtmlBody, rssEntry.Content)
+	assert.Equal(t, "This week at Sohae — new construction. From the archives: our 2015 report. Read it at https://www.38north.org/2023/10/sohae/.", rssEntry.Description)
+	assert.Equal(t, "https://mail.38north.org/view/weekly-2023-10-23", rssEntry.ExternalUrl)
+	assert.Equal(t, messages[0].Links, rssEntry.Links)
+	assert.Equal(t, time.Date(2023, 10, 23, 0, 0, 0, 0, time.UTC), rssEntry.DatePosted)
+
+	// Senders without a name are credited by their address, text bodies are the content of emails without html:
+	rssEntry = parsers.RssEntryFromMailMessage(messages[1])
+	assert.Equal(t, "digest@example.com", rssEntry.Creator)
+	assert.Equal(t, messages[1].TextBody, rssEntry.Content)
+	assert.Equal(t, "", rssEntry.ExternalUrl)
+
+	// Previews of html only emails are read from the html and cut short:
+	rssEntry = parsers.RssEntryFromMailMessage(parsers.MailMessage{
+		MessageId: "long@example.com",
+		HtmlBody:  "<html><head><title>Ignored</title></head><body><p>" + strings.Repeat("word ", 100) + "</p></body></html>",
+	})
+	assert.True(t, strings.HasPrefix(rssEntry.Description, "word word"))
+	assert.Equal(t, 281, utf8.RuneCountInString(rssEntry.Description))
+	assert.True(t, strings.HasSuffix(rssEntry.Description, "…"))
+}
+
+func TestMailboxPathInsideMailRoot(t *testing.T) {
+
+	fmt.Println("------------------------ TestMailboxPathInsideMailRoot ------------------------")
+
+	defer func(mailRoot string) { parsers.Newsletters.MailRoot = mailRoot }(parsers.Newsletters.MailRoot)
+
+	parsers.Newsletters.MailRoot = ""
+	_, err := parsers.ResolveMailboxPath("newsletters.mbox")
+	assert.ErrorIs(t, err, parsers.ErrMailboxOutsideRoot)
+
+	parsers.Newsletters.MailRoot = "../data/newsletters"
+	mailboxPath, err := parsers.ResolveMailboxPath("newsletters.mbox")
+	assert.Nil(t, err)
+	messages, _, err := parsers.ReadMailbox(mailboxPath)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, messages)
+
+	mailboxPath, err = parsers.ResolveMailboxPath("../data/newsletters/maildir")
+	assert.ErrorIs(t, err, parsers.ErrMailboxOutsideRoot)
+	assert.Equal(t, "", mailboxPath)
+
+	for _, outsidePath := range []string{"/etc/passwd", "../../src/main.go", "maildir/../../test.env"} {
+		_, err = parsers.ResolveMailboxPath(outsidePath)
+		assert.ErrorIs(t, err, parsers.ErrMailboxOutsideRoot, outsidePath)
+	}
+	// Absolute paths are read when they are inside the mail root:
+	absoluteMaildir, _ := filepath.Abs("../data/newsletters/maildir")
+	_, err = parsers.ResolveMailboxPath(absoluteMaildir)
+	assert.Nil(t, err)
+}
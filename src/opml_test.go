@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"knowledge_base/parsers"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpmlImportParsing(t *testing.T) {
+
+	fmt.Println("------------------------ TestOpmlImportParsing ------------------------")
+
+	opmlFile, err := os.Open("../data/opml/test_subscriptions.opml")
+	if err != nil {
+		log.Fatal("Unable to open the test opml file", err)
+	}
+	defer opmlFile.Close()
+
+	rssFeeds, err := parsers.ParseOpml(opmlFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(rssFeeds))
+
+	assert.Equal(t, "38 North", rssFeeds[0].Title)
+	assert.Equal(t, "https://www.38north.org/feed/", rssFeeds[0].Url)
+	assert.Equal(t, "https://www.38north.org/", rssFeeds[0].SiteLink)
+	assert.Equal(t, []string{"Asia/Korea"}, rssFeeds[0].Tags)
+
+	// Outline category attributes are added alongside the nested category path:
+	assert.Equal(t, []string{"Asia/Korea", "Sanctions"}, rssFeeds[1].Tags)
+	assert.Equal(t, []string{"Arms Control"}, rssFeeds[2].Tags)
+
+	assert.Equal(t, "Uncategorised Blog", rssFeeds[3].Title)
+	assert.Nil(t, rssFeeds[3].Tags)
+	assert.Equal(t, "en-US", rssFeeds[3].Language)
+
+	_, err = parsers.ParseOpml(bytes.NewReader([]byte("<rss><channel></channel></rss>")))
+	assert.NotNil(t, err)
+}
+
+func TestOpmlExportRoundTrip(t *testing.T) {
+
+	fmt.Println("------------------------ TestOpmlExportRoundTrip ------------------------")
+
+	rssFeeds := []parsers.RssFeed{
+		{Title: "38 North", Url: "https://www.38north.org/feed/", SiteLink: "https://www.38north.org/", Tags: []string{"Asia/Korea"}},
+		{Title: "NK News", Url: "https://www.nknews.org/feed/", Tags: []string{"Asia/Korea", "Sanctions"}},
+		{Title: "Uncategorised Blog", Url: "https://example.com/rss.xml"},
+	}
+
+	opmlBytes, err := parsers.BuildOpml("Test Export", rssFeeds)
+	assert.Nil(t, err)
+	assert.Contains(t, string(opmlBytes), `<opml version="2.0">`)
+	assert.Contains(t, string(opmlBytes), `<title>Test Export</title>`)
+
+	// Feeds in multiple categories are listed once per category and merged back together on import:
+	reimportedFeeds, err := parsers.ParseOpml(bytes.NewReader(opmlBytes))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(reimportedFeeds))
+
+	tagsByUrl := map[string][]string{}
+	for _, rssFeed := range reimportedFeeds {
+		tagsByUrl[rssFeed.Url] = rssFeed.Tags
+	}
+	assert.ElementsMatch(t, []string{"Asia/Korea"}, tagsByUrl["https://www.38north.org/feed/"])
+	assert.ElementsMatch(t, []string{"Asia/Korea", "Sanctions"}, tagsByUrl["https://www.nknews.org/feed/"])
+	assert.Empty(t, tagsByUrl["https://example.com/rss.xml"])
+}
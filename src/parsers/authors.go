@@ -0,0 +1,546 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Titles that are dropped from the front of an author's name:
+var authorHonorifics = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "prof": true,
+	"professor": true, "sir": true, "dame": true, "rev": true, "hon": true, "amb": true,
+	"ambassador": true, "gen": true, "general": true, "col": true, "maj": true, "capt": true, "lt": true,
+}
+
+// Post-nominals that are dropped from the end of an author's name:
+var authorPostNominals = map[string]bool{
+	"phd": true, "md": true, "esq": true, "mba": true, "obe": true, "cbe": true, "ret": true,
+}
+
+// Words that mark a comma separated part of a byline as a role or affiliation rather than a person:
+var authorRoleWords = map[string]bool{
+	"fellow": true, "senior": true, "director": true, "editor": true, "analyst": true, "correspondent": true,
+	"contributor": true, "contributing": true, "staff": true, "writer": true, "reporter": true, "researcher": true,
+	"professor": true, "associate": true, "chief": true, "president": true, "founder": true, "manager": true,
+	"team": true, "desk": true, "bureau": true, "program": true, "programme": true, "institute": true,
+	"university": true, "center": true, "centre": true, "nonresident": true, "non-resident": true, "visiting": true,
+	"deputy": true, "head": true, "former": true, "guest": true, "special": true, "expert": true,
+}
+
+var authorNameSeparatorPattern = regexp.MustCompile(`(?i)\s+(?:and|&)\s+|\s*[;|]\s*`)
+var bylinePrefixPattern = regexp.MustCompile(`(?i)^\s*(?:written\s+)?by[:\s]+`)
+var authorNamePunctuationPattern = regexp.MustCompile(`[^\p{L}\p{N}\s'\-]`)
+
+// Splits a byline into the names of the individual authors it lists. Names joined with "and", "&", ";" or
+// "|" are separated and comma separated parts are treated as extra authors unless they look like a role or
+// affiliation (eg: "Jenny Town, Senior Fellow"). Each returned name has been cleaned with CleanAuthorName:
+func SplitAuthorNames(byline string) (names []string) {
+
+	byline = bylinePrefixPattern.ReplaceAllString(byline, "")
+	seenNames := map[string]bool{}
+
+	for _, bylinePart := range authorNameSeparatorPattern.Split(byline, -1) {
+		for i, commaPart := range strings.Split(bylinePart, ",") {
+
+			// Everything after the first comma is only a name if it is shaped like one:
+			if i > 0 && !looksLikePersonName(commaPart) {
+				continue
+			}
+
+			name := CleanAuthorName(commaPart)
+			normalisedName := NormaliseAuthorName(name)
+			if normalisedName == "" || seenNames[normalisedName] {
+				continue
+			}
+			seenNames[normalisedName] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Removes the honorifics, post-nominals and role suffixes from a single author name while keeping its
+// original capitalisation, eg: "Dr. Jenny Town, Senior Fellow" becomes "Jenny Town":
+func CleanAuthorName(name string) string {
+
+	name = bylinePrefixPattern.ReplaceAllString(name, "")
+	for _, roleSeparator := range []string{",", " - ", " – ", " | ", "("} {
+		if index := strings.Index(name, roleSeparator); index > 0 {
+			name = name[:index]
+		}
+	}
+
+	tokens := strings.Fields(name)
+	for len(tokens) > 1 && authorHonorifics[normaliseNameToken(tokens[0])] {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 1 && authorPostNominals[normaliseNameToken(tokens[len(tokens)-1])] {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// Produces the key used to compare author names: the cleaned name in lower case with punctuation removed
+// and whitespace collapsed, eg: "Dr. J. Town, Senior Fellow" becomes "j town":
+func NormaliseAuthorName(name string) string {
+
+	var normalisedTokens []string
+	for _, token := range strings.Fields(CleanAuthorName(name)) {
+		for _, subToken := range strings.Fields(strings.ReplaceAll(token, ".", " ")) {
+			normalisedToken := normaliseNameToken(subToken)
+			if normalisedToken != "" {
+				normalisedTokens = append(normalisedTokens, normalisedToken)
+			}
+		}
+	}
+
+	return strings.Join(normalisedTokens, " ")
+}
+
+// Picks the existing author that a name (and optional email) from a feed refers to out of a list of
+// candidate authors. An email match always wins. Otherwise an author with the same normalised name or
+// alias is used unless both have emails that differ, in which case they are different people with the
+// same name. Finally an abbreviated first name ("J. Town") is matched to a full one ("Jenny Town"), or the
+// other way around, but only when exactly one candidate fits. Returns false if no candidate matches:
+func MatchAuthorCandidate(name string, email string, candidates []RssAuthor) (RssAuthor, bool) {
+
+	normalisedName := NormaliseAuthorName(name)
+	email = strings.TrimSpace(email)
+
+	if email != "" {
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate.Email, email) {
+				return candidate, true
+			}
+		}
+	}
+
+	emailConflict := func(candidate RssAuthor) bool {
+		return email != "" && candidate.Email != "" && !strings.EqualFold(candidate.Email, email)
+	}
+
+	for _, candidate := range candidates {
+		if emailConflict(candidate) {
+			continue
+		}
+		if candidateNormalisedName(candidate) == normalisedName {
+			return candidate, true
+		}
+		for _, alias := range candidate.Aliases {
+			if alias == normalisedName {
+				return candidate, true
+			}
+		}
+	}
+
+	var initialMatches []RssAuthor
+	for _, candidate := range candidates {
+		if !emailConflict(candidate) && initialsCompatible(normalisedName, candidateNormalisedName(candidate)) {
+			initialMatches = append(initialMatches, candidate)
+		}
+	}
+	if len(initialMatches) == 1 {
+		return initialMatches[0], true
+	}
+
+	return RssAuthor{}, false
+}
+
+// Returns the surname and first initial of a normalised name, used to find candidates for initial matching:
+func authorNameKey(normalisedName string) (surname string, firstInitial string) {
+	tokens := strings.Fields(normalisedName)
+	if len(tokens) < 2 {
+		return normalisedName, ""
+	}
+	firstInitial = string([]rune(tokens[0])[0])
+	return tokens[len(tokens)-1], firstInitial
+}
+
+// Two names are compatible when they share a surname and one of them only gives the first initial of the
+// other's first name:
+func initialsCompatible(firstName string, secondName string) bool {
+
+	firstTokens := strings.Fields(firstName)
+	secondTokens := strings.Fields(secondName)
+	if len(firstTokens) < 2 || len(secondTokens) < 2 {
+		return false
+	}
+	if firstTokens[len(firstTokens)-1] != secondTokens[len(secondTokens)-1] {
+		return false
+	}
+
+	firstGiven := []rune(firstTokens[0])
+	secondGiven := []rune(secondTokens[0])
+	if len(firstGiven) != 1 && len(secondGiven) != 1 {
+		return false
+	}
+	return firstGiven[0] == secondGiven[0]
+}
+
+func candidateNormalisedName(candidate RssAuthor) string {
+	if candidate.NormalisedName != "" {
+		return candidate.NormalisedName
+	}
+	return NormaliseAuthorName(candidate.Name)
+}
+
+func normaliseNameToken(token string) string {
+	token = authorNamePunctuationPattern.ReplaceAllString(token, "")
+	return strings.Trim(strings.ToLower(token), "-'")
+}
+
+func looksLikePersonName(value string) bool {
+
+	tokens := strings.Fields(strings.TrimSpace(value))
+	if len(tokens) == 0 || len(tokens) > 5 {
+		return false
+	}
+
+	for _, token := range tokens {
+		if authorRoleWords[normaliseNameToken(token)] {
+			return false
+		}
+		// Names written in scripts without case (eg: Korean or Japanese) can't be checked for capitals:
+		firstRune := []rune(token)[0]
+		if unicode.IsLetter(firstRune) && unicode.IsLower(firstRune) {
+			return false
+		}
+	}
+	return true
+}
+
+// An author named in the byline of a newly created article:
+type articleAuthor struct {
+	ArticleId string
+	Name      string
+	Email     string
+}
+
+// Returns the existing authors that could be the author of each row of $names. The final choice between
+// the candidates is made by MatchAuthorCandidate:
+const authorCandidatesQuery = `
+UNWIND $names AS name
+MATCH (author:Author:Person)
+WHERE (name.email <> "" AND toLower(author.email) = toLower(name.email))
+	OR author.normalised_name = name.normalised_name
+	OR name.normalised_name IN coalesce(author.aliases, [])
+	OR (author.surname = name.surname AND author.first_initial = name.first_initial)
+	OR (author.normalised_name IS NULL AND author.name = name.name)
+RETURN name.index AS index, collect(author) AS authors
+`
+
+// Creates author nodes with the keys used to resolve them later:
+const createAuthorsQuery = `
+UNWIND $authors AS new_author
+CREATE (author:Author:Person {
+	name: new_author.name,
+	email: new_author.email,
+	normalised_name: new_author.normalised_name,
+	surname: new_author.surname,
+	first_initial: new_author.first_initial,
+	aliases: []
+})
+RETURN new_author.key AS key, elementId(author) AS id
+`
+
+// Connects authors to articles. Variants of an author's name that haven't been seen before are recorded as
+// aliases and a missing email is filled in:
+const connectAuthorsQuery = `
+UNWIND $connections AS connection
+MATCH (article:Article) WHERE elementId(article) = connection.article_id
+MATCH (author:Author:Person) WHERE elementId(author) = connection.author_id
+
+SET author.email = CASE WHEN coalesce(author.email, "") = "" THEN connection.email ELSE author.email END,
+	author.aliases = CASE
+		WHEN connection.normalised_name = coalesce(author.normalised_name, "") OR connection.normalised_name IN coalesce(author.aliases, [])
+		THEN coalesce(author.aliases, [])
+		ELSE coalesce(author.aliases, []) + connection.normalised_name
+	END
+MERGE (author)-[:WROTE]->(article)
+
+RETURN connection.index AS index
+`
+
+// Resolves the authors of a batch of newly created articles against the existing authors and writes them
+// as part of a feed's write transaction. Names that don't match an existing author are matched against the
+// other new names in the batch so that an author of several new articles is only created once. Returns a
+// summary for every author, in the same order:
+func writeArticleAuthors(articleAuthors []articleAuthor, ctx context.Context, tx neo4j.ManagedTransaction) (summaries []RssAuthorExtractionSummary, err error) {
+
+	summaries = make([]RssAuthorExtractionSummary, len(articleAuthors))
+	if len(articleAuthors) == 0 {
+		return summaries, nil
+	}
+
+	// 1) Finding the candidate existing authors for every name:
+	var nameRows []map[string]any
+	for i, author := range articleAuthors {
+		normalisedName := NormaliseAuthorName(author.Name)
+		surname, firstInitial := authorNameKey(normalisedName)
+		nameRows = append(nameRows, map[string]any{
+			"index":           i,
+			"name":            author.Name,
+			"email":           strings.TrimSpace(author.Email),
+			"normalised_name": normalisedName,
+			"surname":         surname,
+			"first_initial":   firstInitial,
+		})
+	}
+
+	candidates := make([][]RssAuthor, len(articleAuthors))
+	err = runBatchedQuery(authorCandidatesQuery, "names", nameRows, nil, ctx, tx, func(record *neo4j.Record) error {
+		index, _, err := neo4j.GetRecordValue[int64](record, "index")
+		if err != nil {
+			return err
+		}
+		authorNodes, _, err := neo4j.GetRecordValue[[]any](record, "authors")
+		if err != nil {
+			return err
+		}
+		for _, authorNode := range authorNodes {
+			if node, ok := authorNode.(neo4j.Node); ok {
+				candidates[index] = append(candidates[index], RssAuthorFromNode(node))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return summaries, err
+	}
+
+	// 2) Picking the existing author for every name, or the new author that will be created for it. New
+	// authors are given a temporary key in place of their id until they have been created:
+	var newAuthors []RssAuthor
+	var newAuthorRows []map[string]any
+	resolvedAuthors := make([]RssAuthor, len(articleAuthors))
+
+	for i, author := range articleAuthors {
+
+		summaries[i].Name = author.Name
+
+		if existingAuthor, ok := MatchAuthorCandidate(author.Name, author.Email, candidates[i]); ok {
+			resolvedAuthors[i] = existingAuthor
+			summaries[i].Name = existingAuthor.Name
+			summaries[i].Status = StatusLinked
+			summaries[i].Message = "Existing author detected - adding connection to an existing Author"
+			continue
+		}
+
+		// An author that is new to the database but was already named on another item in the feed:
+		if newAuthor, ok := MatchAuthorCandidate(author.Name, author.Email, newAuthors); ok {
+			resolvedAuthors[i] = newAuthor
+			summaries[i].Name = newAuthor.Name
+			summaries[i].Status = StatusLinked
+			summaries[i].Message = "Author created for another Entry in this feed - adding connection to the new Author"
+			continue
+		}
+
+		summaries[i].Status = StatusCreated
+		summaries[i].Message = "New author detected - Creating a new author and connecting it to article"
+
+		normalisedName := NormaliseAuthorName(author.Name)
+		surname, firstInitial := authorNameKey(normalisedName)
+		newAuthor := RssAuthor{
+			Id:             fmt.Sprintf("new:%d", len(newAuthors)),
+			Name:           author.Name,
+			Email:          strings.TrimSpace(author.Email),
+			NormalisedName: normalisedName,
+		}
+		newAuthors = append(newAuthors, newAuthor)
+		newAuthorRows = append(newAuthorRows, map[string]any{
+			"key":             newAuthor.Id,
+			"name":            newAuthor.Name,
+			"email":           newAuthor.Email,
+			"normalised_name": normalisedName,
+			"surname":         surname,
+			"first_initial":   firstInitial,
+		})
+		resolvedAuthors[i] = newAuthor
+	}
+
+	// 3) Creating the new authors and swapping their temporary keys for their ids:
+	createdIds := map[string]string{}
+	err = runBatchedQuery(createAuthorsQuery, "authors", newAuthorRows, nil, ctx, tx, func(record *neo4j.Record) error {
+		key, _, err := neo4j.GetRecordValue[string](record, "key")
+		if err != nil {
+			return err
+		}
+		id, _, err := neo4j.GetRecordValue[string](record, "id")
+		if err != nil {
+			return err
+		}
+		createdIds[key] = id
+		return nil
+	})
+	if err != nil {
+		return summaries, err
+	}
+
+	// 4) Connecting every author to their article:
+	var connectionRows []map[string]any
+	for i, author := range articleAuthors {
+		authorId := resolvedAuthors[i].Id
+		if createdId, ok := createdIds[authorId]; ok {
+			authorId = createdId
+		}
+		summaries[i].Id = authorId
+		connectionRows = append(connectionRows, map[string]any{
+			"index":           i,
+			"article_id":      author.ArticleId,
+			"author_id":       authorId,
+			"email":           strings.TrimSpace(author.Email),
+			"normalised_name": NormaliseAuthorName(author.Name),
+		})
+	}
+
+	connected := 0
+	err = runBatchedQuery(connectAuthorsQuery, "connections", connectionRows, nil, ctx, tx, func(record *neo4j.Record) error {
+		connected++
+		return nil
+	})
+	if err != nil {
+		return summaries, err
+	}
+	if connected != len(connectionRows) {
+		return summaries, fmt.Errorf("connected %d of %d authors to their articles", connected, len(connectionRows))
+	}
+
+	return summaries, nil
+}
+
+// Merges the source author into the target author. Every WROTE relationship of the source is moved to the
+// target, the source's names are kept as aliases of the target, its email is used if the target doesn't
+// have one, and the source node is deleted:
+func MergeAuthors(sourceId string, targetId string, ctx context.Context, driver neo4j.DriverWithContext) (author RssAuthor, err error) {
+
+	if sourceId == targetId {
+		return author, fmt.Errorf("cannot merge author %s into itself", sourceId)
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (source:Author:Person) WHERE elementId(source) = $source_id
+		MATCH (target:Author:Person) WHERE elementId(target) = $target_id
+
+		OPTIONAL MATCH (source)-[wrote:WROTE]->(article:Article)
+		FOREACH (a IN CASE WHEN article IS NULL THEN [] ELSE [article] END |
+			MERGE (target)-[:WROTE]->(a)
+		)
+		DELETE wrote
+
+		WITH DISTINCT source, target
+		SET target.aliases = reduce(
+				aliases = [],
+				alias IN coalesce(target.aliases, []) + coalesce(source.aliases, []) + [coalesce(source.normalised_name, toLower(source.name))] |
+				CASE WHEN alias = coalesce(target.normalised_name, "") OR alias IN aliases THEN aliases ELSE aliases + alias END
+			),
+			target.email = CASE WHEN coalesce(target.email, "") = "" THEN source.email ELSE target.email END
+		WITH source, target
+		DETACH DELETE source
+
+		RETURN target AS author
+		`,
+		map[string]any{"source_id": sourceId, "target_id": targetId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return author, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find both authors %s and %s to merge", sourceId, targetId)
+		return author, err
+	}
+
+	authorNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "author")
+	if err != nil {
+		return author, err
+	}
+
+	return RssAuthorFromNode(authorNode), nil
+}
+
+// Querying the database for an author along with every article they wrote, newest first:
+func GetAuthorWithArticles(id string, ctx context.Context, driver neo4j.DriverWithContext) (author RssAuthor, articles []RssEntry, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (author:Author:Person) WHERE elementId(author) = $id
+		OPTIONAL MATCH (author)-[:WROTE]->(article:Article)
+		WITH author, article
+		ORDER BY article.date_posted DESC
+		RETURN author, collect(article) AS articles
+		`,
+		map[string]any{"id": id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return author, articles, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find author %s in the database", id)
+		return author, articles, err
+	}
+
+	authorNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "author")
+	if err != nil {
+		return author, articles, err
+	}
+	author = RssAuthorFromNode(authorNode)
+
+	articleNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "articles")
+	if err != nil {
+		return author, articles, err
+	}
+	articles = []RssEntry{}
+	for _, articleNode := range articleNodes {
+		if node, ok := articleNode.(neo4j.Node); ok {
+			articles = append(articles, RssEntryFromNode(node))
+		}
+	}
+
+	return author, articles, nil
+}
+
+// Converts an Author:Person node into an RssAuthor struct:
+func RssAuthorFromNode(node neo4j.Node) (author RssAuthor) {
+
+	nodeProps := node.GetProperties()
+
+	author.Id = node.ElementId
+
+	if name, ok := nodeProps["name"].(string); ok {
+		author.Name = name
+	}
+	if email, ok := nodeProps["email"].(string); ok {
+		author.Email = email
+	}
+	if normalisedName, ok := nodeProps["normalised_name"].(string); ok {
+		author.NormalisedName = normalisedName
+	} else {
+		author.NormalisedName = NormaliseAuthorName(author.Name)
+	}
+
+	author.Aliases = []string{}
+	if aliases, ok := nodeProps["aliases"].([]any); ok {
+		for _, alias := range aliases {
+			if aliasName, ok := alias.(string); ok {
+				author.Aliases = append(author.Aliases, aliasName)
+			}
+		}
+	}
+
+	return author
+}
@@ -0,0 +1,916 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Where a backfill finds the past articles of a source:
+const (
+	BackfillFromSitemap = "sitemap"
+	BackfillFromArchive = "archive"
+)
+
+var (
+	ErrBackfillRunning = errors.New("a backfill is already running for the source")
+	ErrInvalidBackfill = errors.New("invalid backfill")
+)
+
+// Number of urls that are looked up and written together. Progress is saved after every batch:
+const backfillBatchSize = 25
+
+// Only the most recent errors of a backfill are kept on its node:
+const maxBackfillErrors = 100
+
+// What a backfill should walk and which of the urls it finds are articles. A backfill walks the sitemap
+// at SitemapUrl, or the paginated archive at ArchiveUrl (where "{page}" is replaced by the page number),
+// or if neither is set the sitemaps the source's site lists in its robots.txt. Archive pages link to much
+// more than articles so they need a UrlPattern, for sitemaps it is optional. Urls last modified or
+// published before Since are skipped:
+type BackfillOptions struct {
+	SitemapUrl  string    `json:"sitemap_url"`
+	ArchiveUrl  string    `json:"archive_url"`
+	UrlPattern  string    `json:"url_pattern"`
+	Since       time.Time `json:"since"`
+	CaptureHtml bool      `json:"capture_html"`
+	StartPage   int       `json:"start_page"`
+	MaxPages    int       `json:"max_pages"`
+	MaxUrls     int       `json:"max_urls"`
+}
+
+type BackfillCounts struct {
+	SitemapsFetched  int `json:"sitemaps_fetched"`
+	PagesFetched     int `json:"pages_fetched"`
+	UrlsSeen         int `json:"urls_seen"`
+	UrlsFiltered     int `json:"urls_filtered"`
+	UrlsFailed       int `json:"urls_failed"`
+	ArticlesCreated  int `json:"articles_created"`
+	ArticlesExisting int `json:"articles_existing"`
+	PagesCaptured    int `json:"pages_captured"`
+	CapturesFailed   int `json:"captures_failed"`
+}
+
+// A backfill of a source's past articles, stored as a Backfill_Job node connected to the source. The node
+// holds the job's progress: the sitemaps still to be walked and how far into the first of them the job
+// got, or the next archive page. A job that stopped (the server was restarted or a request or write
+// failed) continues from there when it is resumed:
+type BackfillJob struct {
+	Id              string           `json:"id"`
+	SourceId        string           `json:"source_id"`
+	Mode            string           `json:"mode"`
+	Options         BackfillOptions  `json:"options"`
+	Status          ExtractionStatus `json:"status"`
+	Message         string           `json:"message"`
+	Error           string           `json:"error"`
+	PendingSitemaps []string         `json:"pending_sitemaps"`
+	SitemapOffset   int              `json:"sitemap_offset"`
+	NextPage        int              `json:"next_page"`
+	Counts          BackfillCounts   `json:"counts"`
+	Errors          []string         `json:"errors"`
+	StartTime       time.Time        `json:"start_time"`
+	UpdatedTime     time.Time        `json:"updated_time"`
+	EndTime         time.Time        `json:"end_time"`
+}
+
+// The sources with a backfill running in this process. Only one backfill runs per source at a time:
+var runningBackfills = struct {
+	sync.Mutex
+	sources map[string]bool
+}{sources: map[string]bool{}}
+
+func claimBackfillSource(sourceId string) bool {
+	runningBackfills.Lock()
+	defer runningBackfills.Unlock()
+	if runningBackfills.sources[sourceId] {
+		return false
+	}
+	runningBackfills.sources[sourceId] = true
+	return true
+}
+
+func releaseBackfillSource(sourceId string) {
+	runningBackfills.Lock()
+	defer runningBackfills.Unlock()
+	delete(runningBackfills.sources, sourceId)
+}
+
+// Checks a backfill's options and works out which mode it runs in:
+func validateBackfillOptions(options BackfillOptions) (mode string, err error) {
+
+	if options.SitemapUrl != "" && options.ArchiveUrl != "" {
+		return "", fmt.Errorf("%w: set either a sitemap_url or an archive_url, not both", ErrInvalidBackfill)
+	}
+	if options.StartPage < 0 || options.MaxPages < 0 || options.MaxUrls < 0 {
+		return "", fmt.Errorf("%w: start_page, max_pages and max_urls can't be negative", ErrInvalidBackfill)
+	}
+	if _, err = regexp.Compile(options.UrlPattern); err != nil {
+		return "", fmt.Errorf("%w: url_pattern is not a valid regular expression: %v", ErrInvalidBackfill, err)
+	}
+	if options.CaptureHtml && PageCaptures.Client == nil {
+		return "", fmt.Errorf("%w: capture_html needs object storage, which is not configured", ErrInvalidBackfill)
+	}
+
+	if options.ArchiveUrl == "" {
+		return BackfillFromSitemap, nil
+	}
+	if !strings.Contains(options.ArchiveUrl, archivePagePlaceholder) {
+		return "", fmt.Errorf("%w: archive_url needs a %s placeholder for the page number", ErrInvalidBackfill, archivePagePlaceholder)
+	}
+	if options.UrlPattern == "" {
+		return "", fmt.Errorf("%w: archive backfills need a url_pattern that matches the source's article urls", ErrInvalidBackfill)
+	}
+	return BackfillFromArchive, nil
+}
+
+// Creates a backfill job for a source and starts it in the background. Fails with ErrInvalidBackfill if
+// the options don't make sense and with ErrBackfillRunning if the source already has a backfill running.
+// Backfills are only for rss feeds, as they read the sitemaps and archive pages of the feed's site, so
+// every backfill query matches Rss_Feed:Source nodes:
+func StartBackfillJob(sourceId string, options BackfillOptions, ctx context.Context, driver neo4j.DriverWithContext) (job BackfillJob, err error) {
+
+	job.Mode, err = validateBackfillOptions(options)
+	if err != nil {
+		return job, err
+	}
+	if !claimBackfillSource(sourceId) {
+		return job, ErrBackfillRunning
+	}
+
+	job.SourceId = sourceId
+	job.Options = options
+	job.Status = StatusRunning
+	job.Message = "The backfill is running"
+	job.StartTime = time.Now().UTC()
+	job.NextPage = options.StartPage
+	if job.NextPage == 0 {
+		job.NextPage = 1
+	}
+	job.PendingSitemaps = []string{}
+	if options.SitemapUrl != "" {
+		job.PendingSitemaps = []string{options.SitemapUrl}
+	}
+	job.Errors = []string{}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (source:Rss_Feed:Source) WHERE elementId(source) = $source_id
+		CREATE (job:Backfill_Job)
+		SET job = $job
+		CREATE (source)-[:HAS_BACKFILL_JOB]->(job)
+		RETURN source, elementId(job) AS id`,
+		map[string]any{"source_id": sourceId, "job": backfillJobProperties(job)},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		releaseBackfillSource(sourceId)
+		return job, err
+	}
+	if len(result.Records) == 0 {
+		releaseBackfillSource(sourceId)
+		return job, fmt.Errorf("unable to find rss feed %s in the database", sourceId)
+	}
+
+	sourceNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "source")
+	if err != nil {
+		releaseBackfillSource(sourceId)
+		return job, err
+	}
+	job.Id, _, err = neo4j.GetRecordValue[string](result.Records[0], "id")
+	if err != nil {
+		releaseBackfillSource(sourceId)
+		return job, err
+	}
+
+	go runBackfillJob(job, RssFeedFromNode(sourceNode), ctx, driver)
+
+	return job, nil
+}
+
+// Continues a backfill that stopped before it finished from where it got to:
+func ResumeBackfillJob(id string, ctx context.Context, driver neo4j.DriverWithContext) (job BackfillJob, err error) {
+
+	job, rssFeed, err := getBackfillJobWithSource(id, ctx, driver)
+	if err != nil {
+		return job, err
+	}
+	if job.Status == StatusCompleted {
+		return job, fmt.Errorf("%w: the backfill has already completed", ErrInvalidBackfill)
+	}
+	if !claimBackfillSource(job.SourceId) {
+		return job, ErrBackfillRunning
+	}
+
+	job.Status = StatusRunning
+	job.Message = "The backfill is running"
+	job.Error = ""
+	job.EndTime = time.Time{}
+	if err = saveBackfillJob(&job, ctx, driver); err != nil {
+		releaseBackfillSource(job.SourceId)
+		return job, err
+	}
+
+	go runBackfillJob(job, rssFeed, ctx, driver)
+
+	return job, nil
+}
+
+// Resumes the backfills that were still running when the server last stopped. Called once on start up.
+// A job that can't be resumed is logged and left as it is so that it doesn't hold up the others:
+func ResumeInterruptedBackfillJobs(ctx context.Context, driver neo4j.DriverWithContext) (jobs []BackfillJob, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (:Rss_Feed:Source)-[:HAS_BACKFILL_JOB]->(job:Backfill_Job {status: $status})
+		RETURN elementId(job) AS id`,
+		map[string]any{"status": string(StatusRunning)},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return jobs, err
+	}
+
+	for _, record := range result.Records {
+		id, _, err := neo4j.GetRecordValue[string](record, "id")
+		if err != nil {
+			fmt.Println("Unable to read the id of an interrupted backfill:", err)
+			continue
+		}
+		job, err := ResumeBackfillJob(id, ctx, driver)
+		if err != nil {
+			fmt.Println("Unable to resume backfill", id, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func runBackfillJob(job BackfillJob, rssFeed RssFeed, ctx context.Context, driver neo4j.DriverWithContext) {
+
+	defer releaseBackfillSource(job.SourceId)
+
+	// Sources we have permission to archive skip the robots.txt check:
+	requestCtx := ctx
+	if rssFeed.IgnoreRobots {
+		requestCtx = WithIgnoreRobots(ctx)
+	}
+
+	var status ExtractionStatus
+	var err error
+	if job.Mode == BackfillFromArchive {
+		status, err = backfillFromArchive(&job, rssFeed, requestCtx, ctx, driver)
+	} else {
+		status, err = backfillFromSitemaps(&job, rssFeed, requestCtx, ctx, driver)
+	}
+
+	job.EndTime = time.Now().UTC()
+	if err != nil {
+		job.Status = status
+		job.Error = err.Error()
+		job.Message = "The backfill stopped before it finished. Resume it to continue from where it stopped"
+	} else {
+		job.Status = StatusCompleted
+		job.Message = fmt.Sprintf(
+			"Backfill complete. Created %d articles, %d already existed",
+			job.Counts.ArticlesCreated,
+			job.Counts.ArticlesExisting,
+		)
+		if backfillUrlLimitReached(job) {
+			job.Message += fmt.Sprintf(". Stopped after the %d url limit", job.Options.MaxUrls)
+		}
+	}
+
+	if saveErr := saveBackfillJob(&job, ctx, driver); saveErr != nil {
+		fmt.Println("Unable to record the outcome of backfill", job.Id, saveErr)
+	}
+	fmt.Printf("Backfill of %s finished with status %s. %s \n", rssFeed.Title, job.Status, job.Message)
+}
+
+// Walks the source's sitemaps. Sitemap indexes add their sitemaps to the end of the queue. Sitemaps that
+// can't be fetched or parsed are recorded as errors and skipped:
+func backfillFromSitemaps(job *BackfillJob, rssFeed RssFeed, requestCtx context.Context, ctx context.Context, driver neo4j.DriverWithContext) (ExtractionStatus, error) {
+
+	pattern := regexp.MustCompile(job.Options.UrlPattern)
+
+	if len(job.PendingSitemaps) == 0 {
+		if job.Counts.SitemapsFetched > 0 {
+			return StatusCompleted, nil
+		}
+		siteUrl := rssFeed.SiteLink
+		if siteUrl == "" {
+			siteUrl = rssFeed.Url
+		}
+		sitemapUrls, err := FindSitemapUrls(requestCtx, siteUrl)
+		if err != nil {
+			return StatusErrorFetch, err
+		}
+		job.PendingSitemaps = sitemapUrls
+		if err = saveBackfillJob(job, ctx, driver); err != nil {
+			return StatusErrorDb, err
+		}
+	}
+
+	// Guards against sitemap indexes that list themselves or each other:
+	queued := map[string]bool{}
+	for _, sitemapUrl := range job.PendingSitemaps {
+		queued[sitemapUrl] = true
+	}
+
+	for len(job.PendingSitemaps) > 0 && !backfillUrlLimitReached(*job) {
+
+		sitemapUrl := job.PendingSitemaps[0]
+		sitemap, err := FetchSitemap(requestCtx, sitemapUrl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return StatusErrorFetch, ctx.Err()
+			}
+			recordBackfillError(job, sitemapUrl, err)
+		} else {
+			job.Counts.SitemapsFetched++
+		}
+
+		for _, childSitemap := range sitemap.Sitemaps {
+			if queued[childSitemap.Url] || publishedBefore(childSitemap, job.Options.Since) {
+				continue
+			}
+			queued[childSitemap.Url] = true
+			job.PendingSitemaps = append(job.PendingSitemaps, childSitemap.Url)
+		}
+
+		// The offset counts the urls that passed the filters so it only has to be stable while the
+		// sitemap doesn't change:
+		var candidates []SitemapUrl
+		filtered := 0
+		for _, sitemapUrl := range sitemap.Urls {
+			if publishedBefore(sitemapUrl, job.Options.Since) || (job.Options.UrlPattern != "" && !pattern.MatchString(sitemapUrl.Url)) {
+				filtered++
+				continue
+			}
+			candidates = append(candidates, sitemapUrl)
+		}
+		if job.SitemapOffset == 0 {
+			job.Counts.UrlsFiltered += filtered
+		}
+
+		for job.SitemapOffset < len(candidates) && !backfillUrlLimitReached(*job) {
+			end := job.SitemapOffset + backfillBatchSize
+			if end > len(candidates) {
+				end = len(candidates)
+			}
+			if job.Options.MaxUrls > 0 && end-job.SitemapOffset > job.Options.MaxUrls-job.Counts.UrlsSeen {
+				end = job.SitemapOffset + job.Options.MaxUrls - job.Counts.UrlsSeen
+			}
+
+			status, err := writeBackfillBatch(job, rssFeed, candidates[job.SitemapOffset:end], requestCtx, ctx, driver)
+			if err != nil {
+				return status, err
+			}
+			job.SitemapOffset = end
+			if err = saveBackfillJob(job, ctx, driver); err != nil {
+				return StatusErrorDb, err
+			}
+		}
+		if backfillUrlLimitReached(*job) && job.SitemapOffset < len(candidates) {
+			break
+		}
+
+		job.PendingSitemaps = job.PendingSitemaps[1:]
+		job.SitemapOffset = 0
+		if err = saveBackfillJob(job, ctx, driver); err != nil {
+			return StatusErrorDb, err
+		}
+	}
+
+	return StatusCompleted, nil
+}
+
+// Walks the pages of the source's archive until a page is missing, a page has no article links that
+// haven't been seen already (archives that show their last page for any page number past the end) or
+// MaxPages pages have been fetched:
+func backfillFromArchive(job *BackfillJob, rssFeed RssFeed, requestCtx context.Context, ctx context.Context, driver neo4j.DriverWithContext) (ExtractionStatus, error) {
+
+	pattern := regexp.MustCompile(job.Options.UrlPattern)
+	seenUrls := map[string]bool{}
+
+	for job.Options.MaxPages == 0 || job.Counts.PagesFetched < job.Options.MaxPages {
+
+		if backfillUrlLimitReached(*job) {
+			return StatusCompleted, nil
+		}
+
+		pageUrl := ArchivePageUrl(job.Options.ArchiveUrl, job.NextPage)
+		resp, err := DefaultFetcher.Get(requestCtx, pageUrl)
+		if err != nil {
+			if errors.Is(err, ErrDisallowedByRobots) {
+				return StatusErrorRobots, err
+			}
+			return StatusErrorFetch, err
+		}
+		if isMissingPageStatus(resp.StatusCode) {
+			resp.Body.Close()
+			return StatusCompleted, nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return StatusErrorFetch, fmt.Errorf("request to archive page %s returned status code: %d", pageUrl, resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return StatusErrorFetch, err
+		}
+		body, _, err = DecodeToUtf8(body, resp.Header.Get("Content-Type"))
+		if err != nil {
+			return StatusErrorParse, err
+		}
+
+		links, err := ExtractArchiveLinks(resp.Request.URL.String(), body, pattern)
+		if err != nil {
+			return StatusErrorParse, err
+		}
+		var candidates []SitemapUrl
+		for _, link := range links {
+			if canonicalUrl := CanonicalizeUrl(link); !seenUrls[canonicalUrl] {
+				seenUrls[canonicalUrl] = true
+				candidates = append(candidates, SitemapUrl{Url: link})
+			}
+		}
+		if len(candidates) == 0 {
+			return StatusCompleted, nil
+		}
+		job.Counts.PagesFetched++
+
+		for start := 0; start < len(candidates) && !backfillUrlLimitReached(*job); start += backfillBatchSize {
+			end := start + backfillBatchSize
+			if end > len(candidates) {
+				end = len(candidates)
+			}
+			if job.Options.MaxUrls > 0 && end-start > job.Options.MaxUrls-job.Counts.UrlsSeen {
+				end = start + job.Options.MaxUrls - job.Counts.UrlsSeen
+			}
+			status, err := writeBackfillBatch(job, rssFeed, candidates[start:end], requestCtx, ctx, driver)
+			if err != nil {
+				return status, err
+			}
+		}
+
+		job.NextPage++
+		if err = saveBackfillJob(job, ctx, driver); err != nil {
+			return StatusErrorDb, err
+		}
+	}
+
+	return StatusCompleted, nil
+}
+
+// A url found by a backfill along with its page, if it was fetched:
+type backfillArticle struct {
+	id       string
+	rssEntry RssEntry
+	page     *ArticlePage
+}
+
+// Creates the articles for a batch of urls. Urls are matched against the existing articles in the same
+// way as feed items (see existingArticlesQuery) so a backfill never duplicates an article that was
+// ingested from the feed or any other source, and vice versa. Pages are fetched for urls the sitemap
+// doesn't give a title for and when their html is being captured, in which case existing articles that
+// haven't been captured yet are captured as well. Urls that fail are recorded on the job and skipped, only
+// a failed write stops it. The batch is only counted once it has been written, so that a batch retried
+// when the job is resumed isn't counted twice:
+func writeBackfillBatch(job *BackfillJob, rssFeed RssFeed, candidates []SitemapUrl, requestCtx context.Context, ctx context.Context, driver neo4j.DriverWithContext) (ExtractionStatus, error) {
+
+	countsBefore := job.Counts
+
+	// 1) Finding the urls that have already been ingested:
+	var lookupRows []map[string]any
+	for i, candidate := range candidates {
+		lookupRows = append(lookupRows, existingRssArticleParams(i, "", candidate.Url))
+	}
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		existingArticlesQuery,
+		map[string]any{"items": lookupRows, "source_id": rssFeed.Id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return StatusErrorDb, err
+	}
+	existingEntries := map[int]RssEntry{}
+	for _, record := range result.Records {
+		index, _, err := neo4j.GetRecordValue[int64](record, "index")
+		if err != nil {
+			return StatusErrorDb, err
+		}
+		articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "article")
+		if err != nil {
+			return StatusErrorDb, err
+		}
+		existingEntries[int(index)] = RssEntryFromNode(articleNode)
+	}
+
+	// 2) Building the new articles from the sitemap and their pages:
+	var newArticles []backfillArticle
+	var captures []backfillArticle
+	seenUrls := map[string]bool{}
+	for i, candidate := range candidates {
+
+		canonicalUrl := CanonicalizeUrl(candidate.Url)
+		if seenUrls[canonicalUrl] {
+			job.Counts.ArticlesExisting++
+			continue
+		}
+		seenUrls[canonicalUrl] = true
+
+		if existingEntry, exists := existingEntries[i]; exists {
+			job.Counts.ArticlesExisting++
+			if job.Options.CaptureHtml && existingEntry.InStorage == 0 {
+				page, err := FetchArticlePage(requestCtx, candidate.Url)
+				if err != nil {
+					job.Counts.CapturesFailed++
+					recordBackfillError(job, candidate.Url, err)
+					continue
+				}
+				captures = append(captures, backfillArticle{id: existingEntry.Id, page: &page})
+			}
+			continue
+		}
+
+		article := backfillArticle{rssEntry: rssEntryFromSitemapUrl(candidate)}
+		if article.rssEntry.Title == "" || job.Options.CaptureHtml {
+			page, err := FetchArticlePage(requestCtx, candidate.Url)
+			if err != nil {
+				job.Counts.UrlsFailed++
+				recordBackfillError(job, candidate.Url, err)
+				continue
+			}
+			article.page = &page
+			mergeArticlePage(&article.rssEntry, page)
+		}
+		if publishedBefore(SitemapUrl{DatePublished: article.rssEntry.DatePosted}, job.Options.Since) {
+			job.Counts.UrlsFiltered++
+			continue
+		}
+		newArticles = append(newArticles, article)
+	}
+
+	// 3) Creating the new articles and their authors in a single transaction:
+	if len(newArticles) > 0 {
+		session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+		defer session.Close(ctx)
+
+		var authorSummaries []RssAuthorExtractionSummary
+		_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			var txErr error
+			authorSummaries, txErr = writeBackfillArticles(job.Id, rssFeed, newArticles, ctx, tx)
+			return nil, txErr
+		})
+		if err != nil {
+			job.Counts = countsBefore
+			return StatusErrorDb, err
+		}
+		job.Counts.ArticlesCreated += len(newArticles)
+		for _, authorSummary := range authorSummaries {
+			if authorSummary.Error != "" {
+				recordBackfillError(job, authorSummary.Name, errors.New(authorSummary.Error))
+			}
+		}
+		for _, article := range newArticles {
+			if err := extractNewArticleEntities(article.id, article.rssEntry, article.page, ctx, driver); err != nil {
+				recordBackfillError(job, article.rssEntry.Url, err)
+			}
+			if err := tagNewArticleTopics(article.id, article.rssEntry, article.page, ctx, driver); err != nil {
+				recordBackfillError(job, article.rssEntry.Url, err)
+			}
+		}
+		if job.Options.CaptureHtml {
+			captures = append(captures, newArticles...)
+		}
+	}
+
+	job.Counts.UrlsSeen += len(candidates)
+
+	// 4) Capturing the pages. A page that can't be stored is retried by the next backfill with capture_html:
+	for _, article := range captures {
+		if _, err := CaptureArticlePage(rssFeed.Title, article.id, *article.page, ctx, driver); err != nil {
+			job.Counts.CapturesFailed++
+			recordBackfillError(job, article.page.Url, err)
+			continue
+		}
+		job.Counts.PagesCaptured++
+	}
+
+	return StatusCompleted, nil
+}
+
+// The body of the write transaction that creates a batch of backfilled articles. Sets the id of every
+// article and returns the summaries of their authors:
+func writeBackfillArticles(jobId string, rssFeed RssFeed, articles []backfillArticle, ctx context.Context, tx neo4j.ManagedTransaction) (authorSummaries []RssAuthorExtractionSummary, err error) {
+
+	var createRows []map[string]any
+	for i := range articles {
+		articles[i].id = ""
+		createRows = append(createRows, rssArticleCreateParams(i, articles[i].rssEntry))
+	}
+
+	created := 0
+	err = runBatchedQuery(
+		createRssArticlesQuery,
+		"articles",
+		createRows,
+		map[string]any{"source_id": rssFeed.Id, "run_id": jobId, "downloaded_date": time.Now().UTC()},
+		ctx,
+		tx,
+		func(record *neo4j.Record) error {
+			index, _, err := neo4j.GetRecordValue[int64](record, "index")
+			if err != nil {
+				return err
+			}
+			articles[index].id, _, err = neo4j.GetRecordValue[string](record, "id")
+			created++
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if created != len(createRows) {
+		return nil, fmt.Errorf("created %d of %d backfilled articles, the source %s may have been removed", created, len(createRows), rssFeed.Title)
+	}
+
+	var articleAuthors []articleAuthor
+	for _, article := range articles {
+		for _, authorName := range SplitAuthorNames(article.rssEntry.Creator) {
+			articleAuthors = append(articleAuthors, articleAuthor{ArticleId: article.id, Name: authorName})
+		}
+	}
+
+	return writeArticleAuthors(articleAuthors, ctx, tx)
+}
+
+// Builds the article for a sitemap url. Google News sitemaps give the title, publication date and
+// keywords, which are stored as the article's categories:
+func rssEntryFromSitemapUrl(sitemapUrl SitemapUrl) (rssEntry RssEntry) {
+
+	rssEntry.Url = sitemapUrl.Url
+	rssEntry.CanonicalUrl = CanonicalizeUrl(sitemapUrl.Url)
+	rssEntry.Title = sitemapUrl.Title
+	rssEntry.DatePosted = sitemapUrl.DatePublished
+	rssEntry.DateUpdated = sitemapUrl.LastModified
+	rssEntry.Categories = nonNilStrings(sitemapUrl.Keywords)
+	rssEntry.Media = []RssMedia{}
+	for _, imageUrl := range sitemapUrl.ImageUrls {
+		if rssEntry.ImageUrl == "" {
+			rssEntry.ImageUrl = imageUrl
+		}
+		rssEntry.Media = append(rssEntry.Media, RssMedia{Url: imageUrl, Medium: "image", Source: "sitemap"})
+	}
+
+	return rssEntry
+}
+
+// Fills in the fields of a backfilled article that the sitemap didn't have from its page (see
+// MergeEntryMetadata):
+func mergeArticlePage(rssEntry *RssEntry, page ArticlePage) {
+
+	MergeEntryMetadata(rssEntry, page.Metadata)
+	if rssEntry.Title == "" {
+		rssEntry.Title = rssEntry.Url
+	}
+	rssEntry.Charset = page.Charset
+}
+
+// Reports whether a sitemap url was last changed or published before the since date. Urls without dates
+// are never skipped:
+func publishedBefore(sitemapUrl SitemapUrl, since time.Time) bool {
+
+	if since.IsZero() {
+		return false
+	}
+	latest := sitemapUrl.LastModified
+	if sitemapUrl.DatePublished.After(latest) {
+		latest = sitemapUrl.DatePublished
+	}
+
+	return !latest.IsZero() && latest.Before(since)
+}
+
+func backfillUrlLimitReached(job BackfillJob) bool {
+	return job.Options.MaxUrls > 0 && job.Counts.UrlsSeen >= job.Options.MaxUrls
+}
+
+func recordBackfillError(job *BackfillJob, url string, err error) {
+	job.Errors = append(job.Errors, fmt.Sprintf("%s: %s", url, err.Error()))
+	if len(job.Errors) > maxBackfillErrors {
+		job.Errors = job.Errors[len(job.Errors)-maxBackfillErrors:]
+	}
+}
+
+// The properties of a Backfill_Job node:
+func backfillJobProperties(job BackfillJob) map[string]any {
+	return map[string]any{
+		"mode":              job.Mode,
+		"sitemap_url":       job.Options.SitemapUrl,
+		"archive_url":       job.Options.ArchiveUrl,
+		"url_pattern":       job.Options.UrlPattern,
+		"since":             neo4jDateTime(job.Options.Since),
+		"capture_html":      job.Options.CaptureHtml,
+		"start_page":        job.Options.StartPage,
+		"max_pages":         job.Options.MaxPages,
+		"max_urls":          job.Options.MaxUrls,
+		"status":            string(job.Status),
+		"message":           job.Message,
+		"error":             job.Error,
+		"pending_sitemaps":  nonNilStrings(job.PendingSitemaps),
+		"sitemap_offset":    job.SitemapOffset,
+		"next_page":         job.NextPage,
+		"sitemaps_fetched":  job.Counts.SitemapsFetched,
+		"pages_fetched":     job.Counts.PagesFetched,
+		"urls_seen":         job.Counts.UrlsSeen,
+		"urls_filtered":     job.Counts.UrlsFiltered,
+		"urls_failed":       job.Counts.UrlsFailed,
+		"articles_created":  job.Counts.ArticlesCreated,
+		"articles_existing": job.Counts.ArticlesExisting,
+		"pages_captured":    job.Counts.PagesCaptured,
+		"captures_failed":   job.Counts.CapturesFailed,
+		"errors":            nonNilStrings(job.Errors),
+		"start_time":        neo4jDateTime(job.StartTime),
+		"updated_time":      neo4jDateTime(job.UpdatedTime),
+		"end_time":          neo4jDateTime(job.EndTime),
+	}
+}
+
+// Saves the progress of a backfill to its node:
+func saveBackfillJob(job *BackfillJob, ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	job.UpdatedTime = time.Now().UTC()
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (job:Backfill_Job) WHERE elementId(job) = $id
+		SET job = $job
+		RETURN job`,
+		map[string]any{"id": job.Id, "job": backfillJobProperties(*job)},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err
+	}
+	if len(result.Records) == 0 {
+		return fmt.Errorf("unable to find backfill %s to save its progress", job.Id)
+	}
+
+	return nil
+}
+
+func getBackfillJobWithSource(id string, ctx context.Context, driver neo4j.DriverWithContext) (job BackfillJob, rssFeed RssFeed, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (source:Rss_Feed:Source)-[:HAS_BACKFILL_JOB]->(job:Backfill_Job) WHERE elementId(job) = $id
+		RETURN source, job`,
+		map[string]any{"id": id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return job, rssFeed, err
+	}
+	if len(result.Records) == 0 {
+		return job, rssFeed, fmt.Errorf("unable to find backfill %s in the database", id)
+	}
+
+	sourceNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "source")
+	if err != nil {
+		return job, rssFeed, err
+	}
+	jobNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "job")
+	if err != nil {
+		return job, rssFeed, err
+	}
+	rssFeed = RssFeedFromNode(sourceNode)
+	job = BackfillJobFromNode(jobNode)
+	job.SourceId = rssFeed.Id
+
+	return job, rssFeed, nil
+}
+
+// Querying the database for a backfill and its progress:
+func GetBackfillJob(id string, ctx context.Context, driver neo4j.DriverWithContext) (job BackfillJob, err error) {
+	job, _, err = getBackfillJobWithSource(id, ctx, driver)
+	return job, err
+}
+
+// Querying the database for every backfill of a source, newest first:
+func GetBackfillJobsForSource(sourceId string, ctx context.Context, driver neo4j.DriverWithContext) (jobs []BackfillJob, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (source:Rss_Feed:Source) WHERE elementId(source) = $source_id
+		OPTIONAL MATCH (source)-[:HAS_BACKFILL_JOB]->(job:Backfill_Job)
+		WITH source, job
+		ORDER BY job.start_time DESC
+		RETURN source, collect(job) AS jobs
+		`,
+		map[string]any{"source_id": sourceId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return jobs, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find rss feed %s in the database", sourceId)
+		return jobs, err
+	}
+
+	jobNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "jobs")
+	if err != nil {
+		return jobs, err
+	}
+
+	jobs = []BackfillJob{}
+	for _, jobNode := range jobNodes {
+		if node, ok := jobNode.(neo4j.Node); ok {
+			job := BackfillJobFromNode(node)
+			job.SourceId = sourceId
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+// Converts a Backfill_Job node into a BackfillJob struct:
+func BackfillJobFromNode(node neo4j.Node) (job BackfillJob) {
+
+	nodeProps := node.GetProperties()
+
+	stringProperty := func(key string) string {
+		value, _ := nodeProps[key].(string)
+		return value
+	}
+	intProperty := func(key string) int {
+		value, _ := nodeProps[key].(int64)
+		return int(value)
+	}
+	stringsProperty := func(key string) []string {
+		values := []string{}
+		list, _ := nodeProps[key].([]any)
+		for _, value := range list {
+			if stringValue, ok := value.(string); ok {
+				values = append(values, stringValue)
+			}
+		}
+		return values
+	}
+
+	job.Id = node.ElementId
+	job.Mode = stringProperty("mode")
+	job.Options = BackfillOptions{
+		SitemapUrl: stringProperty("sitemap_url"),
+		ArchiveUrl: stringProperty("archive_url"),
+		UrlPattern: stringProperty("url_pattern"),
+		Since:      timeFromNodeProperty(nodeProps, "since"),
+		StartPage:  intProperty("start_page"),
+		MaxPages:   intProperty("max_pages"),
+		MaxUrls:    intProperty("max_urls"),
+	}
+	job.Options.CaptureHtml, _ = nodeProps["capture_html"].(bool)
+	job.Status = ExtractionStatus(stringProperty("status"))
+	job.Message = stringProperty("message")
+	job.Error = stringProperty("error")
+	job.PendingSitemaps = stringsProperty("pending_sitemaps")
+	job.SitemapOffset = intProperty("sitemap_offset")
+	job.NextPage = intProperty("next_page")
+	job.Counts = BackfillCounts{
+		SitemapsFetched:  intProperty("sitemaps_fetched"),
+		PagesFetched:     intProperty("pages_fetched"),
+		UrlsSeen:         intProperty("urls_seen"),
+		UrlsFiltered:     intProperty("urls_filtered"),
+		UrlsFailed:       intProperty("urls_failed"),
+		ArticlesCreated:  intProperty("articles_created"),
+		ArticlesExisting: intProperty("articles_existing"),
+		PagesCaptured:    intProperty("pages_captured"),
+		CapturesFailed:   intProperty("captures_failed"),
+	}
+	job.Errors = stringsProperty("errors")
+	job.StartTime = timeFromNodeProperty(nodeProps, "start_time")
+	job.UpdatedTime = timeFromNodeProperty(nodeProps, "updated_time")
+	job.EndTime = timeFromNodeProperty(nodeProps, "end_time")
+
+	return job
+}
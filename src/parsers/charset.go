@@ -0,0 +1,144 @@
+package parsers
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+)
+
+// Where the charset of a document was found, in the order they are checked:
+const (
+	CharsetFromBom            = "bom"
+	CharsetFromHeader         = "header"
+	CharsetFromXmlDeclaration = "xml_declaration"
+	CharsetFromMeta           = "meta"
+	CharsetFromSniffing       = "sniffed"
+	CharsetFromDefault        = "default"
+)
+
+// Pages only declare their charset in a <meta> tag near the start of the document:
+const metaCharsetPrescanBytes = 4096
+
+var metaCharset = regexp.MustCompile(`(?i)(<meta[^>]*?charset\s*=\s*["']?)([\w.:-]+)`)
+
+// Detects the charset of a feed or web page from its byte order mark, the charset of its Content-Type
+// header, its XML declaration or its <meta> charset, in that order, and otherwise by sniffing the content.
+// A body that is valid UTF-8 and isn't plain ASCII is read as UTF-8 whatever it declares, as that is far
+// more often a wrong declaration than a coincidence. Returns the charset's canonical name (eg: "utf-8",
+// "windows-1252", "shift_jis", "euc-kr") and where it was found:
+func DetectCharset(body []byte, contentType string) (name string, source string) {
+
+	switch {
+	case bytes.HasPrefix(body, []byte("\xef\xbb\xbf")):
+		return "utf-8", CharsetFromBom
+	case bytes.HasPrefix(body, []byte("\xff\xfe")):
+		return "utf-16le", CharsetFromBom
+	case bytes.HasPrefix(body, []byte("\xfe\xff")):
+		return "utf-16be", CharsetFromBom
+	}
+
+	validUtf8 := utf8.Valid(body)
+	declared := func(label string, source string) (string, string, bool) {
+		_, name := charset.Lookup(label)
+		if name == "" {
+			return "", "", false
+		}
+		if name != "utf-8" && validUtf8 && hasMultiByteRunes(body) {
+			return "utf-8", CharsetFromSniffing, true
+		}
+		if name == "utf-8" && !validUtf8 {
+			return "", "", false
+		}
+		return name, source, true
+	}
+
+	if name, source, ok := declared(ContentTypeCharset(contentType), CharsetFromHeader); ok {
+		return name, source
+	}
+	if match := xmlDeclarationEncoding.FindSubmatch(bytes.TrimLeft(body, " \t\r\n")); match != nil {
+		if name, source, ok := declared(string(match[2]), CharsetFromXmlDeclaration); ok {
+			return name, source
+		}
+	}
+	prescan := body
+	if len(prescan) > metaCharsetPrescanBytes {
+		prescan = prescan[:metaCharsetPrescanBytes]
+	}
+	if match := metaCharset.FindSubmatch(prescan); match != nil {
+		if name, source, ok := declared(string(match[2]), CharsetFromMeta); ok {
+			return name, source
+		}
+	}
+
+	if validUtf8 {
+		return "utf-8", CharsetFromSniffing
+	}
+	if results, err := chardet.NewTextDetector().DetectAll(body); err == nil {
+		for _, result := range results {
+			if _, name := charset.Lookup(result.Charset); name != "" {
+				return name, CharsetFromSniffing
+			}
+		}
+	}
+
+	return "windows-1252", CharsetFromDefault
+}
+
+// Returns the charset named by a Content-Type header, if any:
+func ContentTypeCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+// Transcodes a feed or web page to UTF-8 (see DetectCharset) and returns it along with the name of the
+// charset it was in. The XML declaration and <meta> charset are rewritten to UTF-8 so that the document
+// still describes itself correctly when it is parsed or stored:
+func DecodeToUtf8(body []byte, contentType string) (decoded []byte, charsetName string, err error) {
+
+	charsetName, _ = DetectCharset(body, contentType)
+
+	decoded = body
+	if charsetName != "utf-8" {
+		encoding, _ := charset.Lookup(charsetName)
+		if encoding == nil {
+			return body, charsetName, fmt.Errorf("unable to decode the unsupported charset %s", charsetName)
+		}
+		decoded, err = encoding.NewDecoder().Bytes(body)
+		if err != nil {
+			return body, charsetName, fmt.Errorf("unable to decode the document from %s: %w", charsetName, err)
+		}
+	}
+
+	return SetUtf8Declarations(decoded), charsetName, nil
+}
+
+// Rewrites the charset declared by a document's XML declaration and <meta> tag to UTF-8 once it has been
+// transcoded:
+func SetUtf8Declarations(body []byte) []byte {
+
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); xmlDeclarationEncoding.Match(trimmed) {
+		body = setXmlDeclarationEncoding(trimmed, "UTF-8")
+	}
+
+	prescan := body
+	if len(prescan) > metaCharsetPrescanBytes {
+		prescan = prescan[:metaCharsetPrescanBytes]
+	}
+	if match := metaCharset.FindSubmatchIndex(prescan); match != nil && !strings.EqualFold(string(body[match[4]:match[5]]), "utf-8") {
+		rewritten := append([]byte{}, body[:match[4]]...)
+		rewritten = append(rewritten, "utf-8"...)
+		body = append(rewritten, body[match[5]:]...)
+	}
+
+	return body
+}
@@ -0,0 +1,148 @@
+package parsers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Layouts tried, in order, for dates that gofeed was unable to parse itself. These cover the odd formats
+// we have seen in feeds and page metadata: missing seconds, missing timezones, written out month names
+// and plain dates:
+var fallbackDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 MST",
+	"02 Jan 2006 15:04 -0700",
+	"02 Jan 2006 15:04:05",
+	"02 Jan 2006",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006",
+	"January 2, 2006 15:04:05",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// Named timezones that Go can't resolve on its own when parsing with the MST layout:
+var timezoneAbbreviationOffsets = map[string]string{
+	"UT":  "+0000",
+	"GMT": "+0000",
+	"UTC": "+0000",
+	"Z":   "+0000",
+	"EST": "-0500",
+	"EDT": "-0400",
+	"CST": "-0600",
+	"CDT": "-0500",
+	"MST": "-0700",
+	"MDT": "-0600",
+	"PST": "-0800",
+	"PDT": "-0700",
+	"BST": "+0100",
+	"CET": "+0100",
+	"KST": "+0900",
+	"JST": "+0900",
+}
+
+var leadingWeekdayPattern = regexp.MustCompile(`^(?i)(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?,?\s+`)
+var ordinalSuffixPattern = regexp.MustCompile(`(\d)(st|nd|rd|th)\b`)
+
+// Parses a date string from a feed or web page that gofeed could not parse. Leading weekday names and
+// ordinal suffixes are removed and named timezones are swapped for their offsets before each of the
+// fallback layouts is tried. Dates without a timezone are assumed to be UTC:
+func ParseFeedDate(value string) (parsedTime time.Time, err error) {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return parsedTime, fmt.Errorf("empty date string")
+	}
+
+	cleanedValue := leadingWeekdayPattern.ReplaceAllString(value, "")
+	cleanedValue = ordinalSuffixPattern.ReplaceAllString(cleanedValue, "$1")
+	cleanedValue = strings.Join(strings.Fields(cleanedValue), " ")
+
+	// A trailing timezone abbreviation is swapped for its numeric offset:
+	if lastSpace := strings.LastIndex(cleanedValue, " "); lastSpace != -1 {
+		if offset, ok := timezoneAbbreviationOffsets[strings.ToUpper(cleanedValue[lastSpace+1:])]; ok {
+			cleanedValue = cleanedValue[:lastSpace] + " " + offset
+		}
+	}
+
+	for _, layout := range fallbackDateLayouts {
+		parsedTime, err = time.Parse(layout, cleanedValue)
+		if err == nil {
+			return parsedTime.UTC(), nil
+		}
+	}
+
+	return parsedTime, fmt.Errorf("unable to parse date %q", value)
+}
+
+// Returns the parsed time gofeed produced for a field, falling back to ParseFeedDate on the raw string.
+// Returns the zero time if the field is empty or can't be parsed in any format:
+func FeedItemTime(parsed *time.Time, raw string) time.Time {
+	if parsed != nil {
+		return parsed.UTC()
+	}
+	fallbackTime, err := ParseFeedDate(raw)
+	if err != nil {
+		return time.Time{}
+	}
+	return fallbackTime
+}
+
+// Converts a time into the value passed to neo4j as a query parameter. Times are always stored as UTC
+// datetimes and the zero time is stored as null so that the property is left unset:
+func neo4jDateTime(value time.Time) any {
+	if value.IsZero() {
+		return nil
+	}
+	return value.UTC()
+}
+
+// Reads a temporal property from a node. Datetimes are returned in UTC. Properties written before dates
+// were stored as datetimes (millisecond timestamps and date strings) are converted so that nodes that have
+// not been migrated yet can still be read:
+func timeFromNodeProperty(nodeProps map[string]any, key string) time.Time {
+
+	switch value := nodeProps[key].(type) {
+	case time.Time:
+		return value.UTC()
+	case neo4j.LocalDateTime:
+		return time.Time(value).UTC()
+	case neo4j.Date:
+		return time.Time(value).UTC()
+	case int64:
+		return time.UnixMilli(value).UTC()
+	case string:
+		parsedTime, err := ParseFeedDate(value)
+		if err == nil {
+			return parsedTime
+		}
+	}
+
+	return time.Time{}
+}
+
+// Formats a time for display in summaries and diffs, the zero time is displayed as an empty string:
+func formatDateTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
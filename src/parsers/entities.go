@@ -0,0 +1,406 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// The types of entity the gazetteer and its rules find. Other extractors may return their own types:
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+	EntityPlace        = "place"
+	EntityWeaponSystem = "weapon_system"
+)
+
+// An entity mentioned in an article. Name is the entity's preferred name, Aliases the other ways it was
+// written in the article and Count the number of times it was mentioned:
+type EntityMention struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Count   int      `json:"count"`
+	Aliases []string `json:"aliases"`
+}
+
+// Finds the people, organisations, places, weapons systems etc. mentioned in the plain text of an
+// article. Mentions of the same entity are returned once with their count:
+type EntityExtractor interface {
+	Name() string
+	ExtractEntities(text string) ([]EntityMention, error)
+}
+
+// The entity extractors that can be used, by name. The gazetteer is replaced by the one loaded from the
+// -gazetteer file in main. Other backends are added with RegisterEntityExtractor:
+var EntityExtractors = map[string]EntityExtractor{
+	"gazetteer": NewGazetteer(nil),
+}
+
+// Adds an entity extractor, or replaces the one with the same name:
+func RegisterEntityExtractor(name string, extractor EntityExtractor) {
+	EntityExtractors[name] = extractor
+}
+
+// Settings for finding the entities mentioned by articles. When Enabled the entities of every new article
+// are extracted as it is ingested, otherwise only on request. Set in main from the command line flags:
+type EntityExtractionConfig struct {
+	Enabled   bool
+	Extractor EntityExtractor
+}
+
+var EntityExtraction = EntityExtractionConfig{
+	Extractor: EntityExtractors["gazetteer"],
+}
+
+// An entity of the gazetteer along with the other names it goes by:
+type GazetteerEntry struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Aliases []string `json:"aliases"`
+}
+
+// The offline entity extractor. Known entities are looked up by their name and aliases (the alias table
+// that merges the variants of a name, eg: "DPRK" and "North Korea"), preferring the longest name that
+// matches. Names are compared ignoring case and punctuation so "Kim Jong-un" matches "Kim Jong Un", except
+// for short acronyms like "US" or "UN" which must match exactly. Entities that aren't in the gazetteer
+// are found by rules (see entityRules):
+type Gazetteer struct {
+	entries   []GazetteerEntry
+	terms     map[string]gazetteerTerm
+	maxTokens int
+}
+
+// A name or alias of a gazetteer entry. exactForms holds the ways an acronym may be written, it is nil
+// for names that match in any case:
+type gazetteerTerm struct {
+	entry      int
+	exactForms map[string]bool
+}
+
+func NewGazetteer(entries []GazetteerEntry) *Gazetteer {
+
+	gazetteer := &Gazetteer{terms: map[string]gazetteerTerm{}, maxTokens: 1}
+	for _, entry := range entries {
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.Type = strings.ToLower(strings.TrimSpace(entry.Type))
+		if entry.Name == "" || entry.Type == "" {
+			continue
+		}
+		entryIndex := len(gazetteer.entries)
+		gazetteer.entries = append(gazetteer.entries, entry)
+
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			tokens := entityTokenPattern.FindAllString(name, -1)
+			if len(tokens) == 0 {
+				continue
+			}
+			if len(tokens) > gazetteer.maxTokens {
+				gazetteer.maxTokens = len(tokens)
+			}
+			key := NormaliseEntityName(name)
+			term, exists := gazetteer.terms[key]
+			if exists && term.entry != entryIndex {
+				continue
+			}
+			if !exists {
+				term = gazetteerTerm{entry: entryIndex}
+				if isEntityAcronym(name) {
+					term.exactForms = map[string]bool{}
+				}
+			}
+			switch {
+			case !isEntityAcronym(name):
+				term.exactForms = nil
+			case term.exactForms != nil:
+				term.exactForms[strings.Join(tokens, " ")] = true
+			}
+			gazetteer.terms[key] = term
+		}
+	}
+
+	return gazetteer
+}
+
+// Reads a gazetteer from a json file of the form {"entities": [{"name", "type", "aliases"}, ...]}:
+func LoadGazetteer(path string) (*Gazetteer, error) {
+
+	gazetteerBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var gazetteerFile struct {
+		Entities []GazetteerEntry `json:"entities"`
+	}
+	if err = json.Unmarshal(gazetteerBytes, &gazetteerFile); err != nil {
+		return nil, fmt.Errorf("unable to read the gazetteer %s: %w", path, err)
+	}
+
+	return NewGazetteer(gazetteerFile.Entities), nil
+}
+
+func (gazetteer *Gazetteer) Name() string { return "gazetteer" }
+
+func (gazetteer *Gazetteer) ExtractEntities(text string) ([]EntityMention, error) {
+
+	mentions := entityMentionCounter{index: map[string]int{}}
+	tokenSpans := entityTokenPattern.FindAllStringIndex(text, -1)
+	var takenSpans [][]int
+
+	// 1) Looking up the longest run of tokens that is a name in the gazetteer at every token:
+	for i := 0; i < len(tokenSpans); {
+		matched := 0
+		for n := gazetteer.maxTokens; n > 0 && matched == 0; n-- {
+			if i+n > len(tokenSpans) {
+				continue
+			}
+			tokens := make([]string, n)
+			for j := range tokens {
+				tokens[j] = text[tokenSpans[i+j][0]:tokenSpans[i+j][1]]
+			}
+			term, ok := gazetteer.terms[NormaliseEntityName(strings.Join(tokens, " "))]
+			if !ok || (term.exactForms != nil && !term.exactForms[strings.Join(tokens, " ")]) {
+				continue
+			}
+			entry := gazetteer.entries[term.entry]
+			span := []int{tokenSpans[i][0], tokenSpans[i+n-1][1]}
+			mentions.add(entry.Name, entry.Type, text[span[0]:span[1]])
+			takenSpans = append(takenSpans, span)
+			matched = n
+		}
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+
+	// 2) Applying the rules to the text the gazetteer didn't match. Names found by a rule that are in the
+	// gazetteer (eg: a title followed by one of its aliases) are counted as the gazetteer's entity:
+	for _, rule := range entityRules {
+		for _, match := range rule.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := trimEntityStopWords(text, match[2], match[3])
+			if start >= end || entitySpanTaken(takenSpans, start, end) {
+				continue
+			}
+			name := text[start:end]
+			if entityRuleStopList[NormaliseEntityName(name)] {
+				continue
+			}
+			if term, ok := gazetteer.terms[NormaliseEntityName(name)]; ok && term.exactForms == nil {
+				entry := gazetteer.entries[term.entry]
+				mentions.add(entry.Name, entry.Type, name)
+			} else {
+				mentions.add(name, rule.entityType, name)
+			}
+			takenSpans = append(takenSpans, []int{start, end})
+		}
+	}
+
+	return mentions.sorted(), nil
+}
+
+// Words made of letters and digits that may be joined by hyphens, apostrophes or full stops (eg:
+// "Hwasong-17", "People's", "U.S"):
+var entityTokenPattern = regexp.MustCompile(`[\p{L}\p{N}]+(?:[-'’.][\p{L}\p{N}]+)*`)
+
+// Normalises the name of an entity so that variants that differ only in case, punctuation or spacing
+// compare equal (eg: "Kim Jong-un" and "Kim Jong Un" both become "kim jong un"):
+func NormaliseEntityName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// Reports whether a name is a short acronym (eg: "US", "U.N.", "KPA") that could be confused with an
+// ordinary word if it was matched in any case:
+func isEntityAcronym(name string) bool {
+	letters := 0
+	for _, r := range name {
+		if unicode.IsLetter(r) {
+			if !unicode.IsUpper(r) {
+				return false
+			}
+			letters++
+		}
+	}
+	return letters > 0 && letters <= 4
+}
+
+// A rule that finds entities of a type that aren't in the gazetteer. The first group of the pattern is the
+// entity's name:
+type entityRule struct {
+	entityType string
+	pattern    *regexp.Regexp
+}
+
+const (
+	entityNameWord  = `\p{Lu}[\p{L}'’-]*`
+	entityNameWords = entityNameWord + `(?:\s+` + entityNameWord + `)`
+)
+
+var entityRules = []entityRule{
+	// People named after their title (eg: "Foreign Minister Choe Son Hui", "Gen. Pak Jong Chon"):
+	{EntityPerson, regexp.MustCompile(`\b(?:(?:President|Chairman|Chairwoman|General|Gen\.|Marshal|Minister|Premier|Secretary|Ambassador|Senator|Sen\.|Rep\.|Dr\.|Mr\.|Ms\.|Mrs\.|Professor|Prof\.)\s+)+(` + entityNameWords + `{0,2})`)},
+	// Organisations named after what they are (eg: "Ministry of National Defense", "Academy of Defense Science"):
+	{EntityOrganization, regexp.MustCompile(`\b((?:Ministry|Department|Bureau|Academy|Institute|University|Office|Commission) of (?:the )?` + entityNameWords + `{0,3})`)},
+	{EntityOrganization, regexp.MustCompile(`\b(` + entityNameWord + `(?:\s+` + entityNameWord + `){0,4}\s+(?:Ministry|Agency|Party|Committee|Council|Institute|University|Corporation|Company|Bank|Army|Navy|Command|Association|Foundation|Center|Centre|Commission|Department|Bureau|Academy|Administration))\b`)},
+	// Weapons systems named by their designation (eg: "Hwasong-19", "S-400", "MiG-29"):
+	{EntityWeaponSystem, regexp.MustCompile(`\b(\p{Lu}\p{L}*-\d{1,3}\p{Lu}?)\b`)},
+}
+
+// Capitalised words that start a sentence or a date rather than a name and are trimmed from the ends of
+// the names found by rules:
+var entityStopWords = map[string]bool{
+	"the": true, "a": true, "an": true, "and": true, "of": true, "in": true, "on": true, "at": true,
+	"for": true, "to": true, "but": true, "with": true, "this": true, "that": true, "after": true,
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true,
+	"sunday": true, "january": true, "february": true, "march": true, "april": true, "may": true,
+	"june": true, "july": true, "august": true, "september": true, "october": true, "november": true,
+	"december": true,
+}
+
+// Names that match a rule but aren't entities of its type:
+var entityRuleStopList = map[string]bool{
+	"covid 19": true,
+}
+
+// Trims stop words from both ends of a name found by a rule, and cuts the name at the first stop word
+// inside it (eg: "Kim Jong Un On Tuesday" becomes "Kim Jong Un"). Returns the new start and end:
+func trimEntityStopWords(text string, start int, end int) (int, int) {
+
+	if start < 0 {
+		return 0, 0
+	}
+	tokenSpans := entityTokenPattern.FindAllStringIndex(text[start:end], -1)
+	first := 0
+	for first < len(tokenSpans) && entityStopWords[strings.ToLower(text[start+tokenSpans[first][0]:start+tokenSpans[first][1]])] {
+		first++
+	}
+	last := first
+	for last < len(tokenSpans) {
+		word := strings.ToLower(text[start+tokenSpans[last][0] : start+tokenSpans[last][1]])
+		// "of" and "the" are part of names like "Ministry of the Interior":
+		if entityStopWords[word] && word != "of" && word != "the" {
+			break
+		}
+		last++
+	}
+	for last > first && entityStopWords[strings.ToLower(text[start+tokenSpans[last-1][0]:start+tokenSpans[last-1][1]])] {
+		last--
+	}
+	if first >= last {
+		return 0, 0
+	}
+
+	return start + tokenSpans[first][0], start + tokenSpans[last-1][1]
+}
+
+func entitySpanTaken(takenSpans [][]int, start int, end int) bool {
+	for _, span := range takenSpans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts the mentions of each entity, keyed by its normalised name:
+type entityMentionCounter struct {
+	mentions []EntityMention
+	index    map[string]int
+}
+
+func (counter *entityMentionCounter) add(name string, entityType string, written string) {
+
+	key := NormaliseEntityName(name)
+	i, seen := counter.index[key]
+	if !seen {
+		i = len(counter.mentions)
+		counter.index[key] = i
+		counter.mentions = append(counter.mentions, EntityMention{Name: name, Type: entityType, Aliases: []string{}})
+	}
+	counter.mentions[i].Count++
+
+	written = strings.Join(strings.Fields(written), " ")
+	if written == name {
+		return
+	}
+	for _, alias := range counter.mentions[i].Aliases {
+		if alias == written {
+			return
+		}
+	}
+	counter.mentions[i].Aliases = append(counter.mentions[i].Aliases, written)
+}
+
+// The mentions with the most mentioned first:
+func (counter *entityMentionCounter) sorted() []EntityMention {
+
+	mentions := append([]EntityMention{}, counter.mentions...)
+	for i := range mentions {
+		sort.Strings(mentions[i].Aliases)
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		if mentions[i].Count != mentions[j].Count {
+			return mentions[i].Count > mentions[j].Count
+		}
+		return mentions[i].Name < mentions[j].Name
+	})
+
+	return mentions
+}
+
+// The plain text that the entities of an article are extracted from: its title, its description and the
+// body of its page, or its content when there is no page:
+func ArticleText(rssEntry RssEntry, page *ArticlePage) string {
+
+	parts := []string{rssEntry.Title, htmlText([]byte(rssEntry.Description), false)}
+	if page != nil && len(page.Body) > 0 {
+		parts = append(parts, htmlText(page.Body, true))
+	} else {
+		parts = append(parts, htmlText([]byte(rssEntry.Content), false))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// The text of an html document or fragment. Text nodes are separated by spaces so that words in
+// neighbouring elements aren't run together. Only the article body is read from full pages:
+func htmlText(body []byte, articleBodyOnly bool) string {
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	selection := doc.Selection
+	if articleBodyOnly {
+		if articleBody := findArticleBody(doc); articleBody != nil {
+			selection = articleBody
+		}
+	}
+	selection.Find("script, style, noscript, template").Remove()
+
+	var text strings.Builder
+	var writeText func(node *html.Node)
+	writeText = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+			text.WriteString(" ")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			writeText(child)
+		}
+	}
+	for _, node := range selection.Nodes {
+		writeText(node)
+	}
+
+	return strings.Join(strings.Fields(text.String()), " ")
+}
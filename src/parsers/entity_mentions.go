@@ -0,0 +1,429 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+var ErrEntityNotFound = errors.New("unable to find the entity")
+
+// A person, organisation, place, weapons system etc. that articles mention. Entities are matched on their
+// normalised name (see NormaliseEntityName) or one of their aliases, which are normalised as well:
+type Entity struct {
+	Id             string   `json:"id"`
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	NormalisedName string   `json:"normalised_name"`
+	Aliases        []string `json:"aliases"`
+	Articles       int64    `json:"articles,omitempty"`
+	Mentions       int64    `json:"mentions,omitempty"`
+}
+
+// An entity mentioned by an article along with the number of times it is mentioned:
+type ArticleEntity struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// An article that mentions an entity along with the number of times it mentions it:
+type EntityArticle struct {
+	Article RssEntry `json:"article"`
+	Count   int64    `json:"count"`
+}
+
+// Replaces the MENTIONS relationships of an article with $mentions. Each mention is connected to the
+// Entity with the same normalised name, or that has it as an alias, and a new Entity is created for names
+// that haven't been seen before. The other ways the entity was written are added to its aliases:
+const writeArticleEntitiesQuery = `
+MATCH (article:Article) WHERE elementId(article) = $article_id
+OPTIONAL MATCH (article)-[old:MENTIONS]->(:Entity)
+DELETE old
+WITH DISTINCT article
+UNWIND $mentions AS mention
+CALL {
+	WITH mention
+	OPTIONAL MATCH (existing:Entity)
+	WHERE existing.normalised_name = mention.normalised_name OR mention.normalised_name IN coalesce(existing.aliases, [])
+	RETURN existing
+	ORDER BY CASE WHEN existing.normalised_name = mention.normalised_name THEN 0 ELSE 1 END
+	LIMIT 1
+}
+FOREACH (_ IN CASE WHEN existing IS NULL THEN [1] ELSE [] END |
+	MERGE (created:Entity {normalised_name: mention.normalised_name})
+	ON CREATE SET
+		created.name = mention.name,
+		created.type = mention.type,
+		created.aliases = [],
+		created.created = datetime({timezone: 'UTC'})
+)
+WITH article, mention, existing
+OPTIONAL MATCH (created:Entity {normalised_name: mention.normalised_name})
+WITH article, mention, coalesce(existing, created) AS entity
+SET entity.aliases = reduce(
+	aliases = coalesce(entity.aliases, []),
+	alias IN mention.aliases |
+	CASE WHEN alias = entity.normalised_name OR alias IN aliases THEN aliases ELSE aliases + alias END
+)
+MERGE (article)-[mentions:MENTIONS]->(entity)
+ON CREATE SET mentions.count = mention.count
+ON MATCH SET mentions.count = mentions.count + mention.count
+SET mentions.extractor = $extractor, mentions.date_extracted = datetime({timezone: 'UTC'})
+RETURN elementId(entity) AS id, entity.name AS name, entity.type AS type, mentions.count AS count
+`
+
+// Extracts the entities mentioned in the text of an article with the configured extractor (see
+// EntityExtraction) and records them as MENTIONS relationships:
+func RecordArticleEntities(articleId string, text string, ctx context.Context, driver neo4j.DriverWithContext) (entities []ArticleEntity, err error) {
+
+	entities = []ArticleEntity{}
+	extractor := EntityExtraction.Extractor
+	if extractor == nil {
+		return entities, fmt.Errorf("no entity extractor is configured")
+	}
+
+	mentions, err := extractor.ExtractEntities(text)
+	if err != nil {
+		return entities, err
+	}
+
+	mentionRows := []map[string]any{}
+	mentionIndex := map[string]int{}
+	for _, mention := range mentions {
+		normalisedName := NormaliseEntityName(mention.Name)
+		if normalisedName == "" {
+			continue
+		}
+		aliases := []string{}
+		for _, alias := range mention.Aliases {
+			if normalisedAlias := NormaliseEntityName(alias); normalisedAlias != "" && normalisedAlias != normalisedName {
+				aliases = append(aliases, normalisedAlias)
+			}
+		}
+		// Extractors that return the same entity more than once have their counts added up:
+		if i, seen := mentionIndex[normalisedName]; seen {
+			mentionRows[i]["count"] = mentionRows[i]["count"].(int) + mention.Count
+			mentionRows[i]["aliases"] = append(mentionRows[i]["aliases"].([]string), aliases...)
+			continue
+		}
+		mentionIndex[normalisedName] = len(mentionRows)
+		mentionRows = append(mentionRows, map[string]any{
+			"name":            strings.TrimSpace(mention.Name),
+			"normalised_name": normalisedName,
+			"type":            mention.Type,
+			"count":           mention.Count,
+			"aliases":         aliases,
+		})
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		writeArticleEntitiesQuery,
+		map[string]any{"article_id": articleId, "mentions": mentionRows, "extractor": extractor.Name()},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entities, err
+	}
+
+	for _, record := range result.Records {
+		recordMap := record.AsMap()
+		var entity ArticleEntity
+		entity.Id, _ = recordMap["id"].(string)
+		entity.Name, _ = recordMap["name"].(string)
+		entity.Type, _ = recordMap["type"].(string)
+		entity.Count, _ = recordMap["count"].(int64)
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+// Extracts the entities of a new article when extraction on ingest is enabled (see EntityExtraction):
+func extractNewArticleEntities(articleId string, rssEntry RssEntry, page *ArticlePage, ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	if !EntityExtraction.Enabled {
+		return nil
+	}
+	_, err := RecordArticleEntities(articleId, ArticleText(rssEntry, page), ctx, driver)
+
+	return err
+}
+
+// Extracts the entities of an article that has already been ingested from its captured page, or from its
+// content if it hasn't been captured:
+func RefreshArticleEntities(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (entities []ArticleEntity, err error) {
+
+	rssEntry, err := GetRssArticleById(articleId, ctx, driver)
+	if err != nil {
+		return entities, err
+	}
+
+	var page *ArticlePage
+	if rssEntry.InStorage == 1 && PageCaptures.Client != nil {
+		body, err := readCapturedPage(rssEntry.StorageUrl, ctx)
+		if err != nil {
+			return entities, err
+		}
+		page = &ArticlePage{Url: rssEntry.Url, Body: body}
+	}
+
+	return RecordArticleEntities(rssEntry.Id, ArticleText(rssEntry, page), ctx, driver)
+}
+
+// Returns the entities mentioned by an article, the most mentioned first:
+func GetArticleEntities(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (entities []ArticleEntity, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $id
+		OPTIONAL MATCH (article)-[mentions:MENTIONS]->(entity:Entity)
+		WITH article, mentions, entity
+		ORDER BY mentions.count DESC, entity.name
+		RETURN collect(CASE WHEN entity IS NULL THEN NULL ELSE {
+			id: elementId(entity),
+			name: entity.name,
+			type: entity.type,
+			count: mentions.count
+		} END) AS entities`,
+		map[string]any{"id": articleId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entities, err
+	}
+	if len(result.Records) == 0 {
+		return entities, fmt.Errorf("%w: %s", ErrArticleNotFound, articleId)
+	}
+
+	entityMaps, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "entities")
+	if err != nil {
+		return entities, err
+	}
+	entities = []ArticleEntity{}
+	for _, value := range entityMaps {
+		entityMap, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		var entity ArticleEntity
+		entity.Id, _ = entityMap["id"].(string)
+		entity.Name, _ = entityMap["name"].(string)
+		entity.Type, _ = entityMap["type"].(string)
+		entity.Count, _ = entityMap["count"].(int64)
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+// Returns the entities mentioned by the most articles, optionally only those of one type:
+func GetEntities(entityType string, limit int, ctx context.Context, driver neo4j.DriverWithContext) (entities []Entity, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (entity:Entity)
+		WHERE $type = "" OR entity.type = $type
+		OPTIONAL MATCH (article:Article)-[mentions:MENTIONS]->(entity)
+		WITH entity, count(DISTINCT article) AS articles, sum(coalesce(mentions.count, 0)) AS mentions
+		RETURN entity, articles, mentions
+		ORDER BY articles DESC, mentions DESC, entity.name
+		LIMIT $limit`,
+		map[string]any{"type": entityType, "limit": limit},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entities, err
+	}
+
+	entities = []Entity{}
+	for _, record := range result.Records {
+		entityNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "entity")
+		if err != nil {
+			return entities, err
+		}
+		entity := EntityFromNode(entityNode)
+		entity.Articles, _, _ = neo4j.GetRecordValue[int64](record, "articles")
+		entity.Mentions, _, _ = neo4j.GetRecordValue[int64](record, "mentions")
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+// Querying the database for an entity along with every article that mentions it, the articles that
+// mention it the most first:
+func GetEntityWithArticles(id string, ctx context.Context, driver neo4j.DriverWithContext) (entity Entity, articles []EntityArticle, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (entity:Entity) WHERE elementId(entity) = $id
+		OPTIONAL MATCH (article:Article)-[mentions:MENTIONS]->(entity)
+		WITH entity, article, mentions
+		ORDER BY mentions.count DESC, article.date_posted DESC
+		RETURN entity, collect(CASE WHEN article IS NULL THEN NULL ELSE {article: article, count: mentions.count} END) AS articles`,
+		map[string]any{"id": id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entity, articles, err
+	}
+	if len(result.Records) == 0 {
+		return entity, articles, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+	}
+
+	entityNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "entity")
+	if err != nil {
+		return entity, articles, err
+	}
+	entity = EntityFromNode(entityNode)
+
+	articleMaps, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "articles")
+	if err != nil {
+		return entity, articles, err
+	}
+	articles = []EntityArticle{}
+	for _, value := range articleMaps {
+		articleMap, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		articleNode, ok := articleMap["article"].(neo4j.Node)
+		if !ok {
+			continue
+		}
+		count, _ := articleMap["count"].(int64)
+		articles = append(articles, EntityArticle{Article: RssEntryFromNode(articleNode), Count: count})
+	}
+	entity.Articles = int64(len(articles))
+
+	return entity, articles, nil
+}
+
+// Merges the source entity into the target entity. Every MENTIONS relationship of the source is moved to
+// the target, adding up the counts of articles that mention both, the source's names are kept as aliases
+// of the target so that later mentions of them are connected to the target, and the source node is
+// deleted:
+func MergeEntities(sourceId string, targetId string, ctx context.Context, driver neo4j.DriverWithContext) (entity Entity, err error) {
+
+	if sourceId == targetId {
+		return entity, fmt.Errorf("cannot merge entity %s into itself", sourceId)
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (source:Entity) WHERE elementId(source) = $source_id
+		MATCH (target:Entity) WHERE elementId(target) = $target_id
+
+		OPTIONAL MATCH (article:Article)-[mentions:MENTIONS]->(source)
+		FOREACH (a IN CASE WHEN article IS NULL THEN [] ELSE [article] END |
+			MERGE (a)-[merged:MENTIONS]->(target)
+			ON CREATE SET
+				merged.count = mentions.count,
+				merged.extractor = mentions.extractor,
+				merged.date_extracted = mentions.date_extracted
+			ON MATCH SET merged.count = merged.count + mentions.count
+		)
+		DELETE mentions
+
+		WITH DISTINCT source, target
+		SET target.aliases = reduce(
+				aliases = [],
+				alias IN coalesce(target.aliases, []) + coalesce(source.aliases, []) + [source.normalised_name] |
+				CASE WHEN alias = target.normalised_name OR alias IN aliases THEN aliases ELSE aliases + alias END
+			)
+		WITH source, target
+		DETACH DELETE source
+
+		RETURN target AS entity
+		`,
+		map[string]any{"source_id": sourceId, "target_id": targetId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entity, err
+	}
+
+	if len(result.Records) == 0 {
+		return entity, fmt.Errorf("%w: %s and %s are not both entities that can be merged", ErrEntityNotFound, sourceId, targetId)
+	}
+
+	entityNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "entity")
+	if err != nil {
+		return entity, err
+	}
+
+	return EntityFromNode(entityNode), nil
+}
+
+// Adds an alias to an entity so that later mentions of the alias are connected to it:
+func AddEntityAlias(id string, alias string, ctx context.Context, driver neo4j.DriverWithContext) (entity Entity, err error) {
+
+	normalisedAlias := NormaliseEntityName(alias)
+	if normalisedAlias == "" {
+		return entity, fmt.Errorf("the alias %q has no letters or digits", alias)
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (entity:Entity) WHERE elementId(entity) = $id
+		SET entity.aliases = CASE
+			WHEN $alias = entity.normalised_name OR $alias IN coalesce(entity.aliases, []) THEN coalesce(entity.aliases, [])
+			ELSE coalesce(entity.aliases, []) + $alias
+		END
+		RETURN entity`,
+		map[string]any{"id": id, "alias": normalisedAlias},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return entity, err
+	}
+	if len(result.Records) == 0 {
+		return entity, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+	}
+
+	entityNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "entity")
+	if err != nil {
+		return entity, err
+	}
+
+	return EntityFromNode(entityNode), nil
+}
+
+// Converts an Entity node into an Entity struct:
+func EntityFromNode(node neo4j.Node) (entity Entity) {
+
+	nodeProps := node.GetProperties()
+	entity.Id = node.GetElementId()
+	if name, ok := nodeProps["name"].(string); ok {
+		entity.Name = name
+	}
+	if entityType, ok := nodeProps["type"].(string); ok {
+		entity.Type = entityType
+	}
+	if normalisedName, ok := nodeProps["normalised_name"].(string); ok {
+		entity.NormalisedName = normalisedName
+	}
+	entity.Aliases = []string{}
+	if aliases, ok := nodeProps["aliases"].([]any); ok {
+		for _, alias := range aliases {
+			if aliasName, ok := alias.(string); ok {
+				entity.Aliases = append(entity.Aliases, aliasName)
+			}
+		}
+	}
+
+	return entity
+}
@@ -0,0 +1,230 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Controls how long a failing source is left alone before it is tried again and when it is given up on. The
+// delay doubles with every consecutive failure, starting at BaseBackoff and never exceeding MaxBackoff. A
+// source is disabled once it has failed DisableAfter times in a row (zero never disables a source):
+type FeedHealthConfig struct {
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	DisableAfter int
+}
+
+// The health settings used when recording ingestion failures. Overridden from the command line flags:
+var FeedHealth = FeedHealthConfig{
+	BaseBackoff:  15 * time.Minute,
+	MaxBackoff:   24 * time.Hour,
+	DisableAfter: 10,
+}
+
+// A source that is failing or disabled along with its type (eg: rss or newsletter) and a human readable
+// reason. Sources of every type are reported as an RssFeed with their schedule and health set:
+type RssFeedHealthReport struct {
+	RssFeed RssFeed `json:"source_feed"`
+	Type    string  `json:"type"`
+	Status  string  `json:"status"`
+	Reason  string  `json:"reason"`
+}
+
+// Only failures caused by the source itself count against its health. Errors in our own database or a
+// source missing from it say nothing about whether the source is working:
+func isFeedFailure(status ExtractionStatus) bool {
+	return status.IsError() && status != StatusErrorDb && status != StatusErrorNotFound
+}
+
+// Returns when a source that has failed a number of times in a row should next be tried:
+func NextAttemptAfterFailure(consecutiveFailures int, failedAt time.Time, config FeedHealthConfig) time.Time {
+
+	backoff := config.BaseBackoff
+	for i := 1; i < consecutiveFailures && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > config.MaxBackoff {
+		backoff = config.MaxBackoff
+	}
+
+	return failedAt.Add(backoff).UTC()
+}
+
+// Reads the schedule and health of a source of any type from the properties of its node into the fields
+// of an RssFeed that hold them (see RecordSourceHealth):
+func readSourceHealth(nodeProps map[string]any, rssFeed *RssFeed) {
+
+	if scheduledTime, ok := nodeProps["scheduled_time"].(string); ok {
+		rssFeed.ExecuteTime = scheduledTime
+	}
+	if consecutiveFailures, ok := nodeProps["consecutive_failures"].(int64); ok {
+		rssFeed.ConsecutiveFailures = int(consecutiveFailures)
+	}
+	rssFeed.LastSuccess = timeFromNodeProperty(nodeProps, "last_success")
+	rssFeed.LastFailure = timeFromNodeProperty(nodeProps, "last_failure")
+	if lastError, ok := nodeProps["last_error"].(string); ok {
+		rssFeed.LastError = lastError
+	}
+	rssFeed.NextAttempt = timeFromNodeProperty(nodeProps, "next_attempt")
+	if disabled, ok := nodeProps["disabled"].(bool); ok {
+		rssFeed.Disabled = disabled
+	}
+}
+
+// Records the outcome of an ingestion on its source, of any type. A successful ingestion (including a 304
+// Not Modified) clears the failure count and backoff and re-enables the source. A failed one increments the
+// failure count, pushes the next attempt back and disables the source once the threshold is reached.
+// Ingestions that ended because of our own database errors push the next attempt back by the source's
+// current backoff without counting against it (see isFeedFailure), so that the scheduler doesn't retry them
+// on every tick. Ingestions of sources missing from the database are not recorded:
+func RecordSourceHealth(sourceId string, summary RssFeedExtractionSummary, at time.Time, ctx context.Context, driver neo4j.DriverWithContext) (err error) {
+
+	if summary.Status == StatusCompleted || summary.Status == StatusNotModified {
+		_, err = neo4j.ExecuteQuery(
+			ctx,
+			driver,
+			`
+			MATCH (source:Source) WHERE elementId(source) = $id
+			SET
+				source.consecutive_failures = 0,
+				source.last_success = $at,
+				source.next_attempt = null,
+				source.disabled = false
+			`,
+			map[string]any{"id": sourceId, "at": at.UTC()},
+			neo4j.EagerResultTransformer,
+			neo4j.ExecuteQueryWithDatabase("neo4j"))
+		return err
+	}
+
+	if !summary.Status.IsError() || summary.Status == StatusErrorNotFound {
+		return nil
+	}
+	countsAsFailure := isFeedFailure(summary.Status)
+	failureIncrement := 0
+	if countsAsFailure {
+		failureIncrement = 1
+	}
+
+	lastError := summary.Error
+	if lastError == "" {
+		lastError = summary.Message
+	}
+
+	// The failure count is incremented by the database rather than from the node read when the ingestion
+	// started, so that overlapping ingestions (eg: the scheduler and a manual one) each count, and is left as
+	// it is by our own errors. The backoff and whether the source is disabled are worked out from the new
+	// count in the same transaction:
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(
+			ctx,
+			`MATCH (source:Source) WHERE elementId(source) = $id
+			SET source.consecutive_failures = coalesce(source.consecutive_failures, 0) + $increment
+			RETURN source.consecutive_failures AS consecutive_failures`,
+			map[string]any{"id": sourceId, "increment": failureIncrement})
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return nil, err
+		}
+		consecutiveFailures, _, err := neo4j.GetRecordValue[int64](record, "consecutive_failures")
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Run(
+			ctx,
+			`MATCH (source:Source) WHERE elementId(source) = $id
+			SET
+				source.last_failure = $at,
+				source.last_error = $last_error,
+				source.next_attempt = $next_attempt,
+				source.disabled = CASE WHEN $counts_as_failure THEN $disabled ELSE coalesce(source.disabled, false) END`,
+			map[string]any{
+				"id":                sourceId,
+				"at":                at.UTC(),
+				"last_error":        lastError,
+				"next_attempt":      NextAttemptAfterFailure(int(consecutiveFailures), at, FeedHealth),
+				"disabled":          FeedHealth.DisableAfter > 0 && int(consecutiveFailures) >= FeedHealth.DisableAfter,
+				"counts_as_failure": countsAsFailure,
+			})
+		return nil, err
+	})
+
+	return err
+}
+
+// Describes why a source is unhealthy. Returns false for sources that are working:
+func RssFeedHealthReason(rssFeed RssFeed) (report RssFeedHealthReport, unhealthy bool) {
+
+	report.RssFeed = rssFeed
+
+	switch {
+	case rssFeed.Disabled:
+		report.Status = "disabled"
+		report.Reason = fmt.Sprintf(
+			"Disabled after %d consecutive failures. Last failed at %s: %s",
+			rssFeed.ConsecutiveFailures,
+			formatDateTime(rssFeed.LastFailure),
+			rssFeed.LastError,
+		)
+	case rssFeed.ConsecutiveFailures > 0:
+		report.Status = "failing"
+		report.Reason = fmt.Sprintf(
+			"%d consecutive failures, next attempt at %s. Last failed at %s: %s",
+			rssFeed.ConsecutiveFailures,
+			formatDateTime(rssFeed.NextAttempt),
+			formatDateTime(rssFeed.LastFailure),
+			rssFeed.LastError,
+		)
+	default:
+		return report, false
+	}
+
+	if !rssFeed.LastSuccess.IsZero() {
+		report.Reason += fmt.Sprintf(". Last succeeded at %s", formatDateTime(rssFeed.LastSuccess))
+	} else {
+		report.Reason += ". Has never succeeded"
+	}
+
+	return report, true
+}
+
+// Querying the database for every source, of every type, that is failing or has been disabled, the most
+// failures first:
+func GetUnhealthySources(ctx context.Context, driver neo4j.DriverWithContext) (reports []RssFeedHealthReport, err error) {
+
+	sources, err := GetAllSources(ctx, driver)
+	if err != nil {
+		return reports, err
+	}
+
+	reports = []RssFeedHealthReport{}
+	for _, source := range sources {
+		rssFeed := RssFeed{Id: source.Id, Title: source.Name}
+		if source.Type == "rss" {
+			rssFeed = RssFeedFromNode(source.Node)
+		} else {
+			readSourceHealth(source.Properties, &rssFeed)
+		}
+		if report, unhealthy := RssFeedHealthReason(rssFeed); unhealthy {
+			report.Type = source.Type
+			reports = append(reports, report)
+		}
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].RssFeed.ConsecutiveFailures > reports[j].RssFeed.ConsecutiveFailures
+	})
+
+	return reports, nil
+}
@@ -0,0 +1,319 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	jsonfeed "github.com/mmcdole/gofeed/json"
+	"golang.org/x/net/html/charset"
+)
+
+var ErrFeedParse = errors.New("unable to parse the feed")
+
+var (
+	xmlDeclarationEncoding = regexp.MustCompile(`^(<\?xml[^>]*?encoding\s*=\s*["'])([^"']*)(["'])`)
+	characterRef           = regexp.MustCompile(`&#([xX][0-9a-fA-F]+|[0-9]+);`)
+	feedItemEnd            = regexp.MustCompile(`</(item|entry|[A-Za-z0-9_]+:item)\s*>`)
+)
+
+// A parsed feed along with the Atom or JSON Feed document gofeed translated it from. The format specific
+// document keeps the fields that have no universal equivalent (see RssEntryFromParsedFeed). Atom and Json
+// are nil for rss feeds:
+type ParsedFeed struct {
+	*gofeed.Feed
+	Atom *atom.Feed
+	Json *jsonfeed.Feed
+}
+
+// Wraps one of gofeed's translators to hold on to the format specific feed it was given:
+type originalFeedTranslator struct {
+	translator gofeed.Translator
+	original   any
+}
+
+func (translator *originalFeedTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	translator.original = feed
+	return translator.translator.Translate(feed)
+}
+
+func parseFeedBody(body []byte) (*ParsedFeed, error) {
+
+	atomTranslator := &originalFeedTranslator{translator: &gofeed.DefaultAtomTranslator{}}
+	jsonTranslator := &originalFeedTranslator{translator: &gofeed.DefaultJSONTranslator{}}
+
+	fp := gofeed.NewParser()
+	fp.AtomTranslator = atomTranslator
+	fp.JSONTranslator = jsonTranslator
+
+	feed, err := fp.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	parsedFeed := &ParsedFeed{Feed: feed}
+	parsedFeed.Atom, _ = atomTranslator.original.(*atom.Feed)
+	parsedFeed.Json, _ = jsonTranslator.original.(*jsonfeed.Feed)
+
+	return parsedFeed, nil
+}
+
+// Parses a feed, repairing the breakage we commonly see in the wild rather than failing on it. The body is
+// first made readable (byte order marks, encoding declarations that don't match the bytes, invalid UTF-8).
+// If it still can't be parsed the markup is repaired (text before the document, control characters,
+// unescaped "<") and, as a last resort, a truncated document is cut back to its last complete item. Every
+// repair that was needed is returned as a warning. Documents that can't be repaired fail with
+// ErrFeedParse:
+func ParseFeed(body []byte) (feed *ParsedFeed, warnings []string, err error) {
+
+	body, warnings = repairFeedEncoding(body)
+
+	feed, err = parseFeedBody(body)
+	if err == nil {
+		return feed, warnings, nil
+	}
+	if errors.Is(err, gofeed.ErrFeedTypeNotDetected) && !looksLikeXml(body) {
+		return nil, warnings, fmt.Errorf("%w: the response is not an rss, atom or json feed", ErrFeedParse)
+	}
+
+	body, markupWarnings := repairFeedMarkup(body)
+	warnings = append(warnings, markupWarnings...)
+	if len(markupWarnings) > 0 {
+		feed, err = parseFeedBody(body)
+		if err == nil {
+			return feed, warnings, nil
+		}
+	}
+
+	if strings.Contains(err.Error(), "unexpected EOF") {
+		if repairedBody, truncationWarning, ok := repairTruncatedFeed(body); ok {
+			feed, truncatedErr := parseFeedBody(repairedBody)
+			if truncatedErr == nil {
+				return feed, append(warnings, truncationWarning), nil
+			}
+		}
+	}
+
+	return nil, warnings, fmt.Errorf("%w: %v", ErrFeedParse, err)
+}
+
+// Strips a UTF-8 byte order mark, rewrites encoding declarations that are unknown or that claim a single
+// byte encoding for a body that is valid UTF-8, and replaces invalid UTF-8 in bodies that don't declare
+// another encoding:
+func repairFeedEncoding(body []byte) ([]byte, []string) {
+
+	warnings := []string{}
+
+	if bytes.HasPrefix(body, []byte("\xef\xbb\xbf")) {
+		body = body[3:]
+		warnings = append(warnings, "Removed a UTF-8 byte order mark from the start of the feed")
+	}
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) != len(body) && bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		body = trimmed
+		warnings = append(warnings, "Removed whitespace before the feed's XML declaration")
+	}
+
+	declared := ""
+	if match := xmlDeclarationEncoding.FindSubmatch(body); match != nil {
+		declared = strings.ToLower(strings.TrimSpace(string(match[2])))
+	}
+	validUtf8 := utf8.Valid(body)
+
+	if declared != "" {
+		encoding, name := charset.Lookup(declared)
+		switch {
+		case encoding == nil && validUtf8:
+			body = setXmlDeclarationEncoding(body, "UTF-8")
+			warnings = append(warnings, fmt.Sprintf("The feed declared the unknown encoding %q, read it as UTF-8", declared))
+			return body, warnings
+		case encoding != nil && name != "utf-8" && !strings.HasPrefix(name, "utf-16") && validUtf8 && hasMultiByteRunes(body):
+			body = setXmlDeclarationEncoding(body, "UTF-8")
+			warnings = append(warnings, fmt.Sprintf("The feed declared the encoding %q but is valid UTF-8, read it as UTF-8", declared))
+			return body, warnings
+		case encoding != nil && name != "utf-8":
+			return body, warnings
+		}
+	}
+
+	if !validUtf8 {
+		body = bytes.ToValidUTF8(body, []byte("�"))
+		warnings = append(warnings, "Replaced invalid UTF-8 in the feed")
+	}
+
+	return body, warnings
+}
+
+// Removes anything before the start of the document, control characters and references to characters
+// that XML doesn't allow, and escapes "<" characters that don't start markup. CDATA sections are left
+// as they are apart from control characters:
+func repairFeedMarkup(body []byte) ([]byte, []string) {
+
+	warnings := []string{}
+
+	if start := bytes.IndexByte(body, '<'); start > 0 && len(bytes.TrimSpace(body[:start])) > 0 {
+		body = body[start:]
+		warnings = append(warnings, fmt.Sprintf("Removed %d bytes of text before the start of the feed", start))
+	}
+
+	// Done byte by byte as the body may not be UTF-8, control characters are the same in every encoding a
+	// feed can be read in:
+	withoutControl := make([]byte, 0, len(body))
+	for _, c := range body {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			continue
+		}
+		withoutControl = append(withoutControl, c)
+	}
+	controlCharacters := len(body) - len(withoutControl)
+	body = withoutControl
+	if controlCharacters > 0 {
+		warnings = append(warnings, fmt.Sprintf("Removed %d control characters that XML does not allow", controlCharacters))
+	}
+
+	var repaired bytes.Buffer
+	characterRefs, unescaped := 0, 0
+	for len(body) > 0 {
+		cdataStart := bytes.Index(body, []byte("<![CDATA["))
+		markup := body
+		if cdataStart >= 0 {
+			markup = body[:cdataStart]
+		}
+
+		markup = characterRef.ReplaceAllFunc(markup, func(ref []byte) []byte {
+			if isXmlCharacterRef(ref) {
+				return ref
+			}
+			characterRefs++
+			return nil
+		})
+		for i, c := range markup {
+			if c == '<' && !startsMarkup(markup[i+1:]) {
+				repaired.WriteString("&lt;")
+				unescaped++
+				continue
+			}
+			repaired.WriteByte(c)
+		}
+
+		if cdataStart < 0 {
+			break
+		}
+		body = body[cdataStart:]
+		cdataEnd := bytes.Index(body, []byte("]]>"))
+		if cdataEnd < 0 {
+			repaired.Write(body)
+			break
+		}
+		repaired.Write(body[:cdataEnd+3])
+		body = body[cdataEnd+3:]
+	}
+	if characterRefs > 0 {
+		warnings = append(warnings, fmt.Sprintf("Removed %d references to characters that XML does not allow", characterRefs))
+	}
+	if unescaped > 0 {
+		warnings = append(warnings, fmt.Sprintf("Escaped %d unescaped \"<\" characters", unescaped))
+	}
+
+	return repaired.Bytes(), warnings
+}
+
+// Cuts a truncated feed back to the end of its last complete item and closes the elements that were still
+// open there:
+func repairTruncatedFeed(body []byte) ([]byte, string, bool) {
+
+	itemEnds := feedItemEnd.FindAllIndex(body, -1)
+	if len(itemEnds) == 0 {
+		return nil, "", false
+	}
+	cut := itemEnds[len(itemEnds)-1][1]
+
+	decoder := xml.NewDecoder(bytes.NewReader(body[:cut]))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	openElements := []string{}
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			openElements = append(openElements, xmlElementName(element.Name))
+		case xml.EndElement:
+			if len(openElements) > 0 {
+				openElements = openElements[:len(openElements)-1]
+			}
+		}
+	}
+
+	repaired := bytes.NewBuffer(append([]byte{}, body[:cut]...))
+	for i := len(openElements) - 1; i >= 0; i-- {
+		fmt.Fprintf(repaired, "</%s>", openElements[i])
+	}
+
+	return repaired.Bytes(), fmt.Sprintf("The feed was truncated, dropped %d bytes after its last complete item", len(body)-cut), true
+}
+
+// Character references are valid if they refer to a character XML allows in a document:
+func isXmlCharacterRef(ref []byte) bool {
+
+	number := string(ref[2 : len(ref)-1])
+	base := 10
+	if number[0] == 'x' || number[0] == 'X' {
+		number, base = number[1:], 16
+	}
+	codePoint, err := strconv.ParseInt(number, base, 32)
+	if err != nil {
+		return false
+	}
+
+	return codePoint == 0x9 || codePoint == 0xA || codePoint == 0xD ||
+		(codePoint >= 0x20 && codePoint <= 0xD7FF) ||
+		(codePoint >= 0xE000 && codePoint <= 0xFFFD) ||
+		(codePoint >= 0x10000 && codePoint <= 0x10FFFF)
+}
+
+func setXmlDeclarationEncoding(body []byte, encoding string) []byte {
+	return xmlDeclarationEncoding.ReplaceAll(body, []byte("${1}"+encoding+"${3}"))
+}
+
+func xmlElementName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func startsMarkup(rest []byte) bool {
+	if len(rest) == 0 {
+		return false
+	}
+	c := rest[0]
+	return c == '/' || c == '!' || c == '?' || c == '_' || c == ':' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func hasMultiByteRunes(body []byte) bool {
+	for _, c := range body {
+		if c >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
+
+func looksLikeXml(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		return true
+	}
+	lower := bytes.ToLower(trimmed)
+	return bytes.Contains(lower, []byte("<rss")) || bytes.Contains(lower, []byte("<feed")) || bytes.Contains(lower, []byte("<rdf:rdf"))
+}
@@ -0,0 +1,337 @@
+package parsers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gocolly/colly/v2"
+)
+
+// Settings shared by every outbound request made while ingesting content (rss feeds, html pages and
+// images):
+type FetcherConfig struct {
+	UserAgent string
+
+	// How long to wait for a connection, for the response headers once the request has been sent, and for
+	// a whole attempt including reading the body:
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	RequestTimeout time.Duration
+
+	// Failed connections, 5xx and 429 responses are retried up to MaxRetries times. The delay before each
+	// retry doubles from RetryBaseDelay with random jitter, unless the server sent a Retry-After header, and
+	// is never longer than MaxRetryDelay:
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	MaxRetryDelay  time.Duration
+
+	// Responses larger than MaxBodyBytes once decompressed fail with ErrBodyTooLarge:
+	MaxBodyBytes int64
+	MaxRedirects int
+
+	// Politeness towards the sites we fetch from (see politeTransport). Requests to a host start at least
+	// DomainDelay apart, or the host's robots.txt Crawl-delay up to MaxCrawlDelay, and no more than
+	// MaxDomainConcurrency run at once. robots.txt files are cached for RobotsCacheTtl, or RobotsRetryAfter
+	// if they couldn't be fetched, during which the host's urls are all disallowed:
+	RespectRobots        bool
+	RobotsCacheTtl       time.Duration
+	RobotsRetryAfter     time.Duration
+	DomainDelay          time.Duration
+	MaxCrawlDelay        time.Duration
+	MaxDomainConcurrency int
+}
+
+var DefaultFetcherConfig = FetcherConfig{
+	UserAgent:      "knowledge_base/1.0 (+https://github.com/MatthewTe/knowledge_base)",
+	ConnectTimeout: 10 * time.Second,
+	ReadTimeout:    30 * time.Second,
+	RequestTimeout: 2 * time.Minute,
+	MaxRetries:     3,
+	RetryBaseDelay: 500 * time.Millisecond,
+	MaxRetryDelay:  30 * time.Second,
+	MaxBodyBytes:   50 * 1024 * 1024,
+	MaxRedirects:   10,
+
+	RespectRobots:        true,
+	RobotsCacheTtl:       24 * time.Hour,
+	RobotsRetryAfter:     5 * time.Minute,
+	DomainDelay:          time.Second,
+	MaxCrawlDelay:        time.Minute,
+	MaxDomainConcurrency: 2,
+}
+
+var ErrBodyTooLarge = errors.New("response body exceeds the maximum size")
+
+// An http client configured from a FetcherConfig. The retries, compression and size limits are done by its
+// round tripper so they also apply to clients we don't build ourselves, like the one inside colly:
+type Fetcher struct {
+	Config    FetcherConfig
+	Client    *http.Client
+	Transport http.RoundTripper
+}
+
+// The fetcher used for all ingestion requests. Replaced in main once the command line flags are parsed:
+var DefaultFetcher = NewFetcher(DefaultFetcherConfig)
+
+func NewFetcher(config FetcherConfig) *Fetcher {
+
+	baseTransport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   config.ConnectTimeout,
+		ResponseHeaderTimeout: config.ReadTimeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		// Compression is handled by the fetcherTransport so that brotli is supported as well as gzip:
+		DisableCompression: true,
+	}
+
+	// Each retry goes through the polite transport so that it waits its turn like any other request:
+	transport := &fetcherTransport{config: config, base: newPoliteTransport(config, baseTransport), sleep: sleepContext}
+
+	return &Fetcher{
+		Config:    config,
+		Transport: transport,
+		Client: &http.Client{
+			Transport:     transport,
+			Timeout:       config.RequestTimeout,
+			CheckRedirect: redirectLimit(config.MaxRedirects),
+		},
+	}
+}
+
+// Makes a GET request for a url:
+func (fetcher *Fetcher) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fetcher.Do(req)
+}
+
+// Sends a request. Like http.Client.Do a non 2xx status code is not an error, callers check the status:
+func (fetcher *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	return fetcher.Client.Do(req)
+}
+
+// Makes a colly collector send its requests through the fetcher with the same user agent, timeouts and
+// limits:
+func (fetcher *Fetcher) ConfigureCollector(collector *colly.Collector) {
+	collector.UserAgent = fetcher.Config.UserAgent
+	collector.MaxBodySize = int(fetcher.Config.MaxBodyBytes)
+	collector.WithTransport(fetcher.Transport)
+	collector.SetRequestTimeout(fetcher.Config.RequestTimeout)
+	collector.SetRedirectHandler(redirectLimit(fetcher.Config.MaxRedirects))
+}
+
+func redirectLimit(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+}
+
+type fetcherTransport struct {
+	config FetcherConfig
+	base   http.RoundTripper
+	sleep  func(ctx context.Context, delay time.Duration) error
+}
+
+func (transport *fetcherTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+
+	// The request is cloned as a RoundTripper must not modify the request it was given:
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", transport.config.UserAgent)
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	}
+
+	for attempt := 0; ; attempt++ {
+
+		if attempt > 0 && req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err = transport.base.RoundTrip(req)
+		if attempt >= transport.config.MaxRetries || !shouldRetryRequest(req, resp, err) {
+			break
+		}
+
+		delay := retryDelay(attempt, resp, transport.config, time.Now())
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		if sleepErr := transport.sleep(req.Context(), delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.prepareBody(resp)
+}
+
+// Connection errors, 5xx and 429 responses are retried unless the request was cancelled or has a body
+// that can't be sent again:
+func shouldRetryRequest(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// Returns how long to wait before retrying. A Retry-After header (in seconds or as an http date) is
+// honoured, otherwise the delay is exponential with full jitter. Both are capped at MaxRetryDelay:
+func retryDelay(attempt int, resp *http.Response, config FetcherConfig, now time.Time) time.Duration {
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			if retryAfter > config.MaxRetryDelay {
+				return config.MaxRetryDelay
+			}
+			return retryAfter
+		}
+	}
+
+	backoff := config.RetryBaseDelay
+	for i := 0; i < attempt && backoff < config.MaxRetryDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > config.MaxRetryDelay {
+		backoff = config.MaxRetryDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff)) + 1)
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if retryAt, err := http.ParseTime(value); err == nil {
+		if retryAt.Before(now) {
+			return 0, true
+		}
+		return retryAt.Sub(now), true
+	}
+
+	return 0, false
+}
+
+// Decompresses the response body if the server compressed it and limits it to MaxBodyBytes:
+func (transport *fetcherTransport) prepareBody(resp *http.Response) (*http.Response, error) {
+
+	if transport.config.MaxBodyBytes > 0 && resp.ContentLength > transport.config.MaxBodyBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes", ErrBodyTooLarge, resp.Request.URL, resp.ContentLength)
+	}
+
+	var decodedBody io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		decodedBody = gzipReader
+	case "deflate":
+		decodedBody = flate.NewReader(resp.Body)
+	case "br":
+		decodedBody = brotli.NewReader(resp.Body)
+	}
+
+	if decodedBody != nil {
+		resp.Body = &limitedBody{reader: decodedBody, closer: resp.Body, limit: transport.config.MaxBodyBytes}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	} else {
+		resp.Body = &limitedBody{reader: resp.Body, closer: resp.Body, limit: transport.config.MaxBodyBytes}
+	}
+
+	return resp, nil
+}
+
+// A response body that fails with ErrBodyTooLarge once more than limit bytes have been read. A limit of
+// zero or less is no limit:
+type limitedBody struct {
+	reader io.Reader
+	closer io.Closer
+	limit  int64
+	read   int64
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+
+	if body.limit <= 0 {
+		return body.reader.Read(p)
+	}
+	if body.read > body.limit {
+		return 0, ErrBodyTooLarge
+	}
+
+	// Reading one byte past the limit tells a body that is exactly the limit apart from a larger one:
+	if remaining := body.limit - body.read; int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := body.reader.Read(p)
+	body.read += int64(n)
+	if body.read > body.limit {
+		return n - int(body.read-body.limit), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (body *limitedBody) Close() error {
+	return body.closer.Close()
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
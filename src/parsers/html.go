@@ -3,8 +3,6 @@ package parsers
 import (
 	"fmt"
 	"io"
-	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,75 +12,113 @@ import (
 	"golang.org/x/net/context"
 )
 
+// A web page loaded with LoadHtmlPage. HtmlPage is the path of the page written to the temp directory and
+// Images the paths of its downloaded images, with the url each image was downloaded from at the same index
+// of ImageUrls:
 type HtmlContent struct {
-	Url      string
-	HtmlPage string
-	Tables   []string
-	Images   []string
-	Snapshot []string
+	Url        string
+	HtmlPage   string
+	Tables     []string
+	Images     []string
+	ImageUrls  []string
+	Snapshot   []string
+	Charset    string
+	StatusCode int
 }
 
-func (htmlContent *HtmlContent) LoadHtmlPage() {
+// Loads the page and its images into the temp directory. Images that fail to download are skipped. Returns
+// an error if the page itself couldn't be loaded:
+func (htmlContent *HtmlContent) LoadHtmlPage() error {
 	c := colly.NewCollector()
+	DefaultFetcher.ConfigureCollector(c)
+
+	var loadErr error
+	seenImages := map[string]bool{}
 
 	// Load the whole HTML page:
 	c.OnHTML("html", func(e *colly.HTMLElement) {
-		wd, err := os.Getwd()
-		if err != nil {
-			log.Fatal("Could not get current working directory path", err)
+		htmlContent.StatusCode = e.Response.StatusCode
+
+		// Pages are stored as UTF-8. colly has already transcoded pages whose Content-Type header names a
+		// charset so those only need their <meta> charset rewriting, the rest are detected and transcoded:
+		pageBody := e.Response.Body
+		var err error
+		if headerCharset := ContentTypeCharset(e.Response.Headers.Get("Content-Type")); headerCharset != "" {
+			htmlContent.Charset, _ = DetectCharset(nil, e.Response.Headers.Get("Content-Type"))
+			pageBody = SetUtf8Declarations(pageBody)
+		} else {
+			pageBody, htmlContent.Charset, err = DecodeToUtf8(pageBody, "")
+			if err != nil {
+				fmt.Println("Unable to transcode", htmlContent.Url, "to UTF-8, storing it as it was served:", err)
+			}
 		}
 
-		parentDir := filepath.Dir(wd)
-		tempFileName := filepath.Join(parentDir, "temp", "test_file.html")
-
-		err = os.WriteFile(tempFileName, e.Response.Body, 0666)
+		// Every page gets its own temp file so pages loaded at the same time don't overwrite each other:
+		tempFile, err := os.CreateTemp(captureTempDir(), "page-*.html")
 		if err != nil {
-			log.Fatal("Unable to write extracted text to html file in temp dir", err)
-		} else {
-			fmt.Println("Wrote ", tempFileName, "to temporary file system storage")
-
-			// Finally add the uploaded html to the struct array:
-			htmlContent.HtmlPage = tempFileName
+			loadErr = fmt.Errorf("unable to create a temp file for %s: %w", htmlContent.Url, err)
+			return
+		}
+		defer tempFile.Close()
 
+		_, err = tempFile.Write(pageBody)
+		if err != nil {
+			loadErr = fmt.Errorf("unable to write %s to the temp dir: %w", htmlContent.Url, err)
+			return
 		}
+		fmt.Println("Wrote ", tempFile.Name(), "to temporary file system storage")
 
+		// Finally add the uploaded html to the struct array:
+		htmlContent.HtmlPage = tempFile.Name()
 	})
 
-	// Extract images from an html:
+	// Extract images from an html. Relative image urls are resolved against the page:
 	c.OnHTML("body img", func(e *colly.HTMLElement) {
-		imagePath := e.Attr("src")
+		imagePath := e.Request.AbsoluteURL(strings.TrimSpace(e.Attr("src")))
+		if !strings.HasPrefix(imagePath, "http://") && !strings.HasPrefix(imagePath, "https://") {
+			return
+		}
+		if seenImages[imagePath] {
+			return
+		}
+		seenImages[imagePath] = true
 
 		fmt.Println(imagePath)
 
-		resp, err := http.Get(imagePath)
+		resp, err := DefaultFetcher.Get(context.Background(), imagePath)
 		if err != nil {
-			log.Fatal("Error downloading image:", err)
+			fmt.Println("Error downloading image:", imagePath, err)
+			return
 		}
 		defer resp.Body.Close()
-		imageData, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal("Could not extract image data into byte array", err)
+		if resp.StatusCode > 300 {
+			fmt.Println("Error downloading image:", imagePath, "returned status code", resp.StatusCode)
+			return
 		}
-
-		wd, err := os.Getwd()
+		imageData, err := io.ReadAll(resp.Body)
 		if err != nil {
-			log.Fatal("Could not get current working directory path", err)
+			fmt.Println("Could not extract image data into byte array", imagePath, err)
+			return
 		}
 
 		// Read the image data
 		fileName := extractFileName(imagePath)
-		parentDir := filepath.Dir(wd)
-		tempFileName := filepath.Join(parentDir, "temp", fileName)
-		err = os.WriteFile(tempFileName, imageData, 0666)
+		tempFile, err := os.CreateTemp(captureTempDir(), "*-"+fileName)
 		if err != nil {
-			log.Fatal("Error reading image data into temp directory", err)
-		} else {
-			fmt.Println("Wrote", tempFileName, "to temporary file system storage.")
-
-			// Finally add the uploaded image to the struct array:
-			htmlContent.Images = append(htmlContent.Images, tempFileName)
+			fmt.Println("Error creating a temp file for image", imagePath, err)
+			return
 		}
+		defer tempFile.Close()
+		if _, err = tempFile.Write(imageData); err != nil {
+			fmt.Println("Error reading image data into temp directory", err)
+			os.Remove(tempFile.Name())
+			return
+		}
+		fmt.Println("Wrote", tempFile.Name(), "to temporary file system storage.")
 
+		// Finally add the uploaded image to the struct array:
+		htmlContent.Images = append(htmlContent.Images, tempFile.Name())
+		htmlContent.ImageUrls = append(htmlContent.ImageUrls, imagePath)
 	})
 
 	// Extracting tables from html page:
@@ -93,8 +129,30 @@ func (htmlContent *HtmlContent) LoadHtmlPage() {
 
 	c.OnError(func(r *colly.Response, err error) {
 		fmt.Println("Request URL:", r.Request.URL, "failed with response:", r, "\nError:", err)
+		htmlContent.StatusCode = r.StatusCode
+		loadErr = err
 	})
-	c.Visit(htmlContent.Url)
+
+	if err := c.Visit(htmlContent.Url); err != nil {
+		return err
+	}
+	if loadErr != nil {
+		return loadErr
+	}
+	if htmlContent.HtmlPage == "" {
+		return fmt.Errorf("%s did not return an html page", htmlContent.Url)
+	}
+	return nil
+}
+
+// Removes the page and images loaded into the temp directory:
+func (htmlContent *HtmlContent) RemoveTempFiles() {
+	if htmlContent.HtmlPage != "" {
+		os.Remove(htmlContent.HtmlPage)
+	}
+	for _, imagePath := range htmlContent.Images {
+		os.Remove(imagePath)
+	}
 }
 
 func extractFileName(url string) string {
@@ -107,45 +165,7 @@ func extractFileName(url string) string {
 }
 
 func UploadHtmlFileToStatic(ctx context.Context, minioClient *minio.Client, bucketName string, bucketFilePath string, reader *os.File) (string, error) {
-
-	// Creating the client object:
-	err := minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
-
-	// First we create the bucket if it doens't exist:
-	if err != nil {
-		exists, errBucketExists := minioClient.BucketExists(ctx, bucketName)
-		if errBucketExists == nil && exists {
-			log.Printf("Bucket already exists, skipping bucket creation")
-		} else {
-			log.Println("Error in creating bucket in Minio", bucketName, err)
-			return bucketFilePath, err
-		}
-	} else {
-		log.Println("Successfully created: ", bucketName)
-	}
-
-	// Calculating the size of the byte array to be uploaded:
-	objectStat, err := reader.Stat()
-	if err != nil {
-		log.Println("Error in calculating the statistics for the file", err)
-		return bucketFilePath, err
-	}
-
-	info, err := minioClient.PutObject(
-		ctx,
-		bucketName,
-		bucketFilePath,
-		reader,
-		objectStat.Size(),
-		minio.PutObjectOptions{ContentType: "text/html"},
-	)
-	if err != nil {
-		log.Println("Unable to insert the html page into the s3 bucket", err)
-	} else {
-		log.Println("Inserted html", bucketFilePath, "of size:", info.Size, "bytes", "successfully into bucket", bucketName)
-	}
-
-	return bucketFilePath, err
+	return UploadFileToStatic(ctx, minioClient, bucketName, bucketFilePath, reader, "text/html")
 }
 
 func UploadHtmlFileToGraph() {
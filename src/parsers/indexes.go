@@ -0,0 +1,42 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// The properties that nodes are looked up by while ingesting, by the name of their index. Without them
+// every lookup scans all the nodes of the label:
+var graphIndexes = []struct {
+	name     string
+	label    string
+	property string
+}{
+	{"article_guid", "Article", "guid"},
+	{"article_canonical_url", "Article", "canonical_url"},
+	{"article_url", "Article", "url"},
+	{"web_page_canonical_url", "Web_Page", "canonical_url"},
+	{"entity_normalised_name", "Entity", "normalised_name"},
+	{"topic_name", "Topic", "name"},
+}
+
+// Creates the indexes of graphIndexes that don't exist yet. Run every time the server starts:
+func CreateIndexes(ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	for _, index := range graphIndexes {
+		_, err := neo4j.ExecuteQuery(
+			ctx,
+			driver,
+			fmt.Sprintf("CREATE INDEX %s IF NOT EXISTS FOR (n:%s) ON (n.%s)", index.name, index.label, index.property),
+			nil,
+			neo4j.EagerResultTransformer,
+			neo4j.ExecuteQueryWithDatabase("neo4j"))
+		if err != nil {
+			return fmt.Errorf("unable to create the index %s: %w", index.name, err)
+		}
+	}
+
+	return nil
+}
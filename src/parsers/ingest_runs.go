@@ -0,0 +1,252 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A single ingestion of a source. Runs are stored as Ingest_Run nodes connected to their source so that
+// the history of a feed (when it last worked, what the server returned and what was written) is kept
+// after the summary has been returned to the caller:
+type IngestRun struct {
+	Id         string              `json:"id"`
+	SourceId   string              `json:"source_id"`
+	StartTime  time.Time           `json:"start_time"`
+	EndTime    time.Time           `json:"end_time"`
+	Status     ExtractionStatus    `json:"status"`
+	Message    string              `json:"message"`
+	Error      string              `json:"error"`
+	HttpStatus int                 `json:"http_status"`
+	Etag       string              `json:"etag"`
+	Counts     RssExtractionCounts `json:"counts"`
+	Errors     []string            `json:"errors"`
+	Warnings   []string            `json:"warnings"`
+}
+
+// Creates the Ingest_Run node for an ingestion of a source of any type that is starting. The run is marked
+// as running until it is finished with FinishIngestRun:
+func StartIngestRun(sourceId string, sourceTitle string, startTime time.Time, ctx context.Context, driver neo4j.DriverWithContext) (run IngestRun, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (source:Source) WHERE elementId(source) = $source_id
+		CREATE (run:Ingest_Run {start_time: $start_time, status: $status})
+		CREATE (source)-[:HAS_INGEST_RUN]->(run)
+		RETURN run
+		`,
+		map[string]any{
+			"source_id":  sourceId,
+			"start_time": startTime.UTC(),
+			"status":     string(StatusRunning),
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return run, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find source %s to record an ingest run for", sourceTitle)
+		return run, err
+	}
+
+	runNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "run")
+	if err != nil {
+		return run, err
+	}
+	run = IngestRunFromNode(runNode)
+	run.SourceId = sourceId
+
+	return run, nil
+}
+
+// Records the outcome of an ingestion on its Ingest_Run node. The errors of the individual entries and
+// authors are kept alongside the error of the run itself, as are the repairs made to parse the feed:
+func FinishIngestRun(run IngestRun, summary RssFeedExtractionSummary, endTime time.Time, ctx context.Context, driver neo4j.DriverWithContext) (err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (run:Ingest_Run) WHERE elementId(run) = $id
+		SET
+			run.end_time = $end_time,
+			run.status = $status,
+			run.message = $message,
+			run.error = $error,
+			run.http_status = $http_status,
+			run.etag = $etag,
+			run.items_seen = $items_seen,
+			run.items_created = $items_created,
+			run.items_updated = $items_updated,
+			run.items_skipped = $items_skipped,
+			run.items_failed = $items_failed,
+			run.authors_created = $authors_created,
+			run.authors_linked = $authors_linked,
+			run.duration_ms = $duration_ms,
+			run.errors = $errors,
+			run.warnings = $warnings
+		RETURN run
+		`,
+		map[string]any{
+			"id":              run.Id,
+			"end_time":        endTime.UTC(),
+			"status":          string(summary.Status),
+			"message":         summary.Message,
+			"error":           summary.Error,
+			"http_status":     summary.HttpStatus,
+			"etag":            summary.Etag,
+			"items_seen":      summary.Counts.ItemsSeen,
+			"items_created":   summary.Counts.ItemsCreated,
+			"items_updated":   summary.Counts.ItemsUpdated,
+			"items_skipped":   summary.Counts.ItemsSkipped,
+			"items_failed":    summary.Counts.ItemsFailed,
+			"authors_created": summary.Counts.AuthorsCreated,
+			"authors_linked":  summary.Counts.AuthorsLinked,
+			"duration_ms":     summary.Counts.DurationMs,
+			"errors":          SummaryErrors(summary),
+			"warnings":        append([]string{}, summary.Warnings...),
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err
+	}
+
+	if len(result.Records) == 0 {
+		return fmt.Errorf("unable to find ingest run %s to finish", run.Id)
+	}
+
+	return nil
+}
+
+// Collects the errors of the entries, authors and media downloads of a feed extraction, each prefixed with
+// the title, name or url it belongs to:
+func SummaryErrors(summary RssFeedExtractionSummary) []string {
+
+	errors := []string{}
+	for _, entry := range summary.RssEntries {
+		if entry.Error != "" {
+			errors = append(errors, fmt.Sprintf("%s: %s", entry.Title, entry.Error))
+		}
+		for _, author := range entry.Authors {
+			if author.Error != "" {
+				errors = append(errors, fmt.Sprintf("%s (%s): %s", entry.Title, author.Name, author.Error))
+			}
+		}
+	}
+	for _, media := range summary.Media {
+		if media.Error != "" {
+			errors = append(errors, fmt.Sprintf("%s: %s", media.Url, media.Error))
+		}
+	}
+	return errors
+}
+
+// Querying the database for every ingest run of a source, newest first:
+func GetIngestRunsForSource(sourceId string, ctx context.Context, driver neo4j.DriverWithContext) (runs []IngestRun, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (source:Source) WHERE elementId(source) = $source_id
+		OPTIONAL MATCH (source)-[:HAS_INGEST_RUN]->(run:Ingest_Run)
+		WITH source, run
+		ORDER BY run.start_time DESC
+		RETURN source, collect(run) AS runs
+		`,
+		map[string]any{"source_id": sourceId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return runs, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find rss feed %s in the database", sourceId)
+		return runs, err
+	}
+
+	runNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "runs")
+	if err != nil {
+		return runs, err
+	}
+
+	runs = []IngestRun{}
+	for _, runNode := range runNodes {
+		if node, ok := runNode.(neo4j.Node); ok {
+			run := IngestRunFromNode(node)
+			run.SourceId = sourceId
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
+
+// Converts an Ingest_Run node into an IngestRun struct:
+func IngestRunFromNode(node neo4j.Node) (run IngestRun) {
+
+	nodeProps := node.GetProperties()
+
+	run.Id = node.ElementId
+	run.StartTime = timeFromNodeProperty(nodeProps, "start_time")
+	run.EndTime = timeFromNodeProperty(nodeProps, "end_time")
+
+	if status, ok := nodeProps["status"].(string); ok {
+		run.Status = ExtractionStatus(status)
+	}
+	if message, ok := nodeProps["message"].(string); ok {
+		run.Message = message
+	}
+	if runError, ok := nodeProps["error"].(string); ok {
+		run.Error = runError
+	}
+	if etag, ok := nodeProps["etag"].(string); ok {
+		run.Etag = etag
+	}
+
+	intProperty := func(key string) int {
+		value, _ := nodeProps[key].(int64)
+		return int(value)
+	}
+	run.HttpStatus = intProperty("http_status")
+	run.Counts = RssExtractionCounts{
+		ItemsSeen:      intProperty("items_seen"),
+		ItemsCreated:   intProperty("items_created"),
+		ItemsUpdated:   intProperty("items_updated"),
+		ItemsSkipped:   intProperty("items_skipped"),
+		ItemsFailed:    intProperty("items_failed"),
+		AuthorsCreated: intProperty("authors_created"),
+		AuthorsLinked:  intProperty("authors_linked"),
+	}
+	if durationMs, ok := nodeProps["duration_ms"].(int64); ok {
+		run.Counts.DurationMs = durationMs
+	}
+
+	run.Errors = []string{}
+	if errors, ok := nodeProps["errors"].([]any); ok {
+		for _, runError := range errors {
+			if errorMessage, ok := runError.(string); ok {
+				run.Errors = append(run.Errors, errorMessage)
+			}
+		}
+	}
+
+	run.Warnings = []string{}
+	if warnings, ok := nodeProps["warnings"].([]any); ok {
+		for _, warning := range warnings {
+			if warningMessage, ok := warning.(string); ok {
+				run.Warnings = append(run.Warnings, warningMessage)
+			}
+		}
+	}
+
+	return run
+}
@@ -0,0 +1,391 @@
+package parsers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/minio/minio-go/v7"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A link from the body of an article to another page. Links to the same page (see CanonicalizeUrl) are
+// recorded once with the number of times they appear and the first anchor text that isn't empty:
+type ArticleLink struct {
+	Url          string `json:"url"`
+	CanonicalUrl string `json:"canonical_url"`
+	Domain       string `json:"domain"`
+	AnchorText   string `json:"anchor_text"`
+	Count        int    `json:"count"`
+}
+
+// A page that an article links to or that links to an article. Type is "article" for pages that have
+// been ingested and "web_page" for the Web_Page stubs of pages that haven't:
+type LinkedPage struct {
+	Id         string `json:"id"`
+	Type       string `json:"type"`
+	Url        string `json:"url"`
+	Title      string `json:"title"`
+	Domain     string `json:"domain"`
+	AnchorText string `json:"anchor_text"`
+	Count      int    `json:"count"`
+}
+
+// The links of an article in both directions:
+type ArticleLinks struct {
+	Outbound []LinkedPage `json:"outbound"`
+	Inbound  []LinkedPage `json:"inbound"`
+}
+
+// A page or domain outside of the archive along with the number of articles that link to it:
+type CitedSource struct {
+	Domain   string `json:"domain"`
+	Url      string `json:"url,omitempty"`
+	Articles int64  `json:"articles"`
+	Links    int64  `json:"links"`
+	Pages    int64  `json:"pages,omitempty"`
+}
+
+// The elements that hold the body of an article, most specific first. Links outside of the body (the
+// site's navigation, footer, sidebars etc.) are ignored:
+var articleBodySelectors = []string{`[itemprop="articleBody"]`, "article", "main", "body"}
+
+// Elements inside an article body whose links aren't part of the article:
+const articleChromeSelector = "script, style, noscript, template, nav, header, footer, aside, form"
+
+// Reads the links in the body of an article page. Relative links are resolved against the page (or its
+// <base>), and only http(s) links to other pages are kept:
+func ExtractArticleLinks(pageUrl string, body []byte) (links []ArticleLink, err error) {
+
+	links = []ArticleLink{}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return links, err
+	}
+
+	baseUrl := pageUrl
+	if baseHref, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if resolvedBase := resolvePageUrl(pageUrl, baseHref); resolvedBase != "" {
+			baseUrl = resolvedBase
+		}
+	}
+
+	articleBody := findArticleBody(doc)
+	if articleBody == nil {
+		return links, nil
+	}
+
+	pageCanonicalUrl := CanonicalizeUrl(pageUrl)
+	linkIndex := map[string]int{}
+	articleBody.Find("a[href]").Each(func(i int, anchor *goquery.Selection) {
+
+		// Links to a part of the page itself:
+		href, _ := anchor.Attr("href")
+		if strings.HasPrefix(strings.TrimSpace(href), "#") {
+			return
+		}
+		linkUrl := resolvePageUrl(baseUrl, href)
+		if linkUrl == "" {
+			return
+		}
+		canonicalUrl := CanonicalizeUrl(linkUrl)
+		if canonicalUrl == pageCanonicalUrl {
+			return
+		}
+
+		anchorText := strings.Join(strings.Fields(anchor.Text()), " ")
+		if anchorText == "" {
+			anchorText, _ = anchor.Find("img[alt]").First().Attr("alt")
+		}
+		if anchorText == "" {
+			anchorText, _ = anchor.Attr("title")
+		}
+		anchorText = strings.TrimSpace(anchorText)
+
+		if index, seen := linkIndex[canonicalUrl]; seen {
+			links[index].Count++
+			if links[index].AnchorText == "" {
+				links[index].AnchorText = anchorText
+			}
+			return
+		}
+		linkIndex[canonicalUrl] = len(links)
+		links = append(links, ArticleLink{
+			Url:          linkUrl,
+			CanonicalUrl: canonicalUrl,
+			Domain:       linkDomain(linkUrl),
+			AnchorText:   anchorText,
+			Count:        1,
+		})
+	})
+
+	return links, nil
+}
+
+// Returns the element holding the body of an article (see articleBodySelectors) with the site's chrome
+// removed, or nil if the page has no body:
+func findArticleBody(doc *goquery.Document) *goquery.Selection {
+
+	for _, selector := range articleBodySelectors {
+		if selection := doc.Find(selector).First(); selection.Length() > 0 {
+			selection.Find(articleChromeSelector).Remove()
+			return selection
+		}
+	}
+
+	return nil
+}
+
+// The host of a url without its www. prefix, used to group the pages of a site:
+func linkDomain(linkUrl string) string {
+	parsedUrl, err := url.Parse(linkUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsedUrl.Hostname()), "www.")
+}
+
+// Replaces the LINKS_TO relationships of an article with $links. Each link goes to the Article with the
+// same canonical url, if one has been ingested, or else to a Web_Page stub for the url that is shared by
+// every article linking to it. Stubs are replaced by the article when the page is ingested later (see
+// createArticlesQuery):
+const writeArticleLinksQuery = `
+MATCH (article:Article) WHERE elementId(article) = $article_id
+OPTIONAL MATCH (article)-[old:LINKS_TO]->()
+DELETE old
+WITH DISTINCT article
+UNWIND $links AS link
+OPTIONAL MATCH (existing:Article) WHERE existing.canonical_url = link.canonical_url AND existing <> article
+WITH article, link, head(collect(existing)) AS existing
+FOREACH (_ IN CASE WHEN existing IS NULL THEN [1] ELSE [] END |
+	MERGE (stub:Web_Page {canonical_url: link.canonical_url})
+	ON CREATE SET
+		stub.url = link.url,
+		stub.domain = link.domain,
+		stub.created = datetime({timezone: 'UTC'})
+)
+WITH article, link, existing
+OPTIONAL MATCH (stub:Web_Page {canonical_url: link.canonical_url})
+WITH article, link, coalesce(existing, stub) AS target
+CREATE (article)-[:LINKS_TO {
+	url: link.url,
+	anchor_text: link.anchor_text,
+	count: link.count
+}]->(target)
+RETURN count(target) AS links
+`
+
+// Records the links in the body of an article's page as LINKS_TO relationships (see ExtractArticleLinks
+// and writeArticleLinksQuery). Returns the links that were recorded:
+func RecordArticleLinks(articleId string, page ArticlePage, ctx context.Context, driver neo4j.DriverWithContext) (links []ArticleLink, err error) {
+
+	links, err = ExtractArticleLinks(page.Url, page.Body)
+	if err != nil {
+		return links, err
+	}
+
+	linkRows := make([]map[string]any, len(links))
+	for i, link := range links {
+		linkRows[i] = map[string]any{
+			"url":           link.Url,
+			"canonical_url": link.CanonicalUrl,
+			"domain":        link.Domain,
+			"anchor_text":   link.AnchorText,
+			"count":         link.Count,
+		}
+	}
+
+	_, err = neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		writeArticleLinksQuery,
+		map[string]any{"article_id": articleId, "links": linkRows},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+
+	return links, err
+}
+
+// Records the links of an article that has already been ingested. The page is read from object storage
+// if it has been captured and otherwise fetched from its site:
+func RefreshArticleLinks(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (links []ArticleLink, err error) {
+
+	rssEntry, err := GetRssArticleById(articleId, ctx, driver)
+	if err != nil {
+		return links, err
+	}
+
+	page := ArticlePage{Url: rssEntry.Url}
+	switch {
+	case rssEntry.InStorage == 1 && PageCaptures.Client != nil:
+		page.Body, err = readCapturedPage(rssEntry.StorageUrl, ctx)
+		if err != nil {
+			return links, err
+		}
+	case strings.HasPrefix(rssEntry.Url, "http://") || strings.HasPrefix(rssEntry.Url, "https://"):
+		requestCtx, err := articlePageContext(rssEntry.Id, ctx, driver)
+		if err != nil {
+			return links, err
+		}
+		page, err = FetchArticlePage(requestCtx, rssEntry.Url)
+		if err != nil {
+			return links, newSourceError(StatusErrorFetch, "Unable to fetch the page of the article", err)
+		}
+	default:
+		return links, ErrNoArticlePage
+	}
+
+	return RecordArticleLinks(rssEntry.Id, page, ctx, driver)
+}
+
+func readCapturedPage(storageUrl string, ctx context.Context) ([]byte, error) {
+
+	object, err := PageCaptures.Client.GetObject(ctx, PageCaptures.Bucket, storageUrl, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+// Returns the pages an article links to and the articles that link to it:
+func GetArticleLinks(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (articleLinks ArticleLinks, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $id
+		CALL {
+			WITH article
+			OPTIONAL MATCH (article)-[link:LINKS_TO]->(target)
+			WITH link, target ORDER BY link.count DESC, link.url
+			RETURN collect(CASE WHEN target IS NULL THEN NULL ELSE {
+				id: elementId(target),
+				type: CASE WHEN target:Article THEN 'article' ELSE 'web_page' END,
+				url: target.url,
+				title: coalesce(target.name, ''),
+				domain: coalesce(target.domain, ''),
+				anchor_text: coalesce(link.anchor_text, ''),
+				count: coalesce(link.count, 1)
+			} END) AS outbound
+		}
+		CALL {
+			WITH article
+			OPTIONAL MATCH (citing:Article)-[link:LINKS_TO]->(article)
+			WITH link, citing ORDER BY citing.date_posted DESC
+			RETURN collect(CASE WHEN citing IS NULL THEN NULL ELSE {
+				id: elementId(citing),
+				type: 'article',
+				url: citing.url,
+				title: coalesce(citing.name, ''),
+				domain: '',
+				anchor_text: coalesce(link.anchor_text, ''),
+				count: coalesce(link.count, 1)
+			} END) AS inbound
+		}
+		RETURN outbound, inbound`,
+		map[string]any{"id": articleId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return articleLinks, err
+	}
+	if len(result.Records) == 0 {
+		return articleLinks, fmt.Errorf("%w: %s", ErrArticleNotFound, articleId)
+	}
+
+	outbound, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "outbound")
+	if err != nil {
+		return articleLinks, err
+	}
+	inbound, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "inbound")
+	if err != nil {
+		return articleLinks, err
+	}
+	articleLinks.Outbound = linkedPagesFromMaps(outbound)
+	articleLinks.Inbound = linkedPagesFromMaps(inbound)
+	for i, linkedPage := range articleLinks.Outbound {
+		if linkedPage.Domain == "" {
+			articleLinks.Outbound[i].Domain = linkDomain(linkedPage.Url)
+		}
+	}
+	for i, linkedPage := range articleLinks.Inbound {
+		articleLinks.Inbound[i].Domain = linkDomain(linkedPage.Url)
+	}
+
+	return articleLinks, nil
+}
+
+func linkedPagesFromMaps(values []any) []LinkedPage {
+
+	linkedPages := []LinkedPage{}
+	for _, value := range values {
+		linkMap, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		var linkedPage LinkedPage
+		linkedPage.Id, _ = linkMap["id"].(string)
+		linkedPage.Type, _ = linkMap["type"].(string)
+		linkedPage.Url, _ = linkMap["url"].(string)
+		linkedPage.Title, _ = linkMap["title"].(string)
+		linkedPage.Domain, _ = linkMap["domain"].(string)
+		linkedPage.AnchorText, _ = linkMap["anchor_text"].(string)
+		if count, ok := linkMap["count"].(int64); ok {
+			linkedPage.Count = int(count)
+		}
+		linkedPages = append(linkedPages, linkedPage)
+	}
+
+	return linkedPages
+}
+
+// Returns the external sources (Web_Page stubs, which are pages that haven't been ingested) linked to by
+// the most articles. Sources are grouped by domain, or by page when byPage is set:
+func GetMostCitedSources(byPage bool, limit int, ctx context.Context, driver neo4j.DriverWithContext) (sources []CitedSource, err error) {
+
+	query := `MATCH (article:Article)-[link:LINKS_TO]->(page:Web_Page)
+		WITH page.domain AS domain, count(DISTINCT article) AS articles, sum(coalesce(link.count, 1)) AS links,
+			count(DISTINCT page) AS pages
+		RETURN domain, '' AS url, articles, links, pages
+		ORDER BY articles DESC, links DESC, domain
+		LIMIT $limit`
+	if byPage {
+		query = `MATCH (article:Article)-[link:LINKS_TO]->(page:Web_Page)
+		WITH page, count(DISTINCT article) AS articles, sum(coalesce(link.count, 1)) AS links
+		RETURN page.domain AS domain, page.url AS url, articles, links, 0 AS pages
+		ORDER BY articles DESC, links DESC, url
+		LIMIT $limit`
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		query,
+		map[string]any{"limit": limit},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return sources, err
+	}
+
+	sources = []CitedSource{}
+	for _, record := range result.Records {
+		var source CitedSource
+		recordMap := record.AsMap()
+		source.Domain, _ = recordMap["domain"].(string)
+		source.Url, _ = recordMap["url"].(string)
+		source.Articles, _ = recordMap["articles"].(int64)
+		source.Links, _ = recordMap["links"].(int64)
+		source.Pages, _ = recordMap["pages"].(int64)
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
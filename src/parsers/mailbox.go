@@ -0,0 +1,343 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+// An email read from a mailbox. HtmlBody and TextBody are the first text/html and text/plain parts of the
+// message transcoded to UTF-8, Charset the charset the html (or else text) part was sent in. Links are the
+// http(s) links in the body along with their anchor text:
+type MailMessage struct {
+	MessageId string    `json:"message_id"`
+	Subject   string    `json:"subject"`
+	FromName  string    `json:"from_name"`
+	FromEmail string    `json:"from_email"`
+	Date      time.Time `json:"date"`
+	ListId    string    `json:"list_id"`
+	HtmlBody  string    `json:"html_body"`
+	TextBody  string    `json:"text_body"`
+	Charset   string    `json:"charset"`
+	Links     []RssLink `json:"links"`
+}
+
+// Headers are decoded (eg: "=?UTF-8?B?...?=") in any charset we can transcode:
+var mailWordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+var textBodyUrl = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// Reads every message in an mbox file or a Maildir directory (its new/ and cur/ sub directories). Messages
+// that can't be parsed are skipped and returned as warnings:
+func ReadMailbox(mailboxPath string) (messages []MailMessage, warnings []string, err error) {
+
+	info, err := os.Stat(mailboxPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rawMessages [][]byte
+	if info.IsDir() {
+		rawMessages, err = readMaildir(mailboxPath)
+	} else {
+		rawMessages, err = readMbox(mailboxPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, rawMessage := range rawMessages {
+		message, err := ParseMailMessage(rawMessage)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Skipped message %d of %s: %s", i+1, mailboxPath, err.Error()))
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, warnings, nil
+}
+
+// Splits an mbox file into its messages. Each message starts with a "From " line, and lines in the body
+// that started with "From " were escaped as ">From " (or ">>From " etc.) which is undone:
+func readMbox(mboxPath string) (rawMessages [][]byte, err error) {
+
+	mboxFile, err := os.Open(mboxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer mboxFile.Close()
+
+	reader := bufio.NewReader(mboxFile)
+	var current *bytes.Buffer
+	previousBlank := true
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case previousBlank && bytes.HasPrefix(line, []byte("From ")):
+				if current != nil {
+					rawMessages = append(rawMessages, current.Bytes())
+				}
+				current = &bytes.Buffer{}
+			case current == nil:
+				// Text before the first "From " line isn't part of any message
+			default:
+				if unescaped := bytes.TrimLeft(line, ">"); len(unescaped) < len(line) && bytes.HasPrefix(unescaped, []byte("From ")) {
+					line = line[1:]
+				}
+				current.Write(line)
+			}
+			previousBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	if current != nil {
+		rawMessages = append(rawMessages, current.Bytes())
+	}
+	if len(rawMessages) == 0 {
+		return nil, fmt.Errorf("%s is not an mbox file, it has no \"From \" lines", mboxPath)
+	}
+
+	return rawMessages, nil
+}
+
+// Reads the messages of a Maildir in the order they were delivered (Maildir file names start with their
+// delivery time). Messages still being delivered (tmp/) are left alone:
+func readMaildir(maildirPath string) (rawMessages [][]byte, err error) {
+
+	var messagePaths []string
+	for _, subDirectory := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(maildirPath, subDirectory))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				messagePaths = append(messagePaths, filepath.Join(maildirPath, subDirectory, entry.Name()))
+			}
+		}
+	}
+	if messagePaths == nil {
+		return nil, fmt.Errorf("%s is not a Maildir, it has no new or cur directory", maildirPath)
+	}
+	sort.Slice(messagePaths, func(i, j int) bool {
+		return filepath.Base(messagePaths[i]) < filepath.Base(messagePaths[j])
+	})
+
+	for _, messagePath := range messagePaths {
+		rawMessage, err := os.ReadFile(messagePath)
+		if err != nil {
+			return nil, err
+		}
+		rawMessages = append(rawMessages, rawMessage)
+	}
+
+	return rawMessages, nil
+}
+
+// Parses a single email. Multipart messages are walked for their first html and plain text parts,
+// attachments are ignored. Messages without a Message-ID are given one from the hash of their sender,
+// date and subject so that they are still recognised when the mailbox is read again:
+func ParseMailMessage(rawMessage []byte) (message MailMessage, err error) {
+
+	parsedMessage, err := mail.ReadMessage(bytes.NewReader(rawMessage))
+	if err != nil {
+		return message, err
+	}
+	header := parsedMessage.Header
+
+	message.Subject = decodeMailHeader(header.Get("Subject"))
+	message.ListId = decodeMailHeader(header.Get("List-Id"))
+
+	addressParser := mail.AddressParser{WordDecoder: mailWordDecoder}
+	if from, err := addressParser.Parse(header.Get("From")); err == nil {
+		message.FromName = strings.TrimSpace(from.Name)
+		message.FromEmail = strings.ToLower(strings.TrimSpace(from.Address))
+	} else {
+		message.FromName = decodeMailHeader(header.Get("From"))
+	}
+
+	if date, err := header.Date(); err == nil {
+		message.Date = date.UTC()
+	} else {
+		message.Date, _ = ParseFeedDate(header.Get("Date"))
+	}
+
+	message.MessageId = strings.Trim(strings.TrimSpace(header.Get("Message-Id")), "<>")
+	if message.MessageId == "" {
+		messageHash := sha1.Sum([]byte(message.FromEmail + "\n" + header.Get("Date") + "\n" + message.Subject))
+		message.MessageId = "sha1-" + hex.EncodeToString(messageHash[:])
+	}
+
+	err = readMailPart(&message, header.Get("Content-Type"), header.Get("Content-Transfer-Encoding"), "", parsedMessage.Body)
+	if err != nil {
+		return message, err
+	}
+	if message.HtmlBody == "" && message.TextBody == "" {
+		return message, fmt.Errorf("message %s has no text or html body", message.MessageId)
+	}
+
+	message.Links = extractMailLinks(message)
+
+	return message, nil
+}
+
+func decodeMailHeader(value string) string {
+	decoded, err := mailWordDecoder.DecodeHeader(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+// Reads a part of a message into its html or text body, walking into multipart parts:
+func readMailPart(message *MailMessage, contentType string, transferEncoding string, disposition string, body io.Reader) error {
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Messages without a (valid) Content-Type are plain text:
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(disposition)), "attachment") {
+		return nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		partReader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := partReader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// multipart.Reader decodes quoted-printable parts itself and removes their header:
+			err = readMailPart(
+				message,
+				part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"),
+				part,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if mediaType != "text/html" && mediaType != "text/plain" {
+		return nil
+	}
+	if (mediaType == "text/html" && message.HtmlBody != "") || (mediaType == "text/plain" && message.TextBody != "") {
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &mailBase64Reader{reader: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	content, charsetName, err := DecodeToUtf8(content, contentType)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == "text/html" {
+		message.HtmlBody = string(content)
+		message.Charset = charsetName
+	} else {
+		message.TextBody = string(content)
+		if message.Charset == "" {
+			message.Charset = charsetName
+		}
+	}
+
+	return nil
+}
+
+// The base64 decoder skips the line breaks of wrapped bodies but not the spaces and tabs that some mail
+// clients indent or pad them with, which are dropped here:
+type mailBase64Reader struct {
+	reader io.Reader
+}
+
+func (base64Reader *mailBase64Reader) Read(p []byte) (int, error) {
+	n, err := base64Reader.reader.Read(p)
+	kept := 0
+	for _, c := range p[:n] {
+		if c != ' ' && c != '\t' {
+			p[kept] = c
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// Extracts the http(s) links of a message along with their anchor text, once per url. Links are read from
+// the html body, or from the plain text body of messages without one:
+func extractMailLinks(message MailMessage) (links []RssLink) {
+
+	links = []RssLink{}
+	seenUrls := map[string]bool{}
+	addLink := func(href string, text string) {
+		href = strings.TrimSpace(href)
+		if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
+			return
+		}
+		if seenUrls[href] {
+			return
+		}
+		seenUrls[href] = true
+		links = append(links, RssLink{Href: href, Title: strings.Join(strings.Fields(text), " ")})
+	}
+
+	if message.HtmlBody != "" {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(message.HtmlBody))
+		if err != nil {
+			return links
+		}
+		doc.Find("a[href]").Each(func(i int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			addLink(href, link.Text())
+		})
+		return links
+	}
+
+	for _, href := range textBodyUrl.FindAllString(message.TextBody, -1) {
+		addLink(strings.TrimRight(href, ".,;:!?"), "")
+	}
+	return links
+}
@@ -0,0 +1,362 @@
+package parsers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// The name of the Manual:Source node that articles submitted by hand are connected to:
+const manualSourceName = "manual"
+
+var ErrInvalidSubmission = errors.New("invalid article submission")
+
+// An article url submitted by a user, along with their notes and any tags to file it under:
+type ManualArticleSubmission struct {
+	Url         string   `json:"url"`
+	Notes       string   `json:"notes"`
+	Tags        []string `json:"tags"`
+	SubmittedBy string   `json:"submitted_by"`
+}
+
+// The outcome of a submission. Status is created for a new article and linked when the url had already
+// been ingested, in which case the user is connected to the existing article:
+type ManualArticleSummary struct {
+	Id         string                       `json:"id"`
+	Url        string                       `json:"url"`
+	Title      string                       `json:"title"`
+	Status     ExtractionStatus             `json:"status"`
+	Message    string                       `json:"message"`
+	Error      string                       `json:"error"`
+	StorageUrl string                       `json:"storage_url"`
+	Images     []string                     `json:"images"`
+	Warnings   []string                     `json:"warnings"`
+	Authors    []RssAuthorExtractionSummary `json:"authors"`
+}
+
+// The source type of articles submitted by hand. There is nothing to fetch, articles are added to it by
+// SubmitManualArticle:
+type manualSource struct{}
+
+func (manualSource) Label() string { return "Manual" }
+
+func (manualSource) Fetch(source SourceNode, ctx context.Context) (fetch SourceFetch, err error) {
+	return fetch, nil
+}
+
+// Archives a single article found outside of any feed. The page is loaded (see HtmlContent.LoadHtmlPage),
+// its title, author and dates are read from its meta tags, OpenGraph and JSON-LD and the article is
+// created under the manual source through the same pipeline as every other source type (see
+// WriteSourceItems). The page and its images are stored when object storage is configured. The article
+// is connected to the User that submitted it, with their notes, and tagged with the submission's tags:
+func SubmitManualArticle(submission ManualArticleSubmission, ctx context.Context, driver neo4j.DriverWithContext) (summary ManualArticleSummary, err error) {
+
+	submission.Url = strings.TrimSpace(submission.Url)
+	submission.SubmittedBy = strings.TrimSpace(submission.SubmittedBy)
+	summary.Url = submission.Url
+	summary.Images = []string{}
+
+	parsedUrl, err := url.Parse(submission.Url)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return summary, manualSubmissionError(&summary, fmt.Errorf("%w: %q is not an absolute http(s) url", ErrInvalidSubmission, submission.Url), StatusErrorParse)
+	}
+	if submission.SubmittedBy == "" {
+		return summary, manualSubmissionError(&summary, fmt.Errorf("%w: submitted_by is required", ErrInvalidSubmission), StatusErrorParse)
+	}
+	tags := []string{}
+	for _, tag := range submission.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	// 1) Urls that have already been ingested from any source are linked to the user rather than duplicated:
+	linkExistingArticle := func(articleUrl string) (linked bool, err error) {
+		existingEntry, err := getArticleByUrl(articleUrl, ctx, driver)
+		if err != nil || existingEntry.Id == "" {
+			return false, err
+		}
+		summary.Id = existingEntry.Id
+		summary.Title = existingEntry.Title
+		if err = linkSubmittingUser(existingEntry.Id, submission, tags, ctx, driver); err != nil {
+			return false, err
+		}
+		summary.Status = StatusLinked
+		summary.Message = "Article already exists in the database. Connected it to the submitting user"
+		return true, nil
+	}
+	if linked, err := linkExistingArticle(submission.Url); err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorDb)
+	} else if linked {
+		return summary, nil
+	}
+
+	// 2) Loading the page and its images and reading its metadata:
+	htmlContent := HtmlContent{Url: submission.Url}
+	defer htmlContent.RemoveTempFiles()
+	if err = htmlContent.LoadHtmlPage(); err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorFetch)
+	}
+	page, err := ArticlePageFromHtmlContent(htmlContent)
+	if err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorParse)
+	}
+	summary.Title = page.Title
+
+	// The page may declare a canonical url that an article was already ingested under:
+	item := ManualSourceItem(page, tags)
+	if item.Entry.CanonicalUrl != CanonicalizeUrl(submission.Url) {
+		if linked, err := linkExistingArticle(item.Entry.CanonicalUrl); err != nil {
+			return summary, manualSubmissionError(&summary, err, StatusErrorDb)
+		} else if linked {
+			return summary, nil
+		}
+	}
+
+	// 3) Creating the article under the manual source:
+	source, err := getManualSource(ctx, driver)
+	if err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorDb)
+	}
+	entrySummaries, err := WriteSourceItems(
+		source,
+		manualSource{}.Label(),
+		"",
+		SourceFetch{Items: []SourceItem{item}, SourceProperties: map[string]any{"last_updated": time.Now().UTC()}},
+		ctx,
+		driver,
+	)
+	if err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorDb)
+	}
+	summary.Id = entrySummaries[0].Id
+	summary.Authors = entrySummaries[0].Authors
+
+	if err = linkSubmittingUser(summary.Id, submission, tags, ctx, driver); err != nil {
+		return summary, manualSubmissionError(&summary, err, StatusErrorDb)
+	}
+	if entrySummaries[0].Status != StatusCreated {
+		// The same url was submitted by someone else at the same time:
+		summary.Status = StatusLinked
+		summary.Message = "Article already exists in the database. Connected it to the submitting user"
+		return summary, nil
+	}
+
+	if err = extractNewArticleEntities(summary.Id, item.Entry, &page, ctx, driver); err != nil {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("Unable to extract the entities of the article: %s", err.Error()))
+	}
+	if err = tagNewArticleTopics(summary.Id, item.Entry, &page, ctx, driver); err != nil {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("Unable to tag the topics of the article: %s", err.Error()))
+	}
+
+	// 4) Storing the page and its images. Pages that can't be stored don't fail the submission:
+	if PageCaptures.Client != nil {
+		summary.StorageUrl, err = CaptureArticlePage(manualSourceName, summary.Id, page, ctx, driver)
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("Unable to store the page: %s", err.Error()))
+		}
+		var imageWarnings []string
+		summary.Images, imageWarnings = storeManualArticleImages(summary.Id, htmlContent, ctx, driver)
+		summary.Warnings = append(summary.Warnings, imageWarnings...)
+	}
+
+	summary.Status = StatusCreated
+	summary.Message = "Successfully inserted the Article. Check Author for futher information about Author connections."
+
+	return summary, nil
+}
+
+func manualSubmissionError(summary *ManualArticleSummary, err error, status ExtractionStatus) error {
+	summary.Error = err.Error()
+	summary.Status = status
+	summary.Message = "Unable to archive the submitted article"
+	return err
+}
+
+// Reads the page loaded by LoadHtmlPage and its metadata into an ArticlePage:
+func ArticlePageFromHtmlContent(htmlContent HtmlContent) (page ArticlePage, err error) {
+
+	page.Url = htmlContent.Url
+	page.StatusCode = htmlContent.StatusCode
+	page.Charset = htmlContent.Charset
+	page.Body, err = os.ReadFile(htmlContent.HtmlPage)
+	if err != nil {
+		return page, err
+	}
+
+	err = readArticlePageMetadata(&page)
+	return page, err
+}
+
+// Normalises a submitted page into the item of the manual source. Pages without a title are named by
+// their url and the tags of the submission become the article's categories:
+func ManualSourceItem(page ArticlePage, tags []string) (item SourceItem) {
+
+	item.Entry = RssEntry{
+		Url:          page.Url,
+		CanonicalUrl: CanonicalizeUrl(page.Url),
+		Charset:      page.Charset,
+		Categories:   append([]string{}, tags...),
+		Media:        []RssMedia{},
+	}
+	MergeEntryMetadata(&item.Entry, page.Metadata)
+	if item.Entry.Title == "" {
+		item.Entry.Title = page.Url
+	}
+
+	for _, authorName := range page.Metadata.Authors {
+		item.Authors = append(item.Authors, RssAuthor{Name: authorName})
+	}
+	item.Page = &page
+
+	return item
+}
+
+// Looks up an article of any source type by its url (see existingArticlesQuery):
+func getArticleByUrl(articleUrl string, ctx context.Context, driver neo4j.DriverWithContext) (rssEntry RssEntry, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		existingArticlesQuery,
+		map[string]any{"items": []map[string]any{existingRssArticleParams(0, "", articleUrl)}, "source_id": ""},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return rssEntry, err
+	}
+	if len(result.Records) == 0 {
+		return rssEntry, nil
+	}
+
+	articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "article")
+	if err != nil {
+		return rssEntry, err
+	}
+
+	return RssEntryFromNode(articleNode), nil
+}
+
+func getManualSource(ctx context.Context, driver neo4j.DriverWithContext) (source SourceNode, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MERGE (source:Manual:Source {name: $name})
+		ON CREATE SET
+			source.description = "Articles submitted by hand",
+			source.created = datetime({timezone: 'UTC'})
+		RETURN source`,
+		map[string]any{"name": manualSourceName},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return source, err
+	}
+
+	node, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "source")
+	if err != nil {
+		return source, err
+	}
+
+	return SourceNodeFromNode(node), nil
+}
+
+// Connects the article to the User that submitted it. Every submission is kept as its own SUBMITTED
+// relationship with the notes given at the time:
+func linkSubmittingUser(articleId string, submission ManualArticleSubmission, tags []string, ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $article_id
+		MERGE (user:User {name: $submitted_by})
+		CREATE (user)-[:SUBMITTED {date_submitted: $date_submitted, notes: $notes, url: $url}]->(article)
+		FOREACH (tag_name IN $tags |
+			MERGE (tag:Tag {name: tag_name})
+			MERGE (article)-[:TAGGED]->(tag)
+		)
+		RETURN elementId(user) AS id`,
+		map[string]any{
+			"article_id":     articleId,
+			"submitted_by":   submission.SubmittedBy,
+			"date_submitted": time.Now().UTC(),
+			"notes":          submission.Notes,
+			"url":            submission.Url,
+			"tags":           tags,
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err
+	}
+	if len(result.Records) == 0 {
+		return fmt.Errorf("unable to find article %s to connect to %s", articleId, submission.SubmittedBy)
+	}
+
+	return nil
+}
+
+// Uploads the images loaded with a submitted page into the page capture bucket and records them as Media
+// of the article. Images are kept under images/manual/<hash of the page url>/:
+func storeManualArticleImages(articleId string, htmlContent HtmlContent, ctx context.Context, driver neo4j.DriverWithContext) (storageUrls []string, warnings []string) {
+
+	storageUrls = []string{}
+	urlHash := sha1.Sum([]byte(htmlContent.Url))
+
+	var mediaRows []map[string]any
+	for i, imagePath := range htmlContent.Images {
+
+		imageFile, err := os.Open(imagePath)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Unable to store image %s: %s", htmlContent.ImageUrls[i], err.Error()))
+			continue
+		}
+		// Temp image files are prefixed with a random number by os.CreateTemp:
+		imageName := filepath.Base(imagePath)
+		if _, name, found := strings.Cut(imageName, "-"); found {
+			imageName = name
+		}
+		bucketFilePath := path.Join("images", manualSourceName, hex.EncodeToString(urlHash[:]), fmt.Sprintf("%d-%s", i, imageName))
+		storageUrl, err := UploadImageFileToStatic(ctx, PageCaptures.Client, PageCaptures.Bucket, bucketFilePath, imageFile)
+		imageFile.Close()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Unable to store image %s: %s", htmlContent.ImageUrls[i], err.Error()))
+			continue
+		}
+
+		storageUrls = append(storageUrls, storageUrl)
+		mediaRows = append(mediaRows, map[string]any{"url": htmlContent.ImageUrls[i], "storage_url": storageUrl})
+	}
+	if len(mediaRows) == 0 {
+		return storageUrls, warnings
+	}
+
+	_, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $article_id
+		UNWIND $images AS image
+		MERGE (media:Media {url: image.url})
+		ON CREATE SET media.medium = "image", media.source = "img"
+		SET media.static_file_url = image.storage_url, media.in_static_file_storage = 1
+		MERGE (article)-[:HAS_MEDIA]->(media)`,
+		map[string]any{"article_id": articleId, "images": mediaRows},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("Unable to record the stored images: %s", err.Error()))
+	}
+
+	return storageUrls, warnings
+}
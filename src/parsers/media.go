@@ -0,0 +1,343 @@
+package parsers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Settings for downloading the audio and video enclosures of feeds (podcasts and video briefings) into
+// object storage. Downloads are off unless Enabled is set and a minio Client is provided. Media larger
+// than MaxBytes is skipped. Interrupted downloads are kept in TempDir and resumed with a range request the
+// next time the feed is ingested:
+type MediaDownloadConfig struct {
+	Enabled         bool
+	Client          *minio.Client
+	Bucket          string
+	MaxBytes        int64
+	RequestTimeout  time.Duration
+	MaxPerIngestion int
+	TempDir         string
+}
+
+// The media download settings used by IngestAllRssItems. Set in main from the command line flags:
+var MediaDownloads = MediaDownloadConfig{
+	Bucket:          "media",
+	MaxBytes:        500 * 1024 * 1024,
+	RequestTimeout:  30 * time.Minute,
+	MaxPerIngestion: 10,
+}
+
+// The states a Media node's download can be in, stored as its download_status:
+const (
+	MediaDownloadStored   = "stored"
+	MediaDownloadPartial  = "partial"
+	MediaDownloadTooLarge = "too_large"
+)
+
+// Finds the audio and video media of a source's articles that haven't been stored yet, including the ones
+// that were partly downloaded before:
+const pendingRssMediaQuery = `
+MATCH (source:Source)-[:CONTAINS_ARTICLE]->(:Article)-[:HAS_MEDIA]->(media:Media)
+WHERE elementId(source) = $source_id
+	AND coalesce(media.in_static_file_storage, 0) = 0
+	AND coalesce(media.download_status, '') <> $too_large
+	AND (media.type STARTS WITH 'audio/' OR media.type STARTS WITH 'video/' OR media.medium IN ['audio', 'video'])
+RETURN DISTINCT media
+LIMIT $limit
+`
+
+// Downloads the pending audio and video media of a feed's articles (see pendingRssMediaQuery) into the
+// media bucket and records the outcome on each Media node. A failed download doesn't fail the ingestion,
+// it is reported in the returned summaries and retried on the next ingestion:
+func DownloadRssFeedMedia(rssFeed RssFeed, ctx context.Context, driver neo4j.DriverWithContext) (summaries []RssMediaDownloadSummary, err error) {
+
+	summaries = []RssMediaDownloadSummary{}
+	if !MediaDownloads.Enabled || MediaDownloads.Client == nil {
+		return summaries, nil
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		pendingRssMediaQuery,
+		map[string]any{"source_id": rssFeed.Id, "too_large": MediaDownloadTooLarge, "limit": MediaDownloads.MaxPerIngestion},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return summaries, err
+	}
+
+	tempDir, err := mediaTempDir()
+	if err != nil {
+		return summaries, err
+	}
+
+	fetcherConfig := DefaultFetcher.Config
+	fetcherConfig.MaxBodyBytes = MediaDownloads.MaxBytes
+	fetcherConfig.RequestTimeout = MediaDownloads.RequestTimeout
+	fetcher := NewFetcher(fetcherConfig)
+
+	for _, record := range result.Records {
+		mediaNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "media")
+		if err != nil {
+			return summaries, err
+		}
+		summary := downloadRssMedia(rssFeed, RssMediaFromNode(mediaNode), fetcher, tempDir, ctx, driver)
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+func downloadRssMedia(rssFeed RssFeed, rssMedia RssMedia, fetcher *Fetcher, tempDir string, ctx context.Context, driver neo4j.DriverWithContext) (summary RssMediaDownloadSummary) {
+
+	summary.Id = rssMedia.Id
+	summary.Url = rssMedia.Url
+
+	if MediaDownloads.MaxBytes > 0 && rssMedia.Length > MediaDownloads.MaxBytes {
+		summary.Status = StatusSkippedTooLarge
+		summary.Message = fmt.Sprintf("The feed lists the media as %d bytes, larger than the %d byte limit", rssMedia.Length, MediaDownloads.MaxBytes)
+		summary.Error = recordMediaDownload(rssMedia.Id, MediaDownloadTooLarge, 0, "", "", ctx, driver)
+		return summary
+	}
+
+	urlHash := sha1.Sum([]byte(rssMedia.Url))
+	mediaName := hex.EncodeToString(urlHash[:])
+	partPath := filepath.Join(tempDir, mediaName+".part")
+
+	size, err := DownloadMediaFile(ctx, fetcher, rssMedia.Url, partPath, MediaDownloads.MaxBytes)
+	summary.Bytes = size
+	if errors.Is(err, ErrBodyTooLarge) {
+		summary.Status = StatusSkippedTooLarge
+		summary.Message = fmt.Sprintf("The media is larger than the %d byte limit", MediaDownloads.MaxBytes)
+		summary.Error = recordMediaDownload(rssMedia.Id, MediaDownloadTooLarge, 0, "", err.Error(), ctx, driver)
+		return summary
+	}
+	if err != nil {
+		summary.Status = StatusErrorFetch
+		summary.Error = err.Error()
+		summary.Message = fmt.Sprintf("Downloaded %d bytes of the media before the download failed. It will be resumed on the next ingestion", size)
+		recordMediaDownload(rssMedia.Id, MediaDownloadPartial, size, "", err.Error(), ctx, driver)
+		return summary
+	}
+
+	mediaFile, err := os.Open(partPath)
+	if err != nil {
+		summary.Status = StatusErrorStorage
+		summary.Error = err.Error()
+		summary.Message = "Unable to open the downloaded media"
+		return summary
+	}
+	defer mediaFile.Close()
+
+	contentType := rssMedia.Type
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	bucketFilePath := path.Join("media", storagePathComponent(rssFeed.Title), mediaName+mediaExtension(rssMedia.Url))
+	_, err = UploadFileToStatic(ctx, MediaDownloads.Client, MediaDownloads.Bucket, bucketFilePath, mediaFile, contentType)
+	if err != nil {
+		summary.Status = StatusErrorStorage
+		summary.Error = err.Error()
+		summary.Message = "Downloaded the media but was unable to upload it to object storage. The download is kept for the next ingestion"
+		recordMediaDownload(rssMedia.Id, MediaDownloadPartial, size, "", err.Error(), ctx, driver)
+		return summary
+	}
+	mediaFile.Close()
+	os.Remove(partPath)
+
+	summary.StorageUrl = bucketFilePath
+	summary.Status = StatusCreated
+	summary.Message = fmt.Sprintf("Stored %d bytes of media in the %s bucket", size, MediaDownloads.Bucket)
+	summary.Error = recordMediaDownload(rssMedia.Id, MediaDownloadStored, size, bucketFilePath, "", ctx, driver)
+	if summary.Error != "" {
+		summary.Status = StatusErrorDb
+	}
+
+	return summary
+}
+
+// Downloads a media file into partPath, resuming from the end of the file if an earlier download was
+// interrupted. Servers that ignore the range request send the whole file, which replaces the partial one.
+// Downloads larger than maxBytes fail with ErrBodyTooLarge and are removed, other failures keep what was
+// downloaded so far. Returns the size of the file in partPath:
+func DownloadMediaFile(ctx context.Context, fetcher *Fetcher, mediaUrl string, partPath string, maxBytes int64) (size int64, err error) {
+
+	partFile, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer partFile.Close()
+
+	offset, err := partFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaUrl, nil)
+	if err != nil {
+		return offset, err
+	}
+	// Byte ranges are only meaningful for the file as it is stored so it is requested without compression:
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			partFile.Close()
+			os.Remove(partPath)
+		}
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The earlier download already has the whole file:
+		return offset, nil
+	case resp.StatusCode == http.StatusPartialContent:
+		if contentRangeStart(resp.Header.Get("Content-Range")) != offset {
+			return offset, fmt.Errorf("request for %s returned an unexpected range: %s", mediaUrl, resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode == http.StatusOK:
+		if err = partFile.Truncate(0); err != nil {
+			return 0, err
+		}
+		if _, err = partFile.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		offset = 0
+	default:
+		return offset, fmt.Errorf("request for %s returned status code: %d", mediaUrl, resp.StatusCode)
+	}
+
+	if maxBytes > 0 && resp.ContentLength > 0 && offset+resp.ContentLength > maxBytes {
+		partFile.Close()
+		os.Remove(partPath)
+		return 0, fmt.Errorf("%w: %s is %d bytes", ErrBodyTooLarge, mediaUrl, offset+resp.ContentLength)
+	}
+
+	body := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes-offset+1)
+	}
+	written, err := io.Copy(partFile, body)
+	size = offset + written
+	if errors.Is(err, ErrBodyTooLarge) || (maxBytes > 0 && size > maxBytes) {
+		partFile.Close()
+		os.Remove(partPath)
+		return 0, fmt.Errorf("%w: %s is larger than %d bytes", ErrBodyTooLarge, mediaUrl, maxBytes)
+	}
+
+	return size, err
+}
+
+// Returns the first byte of a "bytes start-end/size" Content-Range header, or -1 if it can't be read:
+func contentRangeStart(contentRange string) int64 {
+	contentRange = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(contentRange), "bytes"))
+	start, _, found := strings.Cut(contentRange, "-")
+	if !found {
+		return -1
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+// Records the state of a media download on its Media node. Returns the error message if it couldn't be
+// recorded:
+func recordMediaDownload(id string, status string, size int64, storageUrl string, downloadError string, ctx context.Context, driver neo4j.DriverWithContext) string {
+
+	inStorage := 0
+	if status == MediaDownloadStored {
+		inStorage = 1
+	}
+
+	_, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (media:Media) WHERE elementId(media) = $id
+		SET
+			media.download_status = $status,
+			media.downloaded_bytes = $size,
+			media.download_error = $error,
+			media.in_static_file_storage = $in_storage,
+			media.static_file_url = $storage_url,
+			media.last_download_attempt = datetime({timezone: 'UTC'})`,
+		map[string]any{
+			"id":          id,
+			"status":      status,
+			"size":        size,
+			"error":       downloadError,
+			"in_storage":  inStorage,
+			"storage_url": storageUrl,
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func mediaTempDir() (string, error) {
+
+	tempDir := MediaDownloads.TempDir
+	if tempDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		tempDir = filepath.Join(filepath.Dir(wd), "temp", "media")
+	}
+
+	return tempDir, os.MkdirAll(tempDir, os.ModePerm)
+}
+
+// The file extension of a media url, eg: ".mp3":
+func mediaExtension(mediaUrl string) string {
+	parsedUrl, err := url.Parse(mediaUrl)
+	if err != nil {
+		return ""
+	}
+	extension := strings.ToLower(path.Ext(parsedUrl.Path))
+	if len(extension) > 10 {
+		return ""
+	}
+	return extension
+}
+
+// Makes a source title safe to use as part of an object path:
+func storagePathComponent(name string) string {
+	component := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+	if component == "" {
+		return "unknown_source"
+	}
+	return component
+}
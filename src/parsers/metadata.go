@@ -0,0 +1,496 @@
+package parsers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Where a metadata value was read from (see PageMetadata.Sources):
+const (
+	MetadataFromRss       = "rss"
+	MetadataFromOpenGraph = "opengraph"
+	MetadataFromTwitter   = "twitter"
+	MetadataFromJsonLd    = "json-ld"
+	MetadataFromMeta      = "meta"
+	MetadataFromHtml      = "html"
+)
+
+// The metadata an article page declares about itself in its <meta> tags, OpenGraph and Twitter card tags
+// and schema.org JSON-LD. Sources maps each field that has a value (by its json name) to where the value
+// was read from. Byline is the authors as the page writes them and Authors the individual names:
+type PageMetadata struct {
+	CanonicalUrl  string            `json:"canonical_url"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+	Byline        string            `json:"byline"`
+	Authors       []string          `json:"authors"`
+	DatePublished time.Time         `json:"date_published"`
+	DateModified  time.Time         `json:"date_modified"`
+	Section       string            `json:"section"`
+	Keywords      []string          `json:"keywords"`
+	LeadImage     string            `json:"lead_image"`
+	Publisher     string            `json:"publisher"`
+	Sources       map[string]string `json:"sources"`
+}
+
+// The schema.org types that describe an article page:
+var jsonLdArticleTypes = map[string]bool{
+	"Article":              true,
+	"NewsArticle":          true,
+	"AnalysisNewsArticle":  true,
+	"OpinionNewsArticle":   true,
+	"ReportageNewsArticle": true,
+	"BlogPosting":          true,
+	"Report":               true,
+	"ScholarlyArticle":     true,
+	"TechArticle":          true,
+}
+
+// Reads the metadata of an article page. Each field is taken from the first of its sources that has a
+// value, in the order:
+//
+//	canonical url:  <link rel="canonical">, og:url, JSON-LD url
+//	title:          og:title, twitter:title, <title>, JSON-LD headline
+//	description:    og:description, twitter:description, meta description, JSON-LD description
+//	authors:        meta author, article:author (unless it is a profile url), JSON-LD author
+//	published:      article:published_time, meta datePublished or date, JSON-LD datePublished
+//	modified:       article:modified_time, og:updated_time, meta dateModified, JSON-LD dateModified
+//	section:        article:section, JSON-LD articleSection
+//	keywords:       article:tag, JSON-LD keywords, meta keywords
+//	lead image:     og:image, twitter:image, JSON-LD image
+//	publisher:      JSON-LD publisher, og:site_name
+//
+// Relative canonical and image urls are resolved against the page url:
+func ExtractPageMetadata(pageUrl string, body []byte) (metadata PageMetadata, err error) {
+
+	metadata.Sources = map[string]string{}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return metadata, err
+	}
+
+	meta := func(selector string) string {
+		content, _ := doc.Find(selector).First().Attr("content")
+		return strings.TrimSpace(content)
+	}
+	setString := func(field *string, name string, source string, value string) {
+		if *field == "" && value != "" {
+			*field = value
+			metadata.Sources[name] = source
+		}
+	}
+	setDate := func(field *time.Time, name string, source string, value string) {
+		if !field.IsZero() || value == "" {
+			return
+		}
+		if date, err := ParseFeedDate(value); err == nil && !date.IsZero() {
+			*field = date
+			metadata.Sources[name] = source
+		}
+	}
+	setList := func(field *[]string, name string, source string, values []string) {
+		if len(*field) == 0 && len(values) > 0 {
+			*field = values
+			metadata.Sources[name] = source
+		}
+	}
+
+	jsonLd := readJsonLdDocuments(doc)
+	article := jsonLd.article()
+
+	canonicalHref, _ := doc.Find(`link[rel="canonical"][href]`).First().Attr("href")
+	setString(&metadata.CanonicalUrl, "canonical_url", MetadataFromHtml, resolvePageUrl(pageUrl, canonicalHref))
+	setString(&metadata.CanonicalUrl, "canonical_url", MetadataFromOpenGraph, resolvePageUrl(pageUrl, meta(`meta[property="og:url"]`)))
+	setString(&metadata.CanonicalUrl, "canonical_url", MetadataFromJsonLd, resolvePageUrl(pageUrl, jsonLdString(article["url"])))
+
+	setString(&metadata.Title, "title", MetadataFromOpenGraph, meta(`meta[property="og:title"]`))
+	setString(&metadata.Title, "title", MetadataFromTwitter, meta(`meta[name="twitter:title"], meta[property="twitter:title"]`))
+	setString(&metadata.Title, "title", MetadataFromHtml, strings.TrimSpace(doc.Find("title").First().Text()))
+	setString(&metadata.Title, "title", MetadataFromJsonLd, jsonLdString(article["headline"]))
+	setString(&metadata.Title, "title", MetadataFromJsonLd, jsonLdString(article["name"]))
+
+	setString(&metadata.Description, "description", MetadataFromOpenGraph, meta(`meta[property="og:description"]`))
+	setString(&metadata.Description, "description", MetadataFromTwitter, meta(`meta[name="twitter:description"], meta[property="twitter:description"]`))
+	setString(&metadata.Description, "description", MetadataFromMeta, meta(`meta[name="description"]`))
+	setString(&metadata.Description, "description", MetadataFromJsonLd, jsonLdString(article["description"]))
+
+	// article:author is often a link to the author's profile rather than their name:
+	setString(&metadata.Byline, "authors", MetadataFromMeta, meta(`meta[name="author"]`))
+	if author := meta(`meta[property="article:author"]`); !strings.Contains(author, "://") {
+		setString(&metadata.Byline, "authors", MetadataFromOpenGraph, author)
+	}
+	if metadata.Byline != "" {
+		metadata.Authors = SplitAuthorNames(metadata.Byline)
+	} else if authorNames := jsonLd.names(article["author"]); len(authorNames) > 0 {
+		metadata.Byline = strings.Join(authorNames, " and ")
+		metadata.Authors = authorNames
+		metadata.Sources["authors"] = MetadataFromJsonLd
+	}
+
+	setDate(&metadata.DatePublished, "date_published", MetadataFromOpenGraph, meta(`meta[property="article:published_time"]`))
+	setDate(&metadata.DatePublished, "date_published", MetadataFromMeta, meta(`meta[itemprop="datePublished"]`))
+	setDate(&metadata.DatePublished, "date_published", MetadataFromMeta, meta(`meta[name="date"]`))
+	setDate(&metadata.DatePublished, "date_published", MetadataFromJsonLd, jsonLdString(article["datePublished"]))
+
+	setDate(&metadata.DateModified, "date_modified", MetadataFromOpenGraph, meta(`meta[property="article:modified_time"]`))
+	setDate(&metadata.DateModified, "date_modified", MetadataFromOpenGraph, meta(`meta[property="og:updated_time"]`))
+	setDate(&metadata.DateModified, "date_modified", MetadataFromMeta, meta(`meta[itemprop="dateModified"]`))
+	setDate(&metadata.DateModified, "date_modified", MetadataFromJsonLd, jsonLdString(article["dateModified"]))
+
+	setString(&metadata.Section, "section", MetadataFromOpenGraph, meta(`meta[property="article:section"]`))
+	if sections := jsonLdStrings(article["articleSection"]); len(sections) > 0 {
+		setString(&metadata.Section, "section", MetadataFromJsonLd, sections[0])
+	}
+
+	var articleTags []string
+	doc.Find(`meta[property="article:tag"]`).Each(func(i int, tag *goquery.Selection) {
+		content, _ := tag.Attr("content")
+		articleTags = append(articleTags, content)
+	})
+	setList(&metadata.Keywords, "keywords", MetadataFromOpenGraph, uniqueKeywords(articleTags))
+	setList(&metadata.Keywords, "keywords", MetadataFromJsonLd, uniqueKeywords(jsonLdStrings(article["keywords"])))
+	setList(&metadata.Keywords, "keywords", MetadataFromMeta, uniqueKeywords([]string{meta(`meta[name="keywords"]`)}))
+
+	setString(&metadata.LeadImage, "lead_image", MetadataFromOpenGraph, resolvePageUrl(pageUrl, meta(`meta[property="og:image"]`)))
+	setString(&metadata.LeadImage, "lead_image", MetadataFromTwitter, resolvePageUrl(pageUrl, meta(`meta[name="twitter:image"], meta[property="twitter:image"], meta[name="twitter:image:src"]`)))
+	if imageUrls := jsonLd.urls(article["image"]); len(imageUrls) > 0 {
+		setString(&metadata.LeadImage, "lead_image", MetadataFromJsonLd, resolvePageUrl(pageUrl, imageUrls[0]))
+	}
+
+	if publisherNames := jsonLd.names(article["publisher"]); len(publisherNames) > 0 {
+		setString(&metadata.Publisher, "publisher", MetadataFromJsonLd, publisherNames[0])
+	}
+	setString(&metadata.Publisher, "publisher", MetadataFromOpenGraph, meta(`meta[property="og:site_name"]`))
+
+	return metadata, nil
+}
+
+// Fills in the fields of an article from the metadata of its page. Values the article already has (from its
+// feed, sitemap or email) are kept and recorded as coming from MetadataFromRss. The page's canonical url
+// replaces the article's, which is only ever derived from the url it was found at, so that articles
+// syndicated or linked under several urls are matched to each other:
+func MergeEntryMetadata(rssEntry *RssEntry, metadata PageMetadata) {
+
+	if rssEntry.MetadataSources == nil {
+		rssEntry.MetadataSources = map[string]string{}
+	}
+
+	mergeString := func(field *string, name string, value string) {
+		if *field != "" {
+			if _, recorded := rssEntry.MetadataSources[name]; !recorded {
+				rssEntry.MetadataSources[name] = MetadataFromRss
+			}
+			return
+		}
+		if value != "" {
+			*field = value
+			rssEntry.MetadataSources[name] = metadata.Sources[name]
+		}
+	}
+	mergeDate := func(field *time.Time, name string, value time.Time) {
+		if !field.IsZero() {
+			if _, recorded := rssEntry.MetadataSources[name]; !recorded {
+				rssEntry.MetadataSources[name] = MetadataFromRss
+			}
+			return
+		}
+		if !value.IsZero() {
+			*field = value
+			rssEntry.MetadataSources[name] = metadata.Sources[name]
+		}
+	}
+
+	if metadata.CanonicalUrl != "" {
+		rssEntry.CanonicalUrl = CanonicalizeUrl(metadata.CanonicalUrl)
+		rssEntry.MetadataSources["canonical_url"] = metadata.Sources["canonical_url"]
+	}
+	mergeString(&rssEntry.Title, "title", metadata.Title)
+	mergeString(&rssEntry.Description, "description", metadata.Description)
+	mergeString(&rssEntry.Creator, "authors", metadata.Byline)
+	mergeDate(&rssEntry.DatePosted, "date_published", metadata.DatePublished)
+	mergeDate(&rssEntry.DateUpdated, "date_modified", metadata.DateModified)
+	mergeString(&rssEntry.Section, "section", metadata.Section)
+	mergeString(&rssEntry.ImageUrl, "lead_image", metadata.LeadImage)
+	mergeString(&rssEntry.Publisher, "publisher", metadata.Publisher)
+
+	if len(rssEntry.Keywords) > 0 {
+		if _, recorded := rssEntry.MetadataSources["keywords"]; !recorded {
+			rssEntry.MetadataSources["keywords"] = MetadataFromRss
+		}
+	} else if len(metadata.Keywords) > 0 {
+		rssEntry.Keywords = metadata.Keywords
+		rssEntry.MetadataSources["keywords"] = metadata.Sources["keywords"]
+	}
+}
+
+// Returned when the page of an article without a web page (eg: a newsletter email) is requested:
+var ErrNoArticlePage = errors.New("the article has no web page")
+
+// Fetches the page of an existing article and fills in the metadata the article is missing (see
+// MergeEntryMetadata). The article's canonical url is only replaced by the page's if it doesn't have one,
+// as the articles it is already matched to were found by it. The authors read from the page are added to
+// articles that don't have any. Pages that can't be fetched are returned as a SourceError with
+// StatusErrorFetch:
+func EnrichArticleMetadata(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (rssEntry RssEntry, err error) {
+
+	rssEntry, err = GetRssArticleById(articleId, ctx, driver)
+	if err != nil {
+		return rssEntry, err
+	}
+	if !strings.HasPrefix(rssEntry.Url, "http://") && !strings.HasPrefix(rssEntry.Url, "https://") {
+		return rssEntry, ErrNoArticlePage
+	}
+
+	requestCtx, err := articlePageContext(rssEntry.Id, ctx, driver)
+	if err != nil {
+		return rssEntry, err
+	}
+	page, err := FetchArticlePage(requestCtx, rssEntry.Url)
+	if err != nil {
+		return rssEntry, newSourceError(StatusErrorFetch, "Unable to fetch the page of the article", err)
+	}
+	canonicalUrl, canonicalSource := rssEntry.CanonicalUrl, rssEntry.MetadataSources["canonical_url"]
+	MergeEntryMetadata(&rssEntry, page.Metadata)
+	if canonicalUrl != "" {
+		rssEntry.CanonicalUrl = canonicalUrl
+		if canonicalSource == "" {
+			delete(rssEntry.MetadataSources, "canonical_url")
+		} else {
+			rssEntry.MetadataSources["canonical_url"] = canonicalSource
+		}
+	}
+
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(
+			ctx,
+			`MATCH (article:Article) WHERE elementId(article) = $id
+			SET
+				article.canonical_url = CASE WHEN coalesce(article.canonical_url, "") = "" THEN $canonical_url ELSE article.canonical_url END,
+				article.name = $name,
+				article.description = $description,
+				article.creator = $creator,
+				article.image_url = $image_url,
+				article.date_posted = $date_posted,
+				article.date_updated = $date_updated,
+				article.section = $section,
+				article.keywords = $keywords,
+				article.publisher = $publisher,
+				article.metadata_sources = $metadata_sources
+			WITH article
+			OPTIONAL MATCH (author:Person)-[:WROTE]->(article)
+			RETURN count(author) AS authors`,
+			map[string]any{
+				"id":               rssEntry.Id,
+				"canonical_url":    rssEntry.CanonicalUrl,
+				"name":             rssEntry.Title,
+				"description":      rssEntry.Description,
+				"creator":          rssEntry.Creator,
+				"image_url":        rssEntry.ImageUrl,
+				"date_posted":      neo4jDateTime(rssEntry.DatePosted),
+				"date_updated":     neo4jDateTime(rssEntry.DateUpdated),
+				"section":          rssEntry.Section,
+				"keywords":         nonNilStrings(rssEntry.Keywords),
+				"publisher":        rssEntry.Publisher,
+				"metadata_sources": metadataSourcesJson(rssEntry.MetadataSources),
+			})
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return nil, err
+		}
+		authorCount, _, err := neo4j.GetRecordValue[int64](record, "authors")
+		if err != nil || authorCount > 0 {
+			return nil, err
+		}
+
+		var articleAuthors []articleAuthor
+		for _, authorName := range page.Metadata.Authors {
+			articleAuthors = append(articleAuthors, articleAuthor{ArticleId: rssEntry.Id, Name: authorName})
+		}
+		_, err = writeArticleAuthors(articleAuthors, ctx, tx)
+		return nil, err
+	})
+
+	return rssEntry, err
+}
+
+// Resolves a url found on a page against the page's url. Only http(s) urls are returned:
+func resolvePageUrl(pageUrl string, href string) string {
+
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if base, err := url.Parse(pageUrl); err == nil && !ref.IsAbs() {
+		ref = base.ResolveReference(ref)
+	}
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+
+	return ref.String()
+}
+
+// Splits comma separated keywords and drops empty and repeated ones (ignoring case):
+func uniqueKeywords(values []string) (keywords []string) {
+
+	seenKeywords := map[string]bool{}
+	for _, value := range values {
+		for _, keyword := range strings.Split(value, ",") {
+			keyword = strings.TrimSpace(keyword)
+			if keyword == "" || seenKeywords[strings.ToLower(keyword)] {
+				continue
+			}
+			seenKeywords[strings.ToLower(keyword)] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	return keywords
+}
+
+// The schema.org objects in the JSON-LD scripts of a page, in the order they appear. Scripts may hold a
+// single object, a list of objects or an @graph of objects. Objects with an @id are indexed so that
+// references to them (eg: an author given as {"@id": "#jenny-town"}) can be followed:
+type jsonLdDocuments struct {
+	objects []map[string]any
+	ids     map[string]map[string]any
+}
+
+func readJsonLdDocuments(doc *goquery.Document) (documents jsonLdDocuments) {
+
+	documents.ids = map[string]map[string]any{}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, script *goquery.Selection) {
+		var document any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(script.Text())), &document); err != nil {
+			return
+		}
+		documents.add(document)
+	})
+
+	return documents
+}
+
+func (documents *jsonLdDocuments) add(document any) {
+
+	switch value := document.(type) {
+	case []any:
+		for _, item := range value {
+			documents.add(item)
+		}
+	case map[string]any:
+		if graph, ok := value["@graph"]; ok {
+			documents.add(graph)
+			return
+		}
+		documents.objects = append(documents.objects, value)
+		if id := jsonLdString(value["@id"]); id != "" {
+			documents.ids[id] = value
+		}
+	}
+}
+
+// Returns the first schema.org Article (or subtype), or nil if the page doesn't have one. Reading a
+// field of a nil map returns nil so callers don't need to check:
+func (documents jsonLdDocuments) article() map[string]any {
+
+	for _, object := range documents.objects {
+		for _, typeName := range jsonLdStrings(object["@type"]) {
+			if jsonLdArticleTypes[typeName] {
+				return object
+			}
+		}
+	}
+
+	return nil
+}
+
+// Follows a reference to another object in the page's JSON-LD:
+func (documents jsonLdDocuments) resolve(object map[string]any) map[string]any {
+
+	if id := jsonLdString(object["@id"]); id != "" && len(object) == 1 {
+		if referenced, ok := documents.ids[id]; ok {
+			return referenced
+		}
+	}
+
+	return object
+}
+
+// Reads the names of a Person, an Organization, a plain string or a list of them:
+func (documents jsonLdDocuments) names(value any) (names []string) {
+	switch value := value.(type) {
+	case string:
+		if name := strings.TrimSpace(value); name != "" {
+			names = append(names, name)
+		}
+	case map[string]any:
+		if name := jsonLdString(documents.resolve(value)["name"]); name != "" {
+			names = append(names, name)
+		}
+	case []any:
+		for _, item := range value {
+			names = append(names, documents.names(item)...)
+		}
+	}
+	return names
+}
+
+// Reads the urls of an ImageObject, a plain url or a list of them:
+func (documents jsonLdDocuments) urls(value any) (urls []string) {
+	switch value := value.(type) {
+	case string:
+		if url := strings.TrimSpace(value); url != "" {
+			urls = append(urls, url)
+		}
+	case map[string]any:
+		if url := jsonLdString(documents.resolve(value)["url"]); url != "" {
+			urls = append(urls, url)
+		}
+	case []any:
+		for _, item := range value {
+			urls = append(urls, documents.urls(item)...)
+		}
+	}
+	return urls
+}
+
+func jsonLdString(value any) string {
+	if text, ok := value.(string); ok {
+		return strings.TrimSpace(text)
+	}
+	return ""
+}
+
+// Reads a string or a list of strings:
+func jsonLdStrings(value any) (values []string) {
+	switch value := value.(type) {
+	case string:
+		if text := strings.TrimSpace(value); text != "" {
+			values = append(values, text)
+		}
+	case []any:
+		for _, item := range value {
+			values = append(values, jsonLdStrings(item)...)
+		}
+	}
+	return values
+}
@@ -0,0 +1,140 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type DateMigrationSummary struct {
+	NodesUpdated         int      `json:"nodes_updated"`
+	RelationshipsUpdated int      `json:"relationships_updated"`
+	Unparseable          []string `json:"unparseable"`
+}
+
+// The temporal properties of each node label and relationship type that were written as strings or
+// millisecond timestamps before dates were stored as datetimes:
+var nodeDateProperties = map[string][]string{
+	"Rss_Feed:Article": {"date_posted", "date_updated", "created", "updated"},
+	"Rss_Feed:Source":  {"last_updated", "created"},
+	"Revision":         {"date_posted", "valid_from", "valid_to"},
+}
+var relationshipDateProperties = map[string][]string{
+	"CONTAINS_ARTICLE": {"date_downloaded"},
+}
+
+// One-off migration that converts every date property written as a string or a millisecond timestamp
+// into a UTC datetime. Empty strings are removed. Values that can't be parsed are left as they are and
+// listed in the summary so they can be fixed by hand. Properties that are already datetimes are not
+// touched so the migration can safely be run more than once:
+func MigrateDatesToDatetime(ctx context.Context, driver neo4j.DriverWithContext) (summary DateMigrationSummary, err error) {
+
+	summary.Unparseable = []string{}
+
+	for label, properties := range nodeDateProperties {
+		updated, err := migrateDateProperties(
+			fmt.Sprintf("MATCH (n:%s) RETURN elementId(n) AS id, properties(n) AS props", label),
+			`UNWIND $updates AS update
+			MATCH (n) WHERE elementId(n) = update.id
+			SET n += update.props`,
+			label,
+			properties,
+			&summary,
+			ctx,
+			driver,
+		)
+		if err != nil {
+			return summary, err
+		}
+		summary.NodesUpdated += updated
+	}
+
+	for relationshipType, properties := range relationshipDateProperties {
+		updated, err := migrateDateProperties(
+			fmt.Sprintf("MATCH ()-[r:%s]->() RETURN elementId(r) AS id, properties(r) AS props", relationshipType),
+			`UNWIND $updates AS update
+			MATCH ()-[r]->() WHERE elementId(r) = update.id
+			SET r += update.props`,
+			relationshipType,
+			properties,
+			&summary,
+			ctx,
+			driver,
+		)
+		if err != nil {
+			return summary, err
+		}
+		summary.RelationshipsUpdated += updated
+	}
+
+	return summary, nil
+}
+
+func migrateDateProperties(readQuery string, writeQuery string, label string, properties []string, summary *DateMigrationSummary, ctx context.Context, driver neo4j.DriverWithContext) (updated int, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		readQuery,
+		nil,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return updated, err
+	}
+
+	var updates []map[string]any
+	for _, record := range result.Records {
+
+		id, _, err := neo4j.GetRecordValue[string](record, "id")
+		if err != nil {
+			return updated, err
+		}
+		props, _, err := neo4j.GetRecordValue[map[string]any](record, "props")
+		if err != nil {
+			return updated, err
+		}
+
+		updatedProps := map[string]any{}
+		for _, property := range properties {
+			switch value := props[property].(type) {
+			case int64:
+				updatedProps[property] = time.UnixMilli(value).UTC()
+			case string:
+				if value == "" {
+					updatedProps[property] = nil
+					continue
+				}
+				parsedTime, err := ParseFeedDate(value)
+				if err != nil {
+					summary.Unparseable = append(summary.Unparseable, fmt.Sprintf("%s %s.%s: %q", label, id, property, value))
+					continue
+				}
+				updatedProps[property] = parsedTime
+			}
+		}
+
+		if len(updatedProps) > 0 {
+			updates = append(updates, map[string]any{"id": id, "props": updatedProps})
+		}
+	}
+
+	if len(updates) == 0 {
+		return updated, nil
+	}
+
+	_, err = neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		writeQuery,
+		map[string]any{"updates": updates},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return updated, err
+	}
+
+	return len(updates), nil
+}
@@ -0,0 +1,281 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// An email newsletter read from a local mailbox. Path is an mbox file or a Maildir directory inside the mail
+// root (see ResolveMailboxPath) that the newsletter is delivered to. The mailbox is read by the scheduler
+// once a day at ScheduledTime, a "15:04" time of day in UTC (see SourceIsDue):
+type NewsletterSource struct {
+	Id            string    `json:"id"`
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`
+	Description   string    `json:"description"`
+	ScheduledTime string    `json:"scheduled_time"`
+	LastIngested  time.Time `json:"last_ingested"`
+}
+
+var ErrMailboxOutsideRoot = errors.New("the mailbox is outside of the mail root")
+
+// Settings for reading newsletters. Mailboxes are only read from inside MailRoot, set in main from the
+// command line flags, so that the paths sent to the api can't be used to read any other file of the server:
+type NewsletterConfig struct {
+	MailRoot string
+}
+
+var Newsletters = NewsletterConfig{}
+
+// Resolves the path of a mailbox, relative to the mail root unless it is absolute, and checks that it is
+// inside the mail root once symbolic links have been followed:
+func ResolveMailboxPath(mailboxPath string) (resolvedPath string, err error) {
+
+	if Newsletters.MailRoot == "" {
+		return resolvedPath, fmt.Errorf("%w: no mail root is configured", ErrMailboxOutsideRoot)
+	}
+	root, err := filepath.Abs(Newsletters.MailRoot)
+	if err != nil {
+		return resolvedPath, err
+	}
+	root = followSymlinks(root)
+
+	resolvedPath = filepath.Clean(mailboxPath)
+	if !filepath.IsAbs(resolvedPath) {
+		resolvedPath = filepath.Join(root, resolvedPath)
+	}
+	resolvedPath = followSymlinks(resolvedPath)
+
+	relativePath, err := filepath.Rel(root, resolvedPath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrMailboxOutsideRoot, mailboxPath)
+	}
+
+	return resolvedPath, nil
+}
+
+// Follows the symbolic links of a path. Paths that don't exist yet are returned as they are:
+func followSymlinks(path string) string {
+	if evaluatedPath, err := filepath.EvalSymlinks(path); err == nil {
+		return evaluatedPath
+	}
+	return path
+}
+
+// The length of the plain text preview stored as the description of a newsletter article:
+const newsletterPreviewLength = 280
+
+// The "view this email in your browser" link most newsletter platforms add to the top of their emails:
+var viewInBrowserText = regexp.MustCompile(`(?i)(view|read|open)\b.*\b(in|on) (your |a |the )?(web )?browser|(view|read) (it |this )?(email )?online|web version`)
+
+// Creates a Newsletter:Source node for the newsletter if one with the same name does not already exist:
+func CreateNewsletterSource(newsletter NewsletterSource, ctx context.Context, driver neo4j.DriverWithContext) (insertedNewsletter NewsletterSource, err error) {
+
+	if strings.TrimSpace(newsletter.Name) == "" || strings.TrimSpace(newsletter.Path) == "" {
+		return insertedNewsletter, fmt.Errorf("a newsletter needs both a name and the path of its mailbox")
+	}
+	if _, err = ResolveMailboxPath(strings.TrimSpace(newsletter.Path)); err != nil {
+		return insertedNewsletter, err
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MERGE (newsletter:Newsletter:Source {name: $name})
+		ON CREATE SET
+			newsletter.path = $path,
+			newsletter.description = $description,
+			newsletter.scheduled_time = $scheduled_time,
+			newsletter.created = datetime({timezone: 'UTC'})
+		RETURN newsletter`,
+		map[string]any{
+			"name":           strings.TrimSpace(newsletter.Name),
+			"path":           strings.TrimSpace(newsletter.Path),
+			"description":    newsletter.Description,
+			"scheduled_time": newsletter.ScheduledTime,
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return insertedNewsletter, err
+	}
+	if len(result.Records) == 0 {
+		return insertedNewsletter, fmt.Errorf("no newsletter source node returned when creating newsletter %s", newsletter.Name)
+	}
+
+	node, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "newsletter")
+	if err != nil {
+		return insertedNewsletter, err
+	}
+
+	return NewsletterSourceFromNode(node), nil
+}
+
+// Returns every Newsletter:Source node in the database:
+func GetNewsletterSources(ctx context.Context, driver neo4j.DriverWithContext) (newsletters []NewsletterSource, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (newsletter:Newsletter:Source) RETURN newsletter ORDER BY newsletter.name`,
+		nil,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return newsletters, err
+	}
+
+	newsletters = []NewsletterSource{}
+	for _, record := range result.Records {
+		node, _, err := neo4j.GetRecordValue[neo4j.Node](record, "newsletter")
+		if err != nil {
+			return newsletters, err
+		}
+		newsletters = append(newsletters, NewsletterSourceFromNode(node))
+	}
+
+	return newsletters, nil
+}
+
+// Converts a Newsletter:Source node into a NewsletterSource struct:
+func NewsletterSourceFromNode(node neo4j.Node) (newsletter NewsletterSource) {
+
+	nodeProps := node.GetProperties()
+	newsletter.Id = node.GetElementId()
+	if name, ok := nodeProps["name"].(string); ok {
+		newsletter.Name = name
+	}
+	if mailboxPath, ok := nodeProps["path"].(string); ok {
+		newsletter.Path = mailboxPath
+	}
+	if description, ok := nodeProps["description"].(string); ok {
+		newsletter.Description = description
+	}
+	if scheduledTime, ok := nodeProps["scheduled_time"].(string); ok {
+		newsletter.ScheduledTime = scheduledTime
+	}
+	newsletter.LastIngested = timeFromNodeProperty(nodeProps, "last_ingested")
+
+	return newsletter
+}
+
+// Maps an email onto an article. Emails have no url of their own so they are given a mid: url (RFC 2392)
+// from their Message-ID, which is also their guid. The sender is the article's creator and the links in
+// the email are kept with their anchor text as the link title:
+func RssEntryFromMailMessage(message MailMessage) (rssEntry RssEntry) {
+
+	messageUrl := "mid:" + url.PathEscape(message.MessageId)
+
+	rssEntry.Guid = message.MessageId
+	rssEntry.Url = messageUrl
+	rssEntry.CanonicalUrl = messageUrl
+	rssEntry.Title = message.Subject
+	rssEntry.Content = message.HtmlBody
+	if rssEntry.Content == "" {
+		rssEntry.Content = message.TextBody
+	}
+	rssEntry.Description = mailPreview(message)
+	rssEntry.Creator = message.FromName
+	if rssEntry.Creator == "" {
+		rssEntry.Creator = message.FromEmail
+	}
+	rssEntry.DatePosted = message.Date
+	rssEntry.Charset = message.Charset
+	rssEntry.Categories = []string{}
+	rssEntry.Media = []RssMedia{}
+	rssEntry.Links = message.Links
+
+	for _, link := range message.Links {
+		if viewInBrowserText.MatchString(link.Title) {
+			rssEntry.ExternalUrl = link.Href
+			break
+		}
+	}
+
+	return rssEntry
+}
+
+// A short plain text preview of an email, taken from its text body or else the text of its html body:
+func mailPreview(message MailMessage) string {
+
+	text := message.TextBody
+	if text == "" && message.HtmlBody != "" {
+		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(message.HtmlBody)); err == nil {
+			doc.Find("script, style, head").Remove()
+			text = doc.Text()
+		}
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= newsletterPreviewLength {
+		return text
+	}
+	return string([]rune(text)[:newsletterPreviewLength]) + "…"
+}
+
+// Reads the mailbox of a newsletter and ingests every email that hasn't been ingested yet as an article of
+// the newsletter (see IngestSource and newsletterSource):
+func IngestNewsletter(name string, ctx context.Context, driver neo4j.DriverWithContext) (SummaryResponse RssFeedExtractionSummary, err error) {
+	return IngestSource("newsletter", name, ctx, driver)
+}
+
+// The email newsletter source type. Each email becomes an article with its sender as the author, and the
+// html body of the email is archived like a captured article page. Emails are never edited once they are
+// sent so existing articles are never updated:
+type newsletterSource struct{}
+
+func (newsletterSource) Label() string { return "Newsletter" }
+
+func (newsletterSource) Fetch(source SourceNode, ctx context.Context) (fetch SourceFetch, err error) {
+
+	newsletter := NewsletterSourceFromNode(source.Node)
+
+	// The mail root may have changed since the newsletter was created:
+	mailboxPath, err := ResolveMailboxPath(newsletter.Path)
+	if err != nil {
+		return fetch, newSourceError(StatusErrorParse, "The mailbox of the Newsletter is outside of the mail root", err)
+	}
+	messages, warnings, err := ReadMailbox(mailboxPath)
+	fetch.Warnings = warnings
+	if err != nil {
+		return fetch, newSourceError(StatusErrorParse, "Unable to read the mailbox of the Newsletter", err)
+	}
+
+	fetch.Items = make([]SourceItem, len(messages))
+	for i, message := range messages {
+		fetch.Items[i] = NewsletterItemFromMailMessage(message)
+	}
+	fetch.SourceProperties = map[string]any{"last_ingested": time.Now().UTC()}
+
+	return fetch, nil
+}
+
+// Normalises an email into the item of a newsletter source (see RssEntryFromMailMessage):
+func NewsletterItemFromMailMessage(message MailMessage) (item SourceItem) {
+
+	item.Entry = RssEntryFromMailMessage(message)
+
+	authorName := message.FromName
+	if authorName == "" {
+		authorName = message.FromEmail
+	}
+	if authorName != "" {
+		item.Authors = []RssAuthor{{Name: authorName, Email: message.FromEmail}}
+	}
+
+	if message.HtmlBody != "" {
+		item.Page = &ArticlePage{Url: item.Entry.Url, Body: []byte(message.HtmlBody), Charset: message.Charset}
+	}
+
+	return item
+}
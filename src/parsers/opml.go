@@ -0,0 +1,215 @@
+package parsers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OPML 2.0 document structure. Only the outline attributes used by feed readers are mapped:
+// http://opml.org/spec2.opml
+type Opml struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    OpmlHead `xml:"head"`
+	Body    OpmlBody `xml:"body"`
+}
+type OpmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+type OpmlBody struct {
+	Outlines []OpmlOutline `xml:"outline"`
+}
+type OpmlOutline struct {
+	Text        string        `xml:"text,attr"`
+	Title       string        `xml:"title,attr,omitempty"`
+	Type        string        `xml:"type,attr,omitempty"`
+	XmlUrl      string        `xml:"xmlUrl,attr,omitempty"`
+	HtmlUrl     string        `xml:"htmlUrl,attr,omitempty"`
+	Description string        `xml:"description,attr,omitempty"`
+	Language    string        `xml:"language,attr,omitempty"`
+	Category    string        `xml:"category,attr,omitempty"`
+	Outlines    []OpmlOutline `xml:"outline"`
+}
+
+// Per-feed result of an OPML import:
+type OpmlImportResult struct {
+	Id     string   `json:"id"`
+	Title  string   `json:"title"`
+	Url    string   `json:"url"`
+	Tags   []string `json:"tags"`
+	Status string   `json:"status"`
+	Error  string   `json:"error"`
+}
+
+type OpmlImportSummary struct {
+	Created int                `json:"created"`
+	Skipped int                `json:"skipped"`
+	Errors  int                `json:"errors"`
+	Feeds   []OpmlImportResult `json:"feeds"`
+}
+
+// Reads an OPML subscription list and returns one RssFeed per feed outline. Outlines without an xmlUrl
+// are treated as categories and the path of nested categories above a feed (eg: "Asia/Korea") is added
+// to the feed's tags, along with any paths declared in the outline's own category attribute. A feed that
+// appears more than once is returned once with the tags of every category it was listed under:
+func ParseOpml(reader io.Reader) (rssFeeds []RssFeed, err error) {
+
+	var opml Opml
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	err = decoder.Decode(&opml)
+	if err != nil {
+		return rssFeeds, fmt.Errorf("unable to parse opml document: %s", err.Error())
+	}
+
+	if opml.XMLName.Local != "opml" {
+		return rssFeeds, fmt.Errorf("document is not an opml document, root element is <%s>", opml.XMLName.Local)
+	}
+
+	feedIndexByUrl := map[string]int{}
+
+	var walkOutlines func(outlines []OpmlOutline, categoryPath []string)
+	walkOutlines = func(outlines []OpmlOutline, categoryPath []string) {
+		for _, outline := range outlines {
+
+			outlineName := strings.TrimSpace(outline.Text)
+			if outlineName == "" {
+				outlineName = strings.TrimSpace(outline.Title)
+			}
+
+			// Category outlines only contribute to the path of the feeds nested under them:
+			if strings.TrimSpace(outline.XmlUrl) == "" {
+				walkOutlines(outline.Outlines, append(append([]string{}, categoryPath...), outlineName))
+				continue
+			}
+
+			rssFeed := RssFeed{
+				Url:         strings.TrimSpace(outline.XmlUrl),
+				Title:       outlineName,
+				Description: outline.Description,
+				SiteLink:    outline.HtmlUrl,
+				Language:    outline.Language,
+			}
+
+			if len(categoryPath) > 0 {
+				rssFeed.Tags = appendUniqueTag(rssFeed.Tags, strings.Join(categoryPath, "/"))
+			}
+			for _, category := range strings.Split(outline.Category, ",") {
+				category = strings.Trim(strings.TrimSpace(category), "/")
+				if category != "" {
+					rssFeed.Tags = appendUniqueTag(rssFeed.Tags, category)
+				}
+			}
+
+			// Feeds listed under several categories are merged into one feed with all of their tags:
+			if existingIndex, ok := feedIndexByUrl[rssFeed.Url]; ok {
+				for _, tag := range rssFeed.Tags {
+					rssFeeds[existingIndex].Tags = appendUniqueTag(rssFeeds[existingIndex].Tags, tag)
+				}
+				continue
+			}
+
+			feedIndexByUrl[rssFeed.Url] = len(rssFeeds)
+			rssFeeds = append(rssFeeds, rssFeed)
+		}
+	}
+	walkOutlines(opml.Body.Outlines, nil)
+
+	return rssFeeds, nil
+}
+
+// Builds an OPML document from a list of rss feeds. Each tag is split on "/" into nested category
+// outlines so that tags created from an OPML import are exported with the same structure. Feeds with
+// more than one tag are listed under each of their categories and untagged feeds sit at the top level:
+func BuildOpml(title string, rssFeeds []RssFeed) ([]byte, error) {
+
+	type categoryNode struct {
+		outline  *OpmlOutline
+		children map[string]*categoryNode
+	}
+	root := &categoryNode{outline: &OpmlOutline{}, children: map[string]*categoryNode{}}
+
+	for _, rssFeed := range rssFeeds {
+
+		feedOutline := OpmlOutline{
+			Text:        rssFeed.Title,
+			Title:       rssFeed.Title,
+			Type:        "rss",
+			XmlUrl:      rssFeed.Url,
+			HtmlUrl:     rssFeed.SiteLink,
+			Description: rssFeed.Description,
+			Language:    rssFeed.Language,
+		}
+
+		if len(rssFeed.Tags) == 0 {
+			root.outline.Outlines = append(root.outline.Outlines, feedOutline)
+			continue
+		}
+
+		for _, tag := range rssFeed.Tags {
+			current := root
+			for _, categoryName := range strings.Split(strings.Trim(tag, "/"), "/") {
+				child, ok := current.children[categoryName]
+				if !ok {
+					child = &categoryNode{
+						outline:  &OpmlOutline{Text: categoryName, Title: categoryName},
+						children: map[string]*categoryNode{},
+					}
+					current.children[categoryName] = child
+				}
+				current = child
+			}
+			current.outline.Outlines = append(current.outline.Outlines, feedOutline)
+		}
+	}
+
+	// Category outlines are written before the feeds of the same level, in alphabetical order so exports are stable:
+	var buildOutlines func(node *categoryNode) []OpmlOutline
+	buildOutlines = func(node *categoryNode) []OpmlOutline {
+
+		categoryNames := make([]string, 0, len(node.children))
+		for categoryName := range node.children {
+			categoryNames = append(categoryNames, categoryName)
+		}
+		sort.Strings(categoryNames)
+
+		var outlines []OpmlOutline
+		for _, categoryName := range categoryNames {
+			child := node.children[categoryName]
+			categoryOutline := *child.outline
+			categoryOutline.Outlines = buildOutlines(child)
+			outlines = append(outlines, categoryOutline)
+		}
+		return append(outlines, node.outline.Outlines...)
+	}
+
+	opml := Opml{
+		Version: "2.0",
+		Head: OpmlHead{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: OpmlBody{Outlines: buildOutlines(root)},
+	}
+
+	opmlBytes, err := xml.MarshalIndent(opml, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), opmlBytes...), nil
+}
+
+func appendUniqueTag(tags []string, tag string) []string {
+	for _, existingTag := range tags {
+		if existingTag == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
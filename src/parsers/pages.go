@@ -0,0 +1,191 @@
+package parsers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Settings for storing the html of article pages in object storage. Pages are only captured when a minio
+// Client is provided:
+type PageCaptureConfig struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// The page capture settings. Set in main from the command line flags:
+var PageCaptures = PageCaptureConfig{
+	Bucket: "html",
+}
+
+// An article page fetched from its site along with the metadata read from its <head>. Body is the page
+// transcoded to UTF-8 and Charset the charset it was served in:
+type ArticlePage struct {
+	Url           string
+	StatusCode    int
+	Body          []byte
+	Charset       string
+	Title         string
+	Description   string
+	Author        string
+	ImageUrl      string
+	CanonicalUrl  string
+	DatePublished time.Time
+	DateModified  time.Time
+	Metadata      PageMetadata
+}
+
+// Marks ctx so that the page of an existing article skips the robots.txt check (see WithIgnoreRobots) when
+// the article belongs to a source that we have permission to archive (see RssFeed.IgnoreRobots):
+func articlePageContext(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (context.Context, error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (source:Source)-[:CONTAINS_ARTICLE]->(article:Article)
+		WHERE elementId(article) = $id AND source.ignore_robots = true
+		RETURN count(source) AS sources`,
+		map[string]any{"id": articleId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return ctx, err
+	}
+	if len(result.Records) == 0 {
+		return ctx, nil
+	}
+	sources, _, err := neo4j.GetRecordValue[int64](result.Records[0], "sources")
+	if err != nil || sources == 0 {
+		return ctx, err
+	}
+
+	return WithIgnoreRobots(ctx), nil
+}
+
+// Fetches an article page and reads its metadata (see ExtractPageMetadata). Pages that respond with an error
+// status are returned with an error and their StatusCode set:
+func FetchArticlePage(ctx context.Context, pageUrl string) (page ArticlePage, err error) {
+
+	page.Url = pageUrl
+
+	resp, err := DefaultFetcher.Get(ctx, pageUrl)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+	page.StatusCode = resp.StatusCode
+	page.Url = resp.Request.URL.String()
+
+	if resp.StatusCode > 300 {
+		return page, fmt.Errorf("request to %s returned status code: %d", pageUrl, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return page, err
+	}
+	page.Body, page.Charset, err = DecodeToUtf8(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return page, err
+	}
+
+	err = readArticlePageMetadata(&page)
+	return page, err
+}
+
+// Reads the metadata of a page (see ExtractPageMetadata) and fills in its fields from it:
+func readArticlePageMetadata(page *ArticlePage) (err error) {
+
+	page.Metadata, err = ExtractPageMetadata(page.Url, page.Body)
+	if err != nil {
+		return err
+	}
+
+	page.Title = page.Metadata.Title
+	page.Description = page.Metadata.Description
+	page.Author = page.Metadata.Byline
+	page.ImageUrl = page.Metadata.LeadImage
+	page.CanonicalUrl = page.Metadata.CanonicalUrl
+	page.DatePublished = page.Metadata.DatePublished
+	page.DateModified = page.Metadata.DateModified
+
+	return nil
+}
+
+// Uploads an article's page into the page capture bucket, marks the article as stored and records the
+// links in its body (see RecordArticleLinks). Pages are kept under html/<source title>/ and named by the
+// hash of their url:
+func CaptureArticlePage(sourceTitle string, articleId string, page ArticlePage, ctx context.Context, driver neo4j.DriverWithContext) (storageUrl string, err error) {
+
+	if PageCaptures.Client == nil {
+		return "", fmt.Errorf("unable to capture %s, object storage is not configured", page.Url)
+	}
+
+	pageFile, err := os.CreateTemp(captureTempDir(), "page-*.html")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(pageFile.Name())
+	defer pageFile.Close()
+
+	if _, err = pageFile.Write(page.Body); err != nil {
+		return "", err
+	}
+	if _, err = pageFile.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	urlHash := sha1.Sum([]byte(page.Url))
+	bucketFilePath := path.Join("html", storagePathComponent(sourceTitle), hex.EncodeToString(urlHash[:])+".html")
+	storageUrl, err = UploadHtmlFileToStatic(ctx, PageCaptures.Client, PageCaptures.Bucket, bucketFilePath, pageFile)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $id
+		SET
+			article.static_file_url = $storage_url,
+			article.in_static_file_storage = 1,
+			article.charset = CASE WHEN coalesce(article.charset, '') = '' THEN $charset ELSE article.charset END
+		RETURN article`,
+		map[string]any{"id": articleId, "storage_url": storageUrl, "charset": page.Charset},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return storageUrl, err
+	}
+	if len(result.Records) == 0 {
+		return storageUrl, fmt.Errorf("unable to find article %s to record its captured page", articleId)
+	}
+
+	if _, err = RecordArticleLinks(articleId, page, ctx, driver); err != nil {
+		return storageUrl, fmt.Errorf("captured %s but unable to record its links: %w", page.Url, err)
+	}
+
+	return storageUrl, nil
+}
+
+// Pages are written to the temp directory next to the src directory before they are uploaded:
+func captureTempDir() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return os.TempDir()
+	}
+	tempDir := filepath.Join(filepath.Dir(wd), "temp")
+	if err = os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return os.TempDir()
+	}
+	return tempDir
+}
@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// A transcript of a podcast episode from a <podcast:transcript> element:
+type RssTranscript struct {
+	Url      string `json:"url"`
+	Type     string `json:"type"`
+	Language string `json:"language"`
+	Rel      string `json:"rel"`
+}
+
+// Sets the podcast fields of an entry from the itunes:* and podcast:* elements of its item. The podcast
+// namespace's <podcast:season> and <podcast:episode> are used when the itunes ones are missing:
+func setRssEntryPodcastFields(rssEntry *RssEntry, item *gofeed.Item) {
+
+	if item.ITunesExt != nil {
+		rssEntry.Duration = ParseItunesDuration(item.ITunesExt.Duration)
+		rssEntry.Episode, _ = strconv.ParseInt(strings.TrimSpace(item.ITunesExt.Episode), 10, 64)
+		rssEntry.Season, _ = strconv.ParseInt(strings.TrimSpace(item.ITunesExt.Season), 10, 64)
+		rssEntry.EpisodeType = strings.ToLower(strings.TrimSpace(item.ITunesExt.EpisodeType))
+		switch strings.ToLower(strings.TrimSpace(item.ITunesExt.Explicit)) {
+		case "yes", "true", "explicit":
+			rssEntry.Explicit = true
+		}
+	}
+
+	rssEntry.Transcripts = []RssTranscript{}
+	podcastExtensions, ok := item.Extensions["podcast"]
+	if !ok {
+		return
+	}
+
+	if rssEntry.Season == 0 {
+		rssEntry.Season = firstExtensionInt(podcastExtensions["season"])
+	}
+	if rssEntry.Episode == 0 {
+		rssEntry.Episode = firstExtensionInt(podcastExtensions["episode"])
+	}
+	for _, transcript := range podcastExtensions["transcript"] {
+		if transcript.Attrs["url"] == "" {
+			continue
+		}
+		rssEntry.Transcripts = append(rssEntry.Transcripts, RssTranscript{
+			Url:      transcript.Attrs["url"],
+			Type:     transcript.Attrs["type"],
+			Language: transcript.Attrs["language"],
+			Rel:      transcript.Attrs["rel"],
+		})
+	}
+}
+
+// Converts an itunes:duration into seconds. Durations are given as seconds or as "MM:SS" or "HH:MM:SS":
+func ParseItunesDuration(duration string) int64 {
+
+	duration = strings.TrimSpace(duration)
+	if duration == "" {
+		return 0
+	}
+
+	var seconds float64
+	for _, part := range strings.Split(duration, ":") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || value < 0 {
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+
+	return int64(seconds)
+}
+
+func firstExtensionInt(extensions []ext.Extension) int64 {
+	for _, extension := range extensions {
+		if value, err := strconv.ParseFloat(strings.TrimSpace(extension.Value), 64); err == nil {
+			return int64(value)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,196 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+type ignoreRobotsKey struct{}
+
+// Marks a context so that requests made with it skip the robots.txt check. Only used for sources that we
+// have permission to archive (see RssFeed.IgnoreRobots). The per domain delay and concurrency still apply:
+func WithIgnoreRobots(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ignoreRobotsKey{}, true)
+}
+
+func ignoresRobots(ctx context.Context) bool {
+	ignore, _ := ctx.Value(ignoreRobotsKey{}).(bool)
+	return ignore
+}
+
+// Wraps a transport so that every request to a host waits its turn. No more than MaxDomainConcurrency
+// requests are made to a host at once and requests start at least DomainDelay apart, or the host's
+// robots.txt Crawl-delay if that is longer (up to MaxCrawlDelay). Paths that robots.txt disallows fail
+// with ErrDisallowedByRobots:
+type politeTransport struct {
+	config FetcherConfig
+	base   http.RoundTripper
+	sleep  func(ctx context.Context, delay time.Duration) error
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+
+	mu          sync.Mutex
+	nextRequest time.Time
+
+	// Held while the host's robots.txt is being fetched, so that it is only fetched by one request:
+	robotsMu      sync.Mutex
+	robots        *robotstxt.RobotsData
+	robotsExpires time.Time
+}
+
+func newPoliteTransport(config FetcherConfig, base http.RoundTripper) *politeTransport {
+	return &politeTransport{config: config, base: base, sleep: sleepContext, hosts: map[string]*hostState{}}
+}
+
+func (transport *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	host := transport.host(req.URL.Scheme + "://" + req.URL.Host)
+	ctx := req.Context()
+
+	crawlDelay := time.Duration(0)
+	if transport.config.RespectRobots && !ignoresRobots(ctx) && req.URL.Path != "/robots.txt" {
+		robots := transport.robots(req, host)
+		if !robots.TestAgent(req.URL.RequestURI(), req.Header.Get("User-Agent")) {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowedByRobots, req.URL)
+		}
+		crawlDelay = robots.FindGroup(req.Header.Get("User-Agent")).CrawlDelay
+	}
+
+	delay := transport.config.DomainDelay
+	if crawlDelay > delay {
+		delay = crawlDelay
+	}
+	if transport.config.MaxCrawlDelay > 0 && delay > transport.config.MaxCrawlDelay {
+		delay = transport.config.MaxCrawlDelay
+	}
+
+	// Waiting for one of the host's slots and then for its turn:
+	select {
+	case host.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var releaseOnce sync.Once
+	release := func() { releaseOnce.Do(func() { <-host.slots }) }
+
+	host.mu.Lock()
+	now := time.Now()
+	wait := host.nextRequest.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	host.nextRequest = now.Add(wait + delay)
+	host.mu.Unlock()
+
+	if wait > 0 {
+		if err := transport.sleep(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	resp, err := transport.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// The slot is held until the body has been read and closed:
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+func (transport *politeTransport) host(hostKey string) *hostState {
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	host, ok := transport.hosts[hostKey]
+	if !ok {
+		concurrency := transport.config.MaxDomainConcurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		host = &hostState{slots: make(chan struct{}, concurrency)}
+		transport.hosts[hostKey] = host
+	}
+	return host
+}
+
+// Returns the host's robots.txt rules, fetching them if the cached ones have expired (see fetchRobots):
+func (transport *politeTransport) robots(req *http.Request, host *hostState) *robotstxt.RobotsData {
+
+	host.robotsMu.Lock()
+	defer host.robotsMu.Unlock()
+
+	if host.robots != nil && time.Now().Before(host.robotsExpires) {
+		return host.robots
+	}
+
+	robots, ttl := transport.fetchRobots(req)
+	host.robots = robots
+	host.robotsExpires = time.Now().Add(ttl)
+
+	return host.robots
+}
+
+// Fetches and parses the robots.txt of a request's host along with how long to cache it. Rules that were
+// parsed and the allow everything of a 4xx response are cached for RobotsCacheTtl. A 5xx response or a
+// robots.txt that can't be fetched or parsed disallows everything until it is fetched again after
+// RobotsRetryAfter. The rules are shared by every request to the host, so they aren't fetched with the
+// context of the request that needed them and cancelling that request doesn't fail the fetch:
+func (transport *politeTransport) fetchRobots(req *http.Request) (*robotstxt.RobotsData, time.Duration) {
+
+	disallowAll, _ := robotstxt.FromStatusAndBytes(http.StatusServiceUnavailable, nil)
+
+	robotsReq, err := http.NewRequest(http.MethodGet, req.URL.Scheme+"://"+req.URL.Host+"/robots.txt", nil)
+	if err != nil {
+		return disallowAll, transport.config.RobotsRetryAfter
+	}
+	robotsReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+
+	// Redirects are followed, as many sites redirect their robots.txt to https or another host:
+	client := &http.Client{Transport: transport.base, Timeout: transport.config.RequestTimeout}
+	resp, err := client.Do(robotsReq)
+	if err != nil {
+		fmt.Println("Unable to fetch robots.txt for", req.URL.Host, err)
+		return disallowAll, transport.config.RobotsRetryAfter
+	}
+	defer resp.Body.Close()
+
+	robots, err := robotstxt.FromResponse(resp)
+	if err != nil || robots == nil {
+		fmt.Println("Unable to parse robots.txt for", req.URL.Host, err)
+		return disallowAll, transport.config.RobotsRetryAfter
+	}
+	if resp.StatusCode >= 500 {
+		return robots, transport.config.RobotsRetryAfter
+	}
+
+	return robots, transport.config.RobotsCacheTtl
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (body *releasingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.release()
+	return err
+}
@@ -0,0 +1,326 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A previous version of an article. The values are the ones the article held between ValidFrom and ValidTo
+// and Changes lists how they differ from the version that replaced them:
+type RssEntryRevision struct {
+	Id          string                `json:"id"`
+	Revision    int64                 `json:"revision"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	DatePosted  time.Time             `json:"date_posted"`
+	ValidFrom   time.Time             `json:"valid_from"`
+	ValidTo     time.Time             `json:"valid_to"`
+	Changes     []RevisionFieldChange `json:"changes"`
+}
+
+type RevisionFieldChange struct {
+	Field    string `json:"field"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Diff     string `json:"diff"`
+}
+
+type RssEntryRevisionHistory struct {
+	Article   RssEntry           `json:"article"`
+	Revisions []RssEntryRevision `json:"revisions"`
+}
+
+// Compares an article stored in the database to the version of the item currently in the feed and returns
+// the names of the fields that have changed. Fields that were filled in from the article's page (see
+// filledFromPage) are not compared, as the feed never supplied them:
+func ChangedRssEntryFields(existingEntry RssEntry, updatedEntry RssEntry) (changedFields []string) {
+	if !filledFromPage(existingEntry, "title") && existingEntry.Title != updatedEntry.Title {
+		changedFields = append(changedFields, "title")
+	}
+	if !filledFromPage(existingEntry, "description") && existingEntry.Description != updatedEntry.Description {
+		changedFields = append(changedFields, "description")
+	}
+	if !filledFromPage(existingEntry, "date_published") && !existingEntry.DatePosted.Equal(updatedEntry.DatePosted) {
+		changedFields = append(changedFields, "date_posted")
+	}
+	return changedFields
+}
+
+// Reports whether a field of an article was filled in from the metadata of its page (see
+// MergeEntryMetadata) rather than by its source. name is the field's key in the article's metadata_sources:
+func filledFromPage(entry RssEntry, name string) bool {
+	source, recorded := entry.MetadataSources[name]
+	return recorded && source != MetadataFromRss
+}
+
+// Updates the title, description and published date of existing articles in place. The values being
+// replaced are kept on a new Revision node connected to the article, and each revision points to the one
+// before it so that the full version chain of the article is preserved. The article's guid and canonical
+// url are also set if it was ingested before they were stored. Each row of $updates is built by
+// rssArticleUpdateParams:
+const updateRssArticlesQuery = `
+UNWIND $updates AS update
+MATCH (article:Article)
+WHERE elementId(article) = update.id
+
+OPTIONAL MATCH (article)-[:HAS_REVISION]->(previous:Revision)
+WITH article, update, previous
+ORDER BY previous.revision DESC
+WITH article, update, head(collect(previous)) AS previous
+
+CREATE (revision:Revision {
+	revision: coalesce(previous.revision, 0) + 1,
+	name: article.name,
+	description: article.description,
+	date_posted: article.date_posted,
+	valid_from: coalesce(previous.valid_to, article.created),
+	valid_to: datetime({timezone: 'UTC'})
+})
+CREATE (article)-[:HAS_REVISION]->(revision)
+FOREACH (p IN CASE WHEN previous IS NULL THEN [] ELSE [previous] END |
+	CREATE (revision)-[:PREVIOUS_REVISION]->(p)
+)
+
+SET
+	article.name = update.name,
+	article.description = update.description,
+	article.date_posted = update.date_posted,
+	article.guid = CASE WHEN update.guid <> "" THEN update.guid ELSE article.guid END,
+	article.canonical_url = coalesce(article.canonical_url, update.canonical_url),
+	article.updated = datetime({timezone: 'UTC'})
+RETURN update.id AS id
+`
+
+// Builds the row passed to updateRssArticlesQuery for an article and the changed version of its feed item.
+// The fields that were filled in from the article's page keep their values (see ChangedRssEntryFields):
+func rssArticleUpdateParams(existingEntry RssEntry, updatedEntry RssEntry) map[string]any {
+
+	if filledFromPage(existingEntry, "title") {
+		updatedEntry.Title = existingEntry.Title
+	}
+	if filledFromPage(existingEntry, "description") {
+		updatedEntry.Description = existingEntry.Description
+	}
+	if filledFromPage(existingEntry, "date_published") {
+		updatedEntry.DatePosted = existingEntry.DatePosted
+	}
+
+	return map[string]any{
+		"id":            existingEntry.Id,
+		"name":          updatedEntry.Title,
+		"description":   updatedEntry.Description,
+		"date_posted":   neo4jDateTime(updatedEntry.DatePosted),
+		"guid":          updatedEntry.Guid,
+		"canonical_url": CanonicalizeUrl(existingEntry.Url),
+	}
+}
+
+// Updates a single existing article and records its previous values as a Revision (see
+// updateRssArticlesQuery). Feed ingestion updates articles in batches instead (see WriteRssFeedItems):
+func UpdateRssArticle(existingEntry RssEntry, updatedEntry RssEntry, ctx context.Context, driver neo4j.DriverWithContext) (err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		updateRssArticlesQuery,
+		map[string]any{"updates": []map[string]any{rssArticleUpdateParams(existingEntry, updatedEntry)}},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err
+	}
+
+	if len(result.Records) == 0 {
+		return fmt.Errorf("unable to find article %s to update", existingEntry.Id)
+	}
+
+	fmt.Printf(
+		"Created %v nodes in %+v. \n",
+		result.Summary.Counters().NodesCreated(),
+		result.Summary.ResultAvailableAfter(),
+	)
+
+	return nil
+}
+
+// Returns an article and all of its revisions, oldest first. Each revision's changes are computed against
+// the version that replaced it, which is either the next revision or the article's current values:
+func GetRssArticleRevisions(id string, ctx context.Context, driver neo4j.DriverWithContext) (history RssEntryRevisionHistory, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (article:Article)
+		WHERE elementId(article) = $id
+		OPTIONAL MATCH (article)-[:HAS_REVISION]->(revision:Revision)
+		WITH article, revision
+		ORDER BY revision.revision ASC
+		RETURN article, collect(revision) AS revisions
+		`,
+		map[string]any{"id": id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return history, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("%w: %s", ErrArticleNotFound, id)
+		return history, err
+	}
+
+	articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "article")
+	if err != nil {
+		return history, err
+	}
+	history.Article = RssEntryFromNode(articleNode)
+
+	revisionNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "revisions")
+	if err != nil {
+		return history, err
+	}
+
+	history.Revisions = []RssEntryRevision{}
+	for _, revisionNode := range revisionNodes {
+		if node, ok := revisionNode.(neo4j.Node); ok {
+			history.Revisions = append(history.Revisions, rssEntryRevisionFromNode(node))
+		}
+	}
+
+	BuildRevisionChanges(&history)
+
+	return history, nil
+}
+
+// Fills in the Changes of every revision in a history by comparing it to the version that replaced it:
+func BuildRevisionChanges(history *RssEntryRevisionHistory) {
+
+	for i := range history.Revisions {
+
+		replacedBy := RssEntry{
+			Title:       history.Article.Title,
+			Description: history.Article.Description,
+			DatePosted:  history.Article.DatePosted,
+		}
+		if i+1 < len(history.Revisions) {
+			replacedBy.Title = history.Revisions[i+1].Title
+			replacedBy.Description = history.Revisions[i+1].Description
+			replacedBy.DatePosted = history.Revisions[i+1].DatePosted
+		}
+
+		revision := history.Revisions[i]
+		previousValues := map[string]string{
+			"title":       revision.Title,
+			"description": revision.Description,
+			"date_posted": formatDateTime(revision.DatePosted),
+		}
+		currentValues := map[string]string{
+			"title":       replacedBy.Title,
+			"description": replacedBy.Description,
+			"date_posted": formatDateTime(replacedBy.DatePosted),
+		}
+
+		history.Revisions[i].Changes = []RevisionFieldChange{}
+		for _, field := range ChangedRssEntryFields(revisionAsEntry(revision), replacedBy) {
+			history.Revisions[i].Changes = append(history.Revisions[i].Changes, RevisionFieldChange{
+				Field:    field,
+				Previous: previousValues[field],
+				Current:  currentValues[field],
+				Diff:     DiffWords(previousValues[field], currentValues[field]),
+			})
+		}
+	}
+}
+
+// Produces a word level diff of two strings with removed words wrapped in [-...-] and added words wrapped
+// in {+...+}, eg: "North Korea [-Launches-] {+Tests+} Missile":
+func DiffWords(previous string, current string) string {
+
+	previousWords := strings.Fields(previous)
+	currentWords := strings.Fields(current)
+
+	// Longest common subsequence table of the two word lists:
+	lcs := make([][]int, len(previousWords)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(currentWords)+1)
+	}
+	for i := len(previousWords) - 1; i >= 0; i-- {
+		for j := len(currentWords) - 1; j >= 0; j-- {
+			if previousWords[i] == currentWords[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diffParts []string
+	var removed, added []string
+	flush := func() {
+		if len(removed) > 0 {
+			diffParts = append(diffParts, "[-"+strings.Join(removed, " ")+"-]")
+			removed = nil
+		}
+		if len(added) > 0 {
+			diffParts = append(diffParts, "{+"+strings.Join(added, " ")+"+}")
+			added = nil
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(previousWords) || j < len(currentWords) {
+		switch {
+		case i < len(previousWords) && j < len(currentWords) && previousWords[i] == currentWords[j]:
+			flush()
+			diffParts = append(diffParts, previousWords[i])
+			i++
+			j++
+		case j < len(currentWords) && (i == len(previousWords) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, currentWords[j])
+			j++
+		default:
+			removed = append(removed, previousWords[i])
+			i++
+		}
+	}
+	flush()
+
+	return strings.Join(diffParts, " ")
+}
+
+func revisionAsEntry(revision RssEntryRevision) RssEntry {
+	return RssEntry{
+		Title:       revision.Title,
+		Description: revision.Description,
+		DatePosted:  revision.DatePosted,
+	}
+}
+
+func rssEntryRevisionFromNode(node neo4j.Node) (revision RssEntryRevision) {
+
+	nodeProps := node.GetProperties()
+
+	revision.Id = node.ElementId
+
+	if revisionNumber, ok := nodeProps["revision"].(int64); ok {
+		revision.Revision = revisionNumber
+	}
+	if title, ok := nodeProps["name"].(string); ok {
+		revision.Title = title
+	}
+	if description, ok := nodeProps["description"].(string); ok {
+		revision.Description = description
+	}
+	revision.DatePosted = timeFromNodeProperty(nodeProps, "date_posted")
+	revision.ValidFrom = timeFromNodeProperty(nodeProps, "valid_from")
+	revision.ValidTo = timeFromNodeProperty(nodeProps, "valid_to")
+
+	return revision
+}
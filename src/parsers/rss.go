@@ -2,13 +2,15 @@ package parsers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/mmcdole/gofeed"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
@@ -16,10 +18,20 @@ import (
 // predefined schema:
 func ExtractFieldsFromRssFeed(file *os.File) (err error) {
 
-	fp := gofeed.NewParser()
-	feed, _ := fp.Parse(file)
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	feed, warnings, err := ParseFeed(body)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Println("Warning:", warning)
+	}
 
-	fmt.Println(feed.UpdatedParsed.Format(time.RFC3339Nano))
+	fmt.Println(FeedItemTime(feed.UpdatedParsed, feed.Updated).Format(time.RFC3339Nano))
 
 	for _, element := range feed.Items {
 
@@ -83,61 +95,124 @@ func GetRssSourceFromDatabase(name string, ctx context.Context, driver neo4j.Dri
 	for _, nodeKey := range extractedNodeDict {
 		switch node := nodeKey.(type) {
 		case neo4j.Node:
+			rssSourceArray = append(rssSourceArray, RssFeedFromNode(node))
+		}
+	}
 
-			nodeProps := node.GetProperties()
+	return rssSourceArray[0], err
 
-			rssSource := RssFeed{Id: node.ElementId}
+}
 
-			if url, ok := nodeProps["url"].(string); ok {
-				rssSource.Url = url
-			} else {
-				log.Fatal("No Url for Node", node.ElementId)
-			}
-			if title, ok := nodeProps["name"].(string); ok {
-				rssSource.Title = title
-			} else {
-				log.Fatal("No name for Node", node.ElementId)
-			}
+// Converts an Rss_Feed:Source node into an RssFeed struct. Optional properties that have not been
+// set on the node are left as empty strings:
+func RssFeedFromNode(node neo4j.Node) (rssFeed RssFeed) {
 
-			if scheduledTime, ok := nodeProps["scheduled_time"].(string); ok {
-				rssSource.ExecuteTime = scheduledTime
-			} else {
-				rssSource.ExecuteTime = ""
-			}
+	nodeProps := node.GetProperties()
 
-			if etag, ok := nodeProps["etag"].(string); ok {
-				rssSource.Etag = etag
-			} else {
-				rssSource.Etag = ""
-			}
+	rssFeed.Id = node.ElementId
 
-			if lastUpdated, ok := nodeProps["last_updated"].(string); ok {
-				rssSource.LastUpdate = lastUpdated
-			} else {
-				rssSource.LastUpdate = ""
-			}
+	if url, ok := nodeProps["url"].(string); ok {
+		rssFeed.Url = url
+	} else {
+		log.Println("No Url for Node", node.ElementId)
+	}
+	if title, ok := nodeProps["name"].(string); ok {
+		rssFeed.Title = title
+	} else {
+		log.Println("No name for Node", node.ElementId)
+	}
 
-			rssSourceArray = append(rssSourceArray, rssSource)
-		}
+	if description, ok := nodeProps["description"].(string); ok {
+		rssFeed.Description = description
+	}
+	if siteLink, ok := nodeProps["site_link"].(string); ok {
+		rssFeed.SiteLink = siteLink
 	}
+	if language, ok := nodeProps["language"].(string); ok {
+		rssFeed.Language = language
+	}
+	if imageUrl, ok := nodeProps["image_url"].(string); ok {
+		rssFeed.ImageUrl = imageUrl
+	}
+	if etag, ok := nodeProps["etag"].(string); ok {
+		rssFeed.Etag = etag
+	}
+	rssFeed.LastUpdate = timeFromNodeProperty(nodeProps, "last_updated")
 
-	return rssSourceArray[0], err
+	// Schedule and health of the feed, see RecordSourceHealth:
+	readSourceHealth(nodeProps, &rssFeed)
+	if ignoreRobots, ok := nodeProps["ignore_robots"].(bool); ok {
+		rssFeed.IgnoreRobots = ignoreRobots
+	}
+	if charsetName, ok := nodeProps["charset"].(string); ok {
+		rssFeed.Charset = charsetName
+	}
+	if feedType, ok := nodeProps["feed_type"].(string); ok {
+		rssFeed.FeedType = feedType
+	}
+	if feedVersion, ok := nodeProps["feed_version"].(string); ok {
+		rssFeed.FeedVersion = feedVersion
+	}
+	if rights, ok := nodeProps["rights"].(string); ok {
+		rssFeed.Rights = rights
+	}
 
+	return rssFeed
 }
 
-// Querying the database for a specific rss feed entry given a title and a url:
-func GetRssArticleFromDatabase(name string, url string, ctx context.Context, driver neo4j.DriverWithContext) (insertedEntry RssEntry, err error) {
+// Looks up the existing article for each row of $items. Articles are identified by the item's guid first
+// and then by the canonical form of its url (see CanonicalizeUrl) or its raw url, as the canonical url of
+// an article may have been replaced by the one its page declares (see MergeEntryMetadata) and articles
+// ingested before canonical urls were stored only have a raw url. Guids are only unique within a feed (many
+// feeds number their items) so they are only matched against the articles of the source $source_id, while
+// urls are matched against the articles of every source type so that an article submitted by hand or found
+// in a newsletter isn't created again when it shows up in a feed. Items without an existing article return
+// no row:
+const existingArticlesQuery = `
+UNWIND $items AS item
+CALL {
+	WITH item
+	CALL {
+		WITH item
+		MATCH (source:Source)-[:CONTAINS_ARTICLE]->(article:Article {guid: item.guid})
+		WHERE item.guid <> "" AND elementId(source) = $source_id
+		RETURN article, 0 AS rank
+		UNION
+		WITH item
+		MATCH (article:Article {canonical_url: item.canonical_url})
+		WHERE item.canonical_url <> ""
+		RETURN article, 1 AS rank
+		UNION
+		WITH item
+		MATCH (article:Article {url: item.url})
+		WHERE item.url <> ""
+		RETURN article, 2 AS rank
+	}
+	RETURN article
+	ORDER BY rank
+	LIMIT 1
+}
+RETURN item.index AS index, article
+`
+
+// Builds the row passed to existingArticlesQuery for a feed item:
+func existingRssArticleParams(index int, guid string, url string) map[string]any {
+	return map[string]any{"index": index, "guid": guid, "url": url, "canonical_url": CanonicalizeUrl(url)}
+}
+
+// Querying the database for an existing entry of the rss feed sourceId (see existingArticlesQuery).
+// Returns an empty RssEntry if the article has not been ingested yet:
+func GetRssArticleFromDatabase(sourceId string, guid string, url string, ctx context.Context, driver neo4j.DriverWithContext) (insertedEntry RssEntry, err error) {
 	// Querying the node from the graph database:
 	results, err := neo4j.ExecuteQuery(
 		ctx,
 		driver,
-		"MATCH (article:Rss_Feed:Article {name: $name, url: $url}) RETURN article",
-		map[string]any{"name": name, "url": url},
+		existingArticlesQuery,
+		map[string]any{"items": []map[string]any{existingRssArticleParams(0, guid, url)}, "source_id": sourceId},
 		neo4j.EagerResultTransformer,
 		neo4j.ExecuteQueryWithDatabase("neo4j"))
 
 	if err != nil {
-		log.Fatal(err)
 		return insertedEntry, err
 	}
 
@@ -145,72 +220,145 @@ func GetRssArticleFromDatabase(name string, url string, ctx context.Context, dri
 		return insertedEntry, nil
 	}
 
-	if len(results.Records) > 1 {
-		err = fmt.Errorf("more than one rss entry returned. %d returned", len(results.Records))
-		log.Fatal(err)
+	articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](results.Records[0], "article")
+	if err != nil {
 		return insertedEntry, err
 	}
 
-	var rssEntryArray []RssEntry
-
-	extractedNodeDict := results.Records[0].AsMap()
-
-	for _, nodeKey := range extractedNodeDict {
-		switch node := nodeKey.(type) {
-		case neo4j.Node:
+	return RssEntryFromNode(articleNode), nil
+}
 
-			nodeProps := node.GetProperties()
+// Converts an Article node of any source type into an RssEntry struct:
+func RssEntryFromNode(node neo4j.Node) (rssEntry RssEntry) {
 
-			rssEntry := RssEntry{Id: node.ElementId}
+	nodeProps := node.GetProperties()
 
-			if url, ok := nodeProps["url"].(string); ok {
-				rssEntry.Url = url
-			} else {
-				log.Fatal("No Url for Node", node.ElementId)
-			}
-			if title, ok := nodeProps["name"].(string); ok {
-				rssEntry.Title = title
-			} else {
-				log.Fatal("No name for Node", node.ElementId)
-			}
+	rssEntry.Id = node.ElementId
 
-			if datePosted, ok := nodeProps["date_posted"].(string); ok {
-				rssEntry.DatePosted = datePosted
-			} else {
-				log.Fatal("No Date Posted for Node", node.ElementId)
-			}
+	if url, ok := nodeProps["url"].(string); ok {
+		rssEntry.Url = url
+	} else {
+		log.Println("No Url for Node", node.ElementId)
+	}
+	if title, ok := nodeProps["name"].(string); ok {
+		rssEntry.Title = title
+	} else {
+		log.Println("No name for Node", node.ElementId)
+	}
 
-			if StaticFileUrl, ok := nodeProps["static_file_url"].(string); ok {
-				rssEntry.StorageUrl = StaticFileUrl
-			} else {
-				rssEntry.StorageUrl = ""
+	if guid, ok := nodeProps["guid"].(string); ok {
+		rssEntry.Guid = guid
+	}
+	if canonicalUrl, ok := nodeProps["canonical_url"].(string); ok {
+		rssEntry.CanonicalUrl = canonicalUrl
+	}
+	if description, ok := nodeProps["description"].(string); ok {
+		rssEntry.Description = description
+	}
+	if content, ok := nodeProps["content"].(string); ok {
+		rssEntry.Content = content
+	}
+	if creator, ok := nodeProps["creator"].(string); ok {
+		rssEntry.Creator = creator
+	}
+	if imageUrl, ok := nodeProps["image_url"].(string); ok {
+		rssEntry.ImageUrl = imageUrl
+	}
+	rssEntry.DatePosted = timeFromNodeProperty(nodeProps, "date_posted")
+	rssEntry.DateUpdated = timeFromNodeProperty(nodeProps, "date_updated")
+	rssEntry.DateExtracted = timeFromNodeProperty(nodeProps, "created")
+	if extensions, ok := nodeProps["extensions"].(string); ok {
+		rssEntry.ExtensionsJson = extensions
+	}
+	if charsetName, ok := nodeProps["charset"].(string); ok {
+		rssEntry.Charset = charsetName
+	}
+	if linksJson, ok := nodeProps["links"].(string); ok && linksJson != "" {
+		json.Unmarshal([]byte(linksJson), &rssEntry.Links)
+	}
+	if externalUrl, ok := nodeProps["external_url"].(string); ok {
+		rssEntry.ExternalUrl = externalUrl
+	}
+	if rights, ok := nodeProps["rights"].(string); ok {
+		rssEntry.Rights = rights
+	}
+	if contributors, ok := nodeProps["contributors"].([]any); ok {
+		for _, contributor := range contributors {
+			if contributorName, ok := contributor.(string); ok {
+				rssEntry.Contributors = append(rssEntry.Contributors, contributorName)
 			}
-
-			if InStorage, ok := nodeProps["in_static_file_storage"].(int); ok {
-				rssEntry.InStorage = InStorage
-			} else {
-				rssEntry.InStorage = 0
+		}
+	}
+	if sourceFeedTitle, ok := nodeProps["source_feed_title"].(string); ok {
+		rssEntry.SourceFeedTitle = sourceFeedTitle
+	}
+	if sourceFeedUrl, ok := nodeProps["source_feed_url"].(string); ok {
+		rssEntry.SourceFeedUrl = sourceFeedUrl
+	}
+	if duration, ok := nodeProps["duration"].(int64); ok {
+		rssEntry.Duration = duration
+	}
+	if episode, ok := nodeProps["episode"].(int64); ok {
+		rssEntry.Episode = episode
+	}
+	if season, ok := nodeProps["season"].(int64); ok {
+		rssEntry.Season = season
+	}
+	if episodeType, ok := nodeProps["episode_type"].(string); ok {
+		rssEntry.EpisodeType = episodeType
+	}
+	if explicit, ok := nodeProps["explicit"].(bool); ok {
+		rssEntry.Explicit = explicit
+	}
+	if transcriptsJson, ok := nodeProps["transcripts"].(string); ok && transcriptsJson != "" {
+		json.Unmarshal([]byte(transcriptsJson), &rssEntry.Transcripts)
+	}
+	if section, ok := nodeProps["section"].(string); ok {
+		rssEntry.Section = section
+	}
+	if keywords, ok := nodeProps["keywords"].([]any); ok {
+		for _, keyword := range keywords {
+			if keywordText, ok := keyword.(string); ok {
+				rssEntry.Keywords = append(rssEntry.Keywords, keywordText)
 			}
-
-			rssEntryArray = append(rssEntryArray, rssEntry)
 		}
 	}
-	return rssEntryArray[0], err
+	if publisher, ok := nodeProps["publisher"].(string); ok {
+		rssEntry.Publisher = publisher
+	}
+	if sourcesJson, ok := nodeProps["metadata_sources"].(string); ok && sourcesJson != "" {
+		json.Unmarshal([]byte(sourcesJson), &rssEntry.MetadataSources)
+	}
+	if StaticFileUrl, ok := nodeProps["static_file_url"].(string); ok {
+		rssEntry.StorageUrl = StaticFileUrl
+	}
+	if InStorage, ok := nodeProps["in_static_file_storage"].(int64); ok {
+		rssEntry.InStorage = int(InStorage)
+	}
+
+	return rssEntry
 }
 
-// Function that checks the Database for an Author:
+// Function that checks the Database for an Author. Authors are matched on their normalised name or one of
+// their aliases (see NormaliseAuthorName), and on their exact name if they were created before names were
+// normalised:
 func GetAuthorFromDatabase(name string, ctx context.Context, driver neo4j.DriverWithContext) (author RssAuthor, err error) {
 	// Querying the node from the graph database:
 	results, err := neo4j.ExecuteQuery(
 		ctx,
 		driver,
-		"MATCH (author:Rss_Feed:Author:Person {name: $name}) RETURN author",
-		map[string]any{"name": name},
+		`MATCH (author:Author:Person)
+		WHERE author.normalised_name = $normalised_name
+			OR $normalised_name IN coalesce(author.aliases, [])
+			OR author.name = $name
+		RETURN author
+		ORDER BY CASE WHEN author.normalised_name = $normalised_name THEN 0 ELSE 1 END
+		LIMIT 1`,
+		map[string]any{"name": name, "normalised_name": NormaliseAuthorName(name)},
 		neo4j.EagerResultTransformer,
 		neo4j.ExecuteQueryWithDatabase("neo4j"))
 
 	if err != nil {
-		log.Fatal(err)
 		return author, err
 	}
 
@@ -218,326 +366,107 @@ func GetAuthorFromDatabase(name string, ctx context.Context, driver neo4j.Driver
 		return author, nil
 	}
 
-	if len(results.Records) > 1 {
-		err = fmt.Errorf("more than one author returned. %d returned", len(results.Records))
-		log.Fatal(err)
+	authorNode, _, err := neo4j.GetRecordValue[neo4j.Node](results.Records[0], "author")
+	if err != nil {
 		return author, err
 	}
 
-	extractedNodeDict := results.Records[0].AsMap()
-	// A really bad way of doing this. Creating an array that will always be length 1 and appending it
-	// Find a better way of parsing an arbitrary key/value pair from a dict that will always be len 1.
-	var personArray []RssAuthor
-
-	for _, nodeKey := range extractedNodeDict {
-		switch node := nodeKey.(type) {
-		case neo4j.Node:
-
-			nodeProps := node.GetProperties()
-
-			author := RssAuthor{Id: node.ElementId}
-
-			if name, ok := nodeProps["name"].(string); ok {
-				author.Name = name
-			} else {
-				err = fmt.Errorf("no name found for extracted author node")
-				return author, err
-			}
-			if email, ok := nodeProps["email"].(string); ok {
-				author.Email = email
-			} else {
-				author.Email = ""
-			}
-
-			personArray = append(personArray, author)
-		}
-	}
-
-	return personArray[0], err
+	return RssAuthorFromNode(authorNode), nil
 
 }
 
 // This is the function that gets called with a RssFeed title and performs all of the ingestion activities in the database:
-// It wraps all of the previously existing logic in the rss parser:
+// The feed goes through the same pipeline as every other source type (see IngestSource and rssSource):
 func IngestAllRssItems(rssFeedTitle string, ctx context.Context, driver neo4j.DriverWithContext) (SummaryResponse RssFeedExtractionSummary, err error) {
+	return IngestSource("rss", rssFeedTitle, ctx, driver)
+}
+
+// The rss, atom and JSON feed source type. Feeds are fetched with the ETag from the previous request so
+// servers that support conditional requests can tell us nothing changed, and existing articles are
+// updated when the feed changes them:
+type rssSource struct{}
 
-	// Generic JSON response struct that summarizes the status of the rss ingestion:
-	SummaryResponse.Title = rssFeedTitle
+func (rssSource) Label() string { return "Rss_Feed" }
 
-	// 1) Query the database for the graph node of rss feed source based on title.
-	extractedRssFeed, err := GetRssSourceFromDatabase(rssFeedTitle, ctx, driver)
+func (rssSource) Fetch(source SourceNode, ctx context.Context) (fetch SourceFetch, err error) {
+
+	rssFeed := RssFeedFromNode(source.Node)
+
+	// Sources we have permission to archive skip the robots.txt check:
+	requestCtx := ctx
+	if rssFeed.IgnoreRobots {
+		requestCtx = WithIgnoreRobots(ctx)
+	}
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodGet, rssFeed.Url, nil)
 	if err != nil {
-		SummaryResponse.Error = err.Error()
-		SummaryResponse.Status = "Error in extracting an Rss Source from database"
-		return
+		return fetch, newSourceError(StatusErrorFetch, "Error in building the request to the Rss feed", err)
+	}
+	if rssFeed.Etag != "" {
+		req.Header.Set("If-None-Match", rssFeed.Etag)
 	}
-	SummaryResponse.Id = extractedRssFeed.Id
-	SummaryResponse.RssFeed = extractedRssFeed
 
-	// 2) Make a post request to the rss feed endpoint based on the field extracted by the node.
-	resp, err := http.Get(extractedRssFeed.Url)
+	resp, err := DefaultFetcher.Do(req)
 	if err != nil {
-		SummaryResponse.Error = err.Error()
-		SummaryResponse.Status = "Error in extracting an Rss Source from database"
-		return
+		if errors.Is(err, ErrDisallowedByRobots) {
+			return fetch, newSourceError(StatusErrorRobots, "The Rss feed's robots.txt does not allow it to be fetched. Set ignore_robots on the source if we have permission to archive it", err)
+		}
+		return fetch, newSourceError(StatusErrorFetch, "Error in making the request to the Rss feed", err)
 	}
 	defer resp.Body.Close()
+	fetch.HttpStatus = resp.StatusCode
+	fetch.Etag = resp.Header.Get("ETag")
 
-	if resp.StatusCode > 300 {
-		err = fmt.Errorf("request to rss feed %s returned status code: %d", extractedRssFeed.Url, resp.StatusCode)
-		SummaryResponse.Error = err.Error()
-		SummaryResponse.Status = "Error in extracting an Rss Source from database"
-		SummaryResponse.RssFeed = extractedRssFeed
-		return
+	if resp.StatusCode == http.StatusNotModified {
+		fetch.Etag = rssFeed.Etag
+		fetch.NotModified = true
+		return fetch, nil
 	}
 
-	fp := gofeed.NewParser()
-	feed, _ := fp.Parse(resp.Body)
+	if resp.StatusCode > 300 {
+		err = fmt.Errorf("request to rss feed %s returned status code: %d", rssFeed.Url, resp.StatusCode)
+		return fetch, newSourceError(StatusErrorFetch, "The Rss feed responded with an error status code", err)
+	}
 
-	if extractedRssFeed.LastUpdate == feed.Updated {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fetch, newSourceError(StatusErrorFetch, "Error in reading the response from the Rss feed", err)
+	}
 
-		noUpdatedRssFeedMsg := fmt.Sprintf(`
-		No new Rss Feed found for %s rss feed. DatabaseLastUpdated: %s, RequestLastUpdated %s`,
-			extractedRssFeed.Title,
-			extractedRssFeed.LastUpdate,
-			feed.Updated,
-		)
+	// Feeds that aren't UTF-8 (eg: Windows-1252, Shift-JIS or EUC-KR) are transcoded before they are parsed.
+	// The charset they were in is recorded on the source and its new articles:
+	body, rssFeed.Charset, err = DecodeToUtf8(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fetch, newSourceError(StatusErrorParse, "Unable to decode the response from the Rss feed into UTF-8", err)
+	}
 
-		SummaryResponse.Status = noUpdatedRssFeedMsg
-		return
+	// Feeds with common breakage are repaired and the repairs listed as warnings. Feeds that can't be
+	// repaired, including html error pages served in place of the feed, fail the ingestion:
+	feed, warnings, err := ParseFeed(body)
+	fetch.Warnings = warnings
+	if err != nil {
+		return fetch, newSourceError(StatusErrorParse, "Unable to parse the response from the Rss feed", err)
 	}
 
-	var EntrySummaryArray []RssEntryExtractionSummary
+	parsedFetch := rssSourceFetch(rssFeed, feed, fetch.Etag)
+	parsedFetch.HttpStatus = fetch.HttpStatus
+	parsedFetch.Warnings = fetch.Warnings
 
-	for _, item := range feed.Items {
+	return parsedFetch, nil
+}
 
-		var EntrySummary RssEntryExtractionSummary
+// Once the feed has been ingested, downloads the audio and video enclosures of the feed into object storage
+// if media downloads are enabled. Media that fails to download is retried on the next ingestion so it
+// doesn't fail this one:
+func (rssSource) Finish(source SourceNode, summary *RssFeedExtractionSummary, ctx context.Context, driver neo4j.DriverWithContext) {
 
-		existingEntry, err := GetRssArticleFromDatabase(item.Title, item.Link, ctx, driver)
-		EntrySummary.Title = item.Title
-		EntrySummary.Url = item.Link
-		if err != nil {
-			EntrySummary.Error = err.Error()
-			EntrySummary.Status = "Error in querying articles from the database"
-			EntrySummaryArray = append(EntrySummaryArray, EntrySummary)
-			continue
-		}
-		// If the article already exists then we are done we don't have to continue to process this entry.
-		if (RssEntry{}) != existingEntry {
-			EntrySummary.Id = existingEntry.Id
-			EntrySummary.Error = ""
-			EntrySummary.Status = "Article already exists in the database. Skipped all functions assocaited with this Entry"
-			EntrySummaryArray = append(EntrySummaryArray, EntrySummary)
-			fmt.Println(err)
-			continue
-		}
+	rssFeed := RssFeedFromNode(source.Node)
+	summary.RssFeed = rssFeed
 
-		// Inserting the Entry into the Graph database:
-		result, err := neo4j.ExecuteQuery(
-			ctx,
-			driver,
-			`
-			CREATE (article:Rss_Feed:Article {
-				name: $name,
-				url: $url,
-				description: $description,
-				date_posted: $date_posted,
-				static_file_url: $static_file_url,
-				in_static_file_storage: $in_static_file_storage,
-				created: timestamp()
-			})
-			WITH article
-
-			MATCH (source:Rss_Feed:Source {name: $rss_source_name})
-
-			CREATE (source)-[rel:CONTAINS_ARTICLE]->(article)
-			SET rel.date_downloaded = $downloaded_date
-			return article
-			`,
-			map[string]any{
-				"name":                   item.Title,
-				"url":                    item.Link,
-				"description":            item.Description,
-				"date_posted":            item.Published,
-				"static_file_url":        "",
-				"in_static_file_storage": 0,
-				"downloaded_date":        time.Now().Format("2006-01-02"),
-				"rss_source_name":        extractedRssFeed.Title,
-			},
-			neo4j.EagerResultTransformer,
-			neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if summary.Status == StatusCompleted {
+		var err error
+		summary.Media, err = DownloadRssFeedMedia(rssFeed, ctx, driver)
 		if err != nil {
-			EntrySummary.Error = err.Error()
-			EntrySummary.Status = "Unable to execute neo4j query to insert new article into the database. Skipped all functions assocaited with this Entry"
-			EntrySummaryArray = append(EntrySummaryArray, EntrySummary)
-			fmt.Println(err)
-			continue
-		}
-
-		insertedNodeDict := result.Records[0].AsMap()
-
-		for _, nodeKey := range insertedNodeDict {
-			switch node := nodeKey.(type) {
-			case neo4j.Node:
-				EntrySummary.Id = node.ElementId
-			}
-		}
-
-		fmt.Printf(
-			"Created %v nodes in %+v. \n",
-			result.Summary.Counters().NodesCreated(),
-			result.Summary.ResultAvailableAfter(),
-		)
-
-		EntrySummary.Status = "Successfully inserted the Article. Check Author for futher information about Author connections."
-
-		// Extracting the article's Author:
-		var AuthorSummaryArray []RssAuthorExtractionSummary
-
-		for _, author := range item.Authors {
-
-			var AuthorSummary RssAuthorExtractionSummary
-
-			extractedAuthor, err := GetAuthorFromDatabase(author.Name, ctx, driver)
-			if err != nil {
-
-				AuthorSummary.Name = author.Name
-				AuthorSummary.Error = err.Error()
-				AuthorSummary.Status = "Error in querying authors from the database"
-				AuthorSummaryArray = append(AuthorSummaryArray, AuthorSummary)
-				continue
-			}
-
-			// Ingestion logic if Author is not unique in db:
-			if (RssAuthor{}) != extractedAuthor {
-
-				AuthorSummary.Name = extractedAuthor.Name
-				AuthorSummary.Status = "Existing author detected - adding connection to an existing Author"
-				AuthorSummary.Id = extractedAuthor.Id
-
-				// Article already exists so we just create a connection between it and the Article:
-				connectionResult, err := neo4j.ExecuteQuery(
-					ctx,
-					driver,
-					`
-					MATCH (article:Rss_Feed:Article {name: $article_name, url: $article_url})
-					MATCH (author:Rss_Feed:Author:Person {name: $author_name})
-
-					CREATE (author)-[:WROTE]->(article)
-
-					RETURN article, author
-					`,
-					map[string]any{
-						"article_name": item.Title,
-						"article_url":  item.Link,
-						"author_name":  extractedAuthor.Name,
-					},
-					neo4j.EagerResultTransformer,
-					neo4j.ExecuteQueryWithDatabase("neo4j"))
-
-				if err != nil {
-					AuthorSummary.Error = err.Error()
-					AuthorSummary.Status = fmt.Sprintf("Error in connecting existing author to the article. Author: %s. Article: %s. Skipping addition author logic",
-						extractedAuthor.Name,
-						item.Title,
-					)
-
-					AuthorSummaryArray = append(AuthorSummaryArray, AuthorSummary)
-					fmt.Println(err)
-					continue
-				}
-				fmt.Printf(
-					"Created %v nodes in %+v. \n",
-					connectionResult.Summary.Counters().NodesCreated(),
-					connectionResult.Summary.ResultAvailableAfter(),
-				)
-			} else {
-				// Ingestion logic if article is unique:
-				AuthorSummary.Name = author.Name
-				AuthorSummary.Status = "New author detected - Creating a new author and connecting it to article"
-
-				// Inserting the author into the database and creating the connection:
-				authorCreationResult, err := neo4j.ExecuteQuery(
-					ctx,
-					driver,
-					`
-					MATCH (article:Rss_Feed:Article {name: $article_name, url: $article_url})
-					
-					MERGE (author:Rss_Feed:Author:Person {name: $author_name})
-					ON CREATE SET author.name = $author_name, author.email = $author_email
-
-					CREATE (author)-[:WROTE]->(article)
-
-					RETURN article, author
-				`,
-					map[string]any{
-						"article_name": item.Title,
-						"article_url":  item.Link,
-						"author_name":  author.Name,
-						"author_email": author.Email,
-					},
-					neo4j.EagerResultTransformer,
-					neo4j.ExecuteQueryWithDatabase("neo4j"))
-				if err != nil {
-					AuthorSummary.Error = err.Error()
-					AuthorSummary.Status = fmt.Sprintf(
-						`Error in creating and connecting author to the article. Author: %s. Article: %s. Skipping addition author logic`,
-						extractedAuthor.Name,
-						item.Title,
-					)
-					AuthorSummaryArray = append(AuthorSummaryArray, AuthorSummary)
-					fmt.Println(err)
-					continue
-				}
-
-				insertedNodeDict := authorCreationResult.Records[0].AsMap()
-
-				for _, nodeKey := range insertedNodeDict {
-					switch node := nodeKey.(type) {
-					case neo4j.Node:
-						AuthorSummary.Id = node.ElementId
-					}
-				}
-
-				fmt.Printf(
-					"Created %v nodes in %+v. \n",
-					authorCreationResult.Summary.Counters().NodesCreated(),
-					authorCreationResult.Summary.ResultAvailableAfter(),
-				)
-			}
-
-			AuthorSummaryArray = append(AuthorSummaryArray, AuthorSummary)
-
+			fmt.Println("Unable to download the media of the", rssFeed.Title, "rss feed:", err)
 		}
-
-		EntrySummary.Authors = AuthorSummaryArray
-		EntrySummaryArray = append(EntrySummaryArray, EntrySummary)
-	}
-
-	// Update the Rss Entry Item in the database to set the Last Updated or E-Tag date:
-	SummaryResponse.Status = "Article and Author Ingestion complete for the feed"
-	SummaryResponse.RssEntries = EntrySummaryArray
-
-	// Updating the Rss Feed item in the database with a new last update value:
-	_, err = neo4j.ExecuteQuery(
-		ctx,
-		driver,
-		`
-		MERGE (rss_feed:Rss_Feed:Source {name: $name})
-		SET rss_feed.last_updated = $last_updated
-		RETURN rss_feed`,
-		map[string]any{
-			"name":         extractedRssFeed.Title,
-			"last_updated": feed.Updated,
-		},
-		neo4j.EagerResultTransformer,
-		neo4j.ExecuteQueryWithDatabase("neo4j"))
-	if err != nil {
-		SummaryResponse.Error = err.Error()
-		SummaryResponse.Status = "Unable to update the Rss Feeds' last_updated value from the extracted rss feed"
-		return
 	}
-
-	return
-
 }
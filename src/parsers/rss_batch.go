@@ -0,0 +1,261 @@
+package parsers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Maximum number of rows sent to neo4j in a single UNWIND query. Feeds with more items than this are
+// written with several queries inside the same transaction:
+const rssWriteBatchSize = 100
+
+// Creates the new articles of a feed and connects them to their source, tags and media. Categories are
+// stored as Tag nodes and enclosures and media:* elements as Media nodes that are shared between all of
+// the articles that reference them. New articles are also connected to the Ingest_Run or Backfill_Job that
+// created them. Each row of $articles is built by rssArticleCreateParams. The source and its articles carry
+// the label of their source type (eg: Rss_Feed or Newsletter):
+func createArticlesQuery(sourceLabel string) string {
+	return fmt.Sprintf(`
+MATCH (source:%[1]s:Source) WHERE elementId(source) = $source_id
+OPTIONAL MATCH (run) WHERE elementId(run) = $run_id AND (run:Ingest_Run OR run:Backfill_Job)
+UNWIND $articles AS item
+CREATE (article:%[1]s:Article {
+	name: item.name,
+	guid: item.guid,
+	url: item.url,
+	canonical_url: item.canonical_url,
+	description: item.description,
+	content: item.content,
+	creator: item.creator,
+	image_url: item.image_url,
+	date_posted: item.date_posted,
+	date_updated: item.date_updated,
+	extensions: item.extensions,
+	charset: item.charset,
+	links: item.links,
+	external_url: item.external_url,
+	rights: item.rights,
+	contributors: item.contributors,
+	source_feed_title: item.source_feed_title,
+	source_feed_url: item.source_feed_url,
+	duration: item.duration,
+	episode: item.episode,
+	season: item.season,
+	episode_type: item.episode_type,
+	explicit: item.explicit,
+	transcripts: item.transcripts,
+	section: item.section,
+	keywords: item.keywords,
+	publisher: item.publisher,
+	metadata_sources: item.metadata_sources,
+	static_file_url: "",
+	in_static_file_storage: 0,
+	created: datetime({timezone: 'UTC'})
+})
+FOREACH (category IN item.categories |
+	MERGE (tag:Tag {name: category})
+	MERGE (article)-[:TAGGED]->(tag)
+)
+FOREACH (media_item IN item.media |
+	MERGE (media:Media {url: media_item.url})
+	ON CREATE SET
+		media.type = media_item.type,
+		media.medium = media_item.medium,
+		media.length = media_item.length,
+		media.width = media_item.width,
+		media.height = media_item.height,
+		media.source = media_item.source,
+		media.title = media_item.title,
+		media.duration = media_item.duration
+	MERGE (article)-[:HAS_MEDIA]->(media)
+)
+CREATE (source)-[:CONTAINS_ARTICLE {date_downloaded: $downloaded_date}]->(article)
+FOREACH (r IN CASE WHEN run IS NULL THEN [] ELSE [run] END |
+	CREATE (r)-[:CREATED_ARTICLE]->(article)
+)
+CALL {
+	WITH article
+	MATCH (stub:Web_Page {canonical_url: article.canonical_url})
+	OPTIONAL MATCH (citing:Article)-[link:LINKS_TO]->(stub)
+	FOREACH (c IN CASE WHEN citing IS NULL THEN [] ELSE [citing] END |
+		CREATE (c)-[adopted:LINKS_TO]->(article)
+		SET adopted = properties(link)
+	)
+	WITH DISTINCT stub
+	DETACH DELETE stub
+}
+RETURN item.index AS index, elementId(article) AS id
+`, sourceLabel)
+}
+
+var createRssArticlesQuery = createArticlesQuery("Rss_Feed")
+
+// Builds the row passed to createRssArticlesQuery for a new article:
+func rssArticleCreateParams(index int, rssEntry RssEntry) map[string]any {
+	return map[string]any{
+		"index":         index,
+		"name":          rssEntry.Title,
+		"guid":          rssEntry.Guid,
+		"url":           rssEntry.Url,
+		"canonical_url": rssEntry.CanonicalUrl,
+		"description":   rssEntry.Description,
+		"content":       rssEntry.Content,
+		"creator":       rssEntry.Creator,
+		"image_url":     rssEntry.ImageUrl,
+		"date_posted":   neo4jDateTime(rssEntry.DatePosted),
+		"date_updated":  neo4jDateTime(rssEntry.DateUpdated),
+		"extensions":    rssEntry.ExtensionsJson,
+		"categories":    rssEntry.Categories,
+		"media":         rssMediaQueryParams(rssEntry.Media),
+		"charset":       rssEntry.Charset,
+		// Atom links and transcripts are stored as json as neo4j properties can't hold a list of maps:
+		"links":             rssLinksJson(rssEntry.Links),
+		"external_url":      rssEntry.ExternalUrl,
+		"rights":            rssEntry.Rights,
+		"contributors":      nonNilStrings(rssEntry.Contributors),
+		"source_feed_title": rssEntry.SourceFeedTitle,
+		"source_feed_url":   rssEntry.SourceFeedUrl,
+		"duration":          rssEntry.Duration,
+		"episode":           rssEntry.Episode,
+		"season":            rssEntry.Season,
+		"episode_type":      rssEntry.EpisodeType,
+		"explicit":          rssEntry.Explicit,
+		"transcripts":       rssTranscriptsJson(rssEntry.Transcripts),
+		"section":           rssEntry.Section,
+		"keywords":          nonNilStrings(rssEntry.Keywords),
+		"publisher":         rssEntry.Publisher,
+		"metadata_sources":  metadataSourcesJson(rssEntry.MetadataSources),
+	}
+}
+
+func rssTranscriptsJson(transcripts []RssTranscript) string {
+	if len(transcripts) == 0 {
+		return ""
+	}
+	transcriptsJson, err := json.Marshal(transcripts)
+	if err != nil {
+		return ""
+	}
+	return string(transcriptsJson)
+}
+
+func metadataSourcesJson(metadataSources map[string]string) string {
+	if len(metadataSources) == 0 {
+		return ""
+	}
+	sourcesJson, err := json.Marshal(metadataSources)
+	if err != nil {
+		return ""
+	}
+	return string(sourcesJson)
+}
+
+func rssLinksJson(rssLinks []RssLink) string {
+	if len(rssLinks) == 0 {
+		return ""
+	}
+	linksJson, err := json.Marshal(rssLinks)
+	if err != nil {
+		return ""
+	}
+	return string(linksJson)
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// Writes every item of a feed to the database in a single managed write transaction (see WriteSourceItems).
+// New articles are created along with their authors, changed articles are updated with a Revision, and the
+// source's last_updated and ETag values are only stored if all of that succeeds. Transient errors (eg:
+// deadlocks or a leader switch) are retried by the driver:
+func WriteRssFeedItems(rssFeed RssFeed, runId string, feed *ParsedFeed, etag string, ctx context.Context, driver neo4j.DriverWithContext) (summaries []RssEntryExtractionSummary, err error) {
+	source := SourceNode{Id: rssFeed.Id, Name: rssFeed.Title, Type: "rss"}
+	return WriteSourceItems(source, rssSource{}.Label(), runId, rssSourceFetch(rssFeed, feed, etag), ctx, driver)
+}
+
+// Normalises the items of a parsed feed into the items of a source. Bylines that list several people are
+// split into individual authors. The feed's last update, ETag and charset are stored on the source along
+// with the format of the feed (rss, atom or json) in case the site has switched formats:
+func rssSourceFetch(rssFeed RssFeed, feed *ParsedFeed, etag string) (fetch SourceFetch) {
+
+	fetch.Etag = etag
+	fetch.UpdateExisting = true
+	fetch.Items = make([]SourceItem, len(feed.Items))
+	for i, item := range feed.Items {
+
+		fetch.Items[i].Entry = RssEntryFromParsedFeed(feed, i)
+		fetch.Items[i].Entry.Charset = rssFeed.Charset
+
+		for _, author := range item.Authors {
+			if author == nil {
+				continue
+			}
+			for _, authorName := range SplitAuthorNames(author.Name) {
+				fetch.Items[i].Authors = append(fetch.Items[i].Authors, RssAuthor{Name: authorName, Email: author.Email})
+			}
+		}
+	}
+
+	fetch.SourceProperties = map[string]any{
+		"last_updated": neo4jDateTime(FeedItemTime(feed.UpdatedParsed, feed.Updated)),
+		"etag":         etag,
+		"charset":      rssFeed.Charset,
+		"feed_type":    feed.FeedType,
+		"feed_version": feed.FeedVersion,
+		"rights":       strings.TrimSpace(feed.Copyright),
+	}
+
+	return fetch
+}
+
+// Runs an UNWIND query inside a transaction with its rows split into batches of rssWriteBatchSize. The rows
+// are passed as the rowsParam parameter alongside any other params, and every returned record is passed
+// to handleRecord:
+func runBatchedQuery(query string, rowsParam string, rows []map[string]any, params map[string]any, ctx context.Context, tx neo4j.ManagedTransaction, handleRecord func(record *neo4j.Record) error) (err error) {
+
+	for start := 0; start < len(rows); start += rssWriteBatchSize {
+		end := start + rssWriteBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		queryParams := map[string]any{rowsParam: rows[start:end]}
+		for key, value := range params {
+			queryParams[key] = value
+		}
+
+		result, err := tx.Run(ctx, query, queryParams)
+		if err != nil {
+			return err
+		}
+		records, err := result.Collect(ctx)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err = handleRecord(record); err != nil {
+				return err
+			}
+		}
+
+		summary, err := result.Consume(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(
+			"Created %v nodes in %+v. \n",
+			summary.Counters().NodesCreated(),
+			summary.ResultAvailableAfter(),
+		)
+	}
+
+	return nil
+}
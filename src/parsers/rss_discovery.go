@@ -0,0 +1,212 @@
+package parsers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// The <link rel="alternate"> types that websites use to advertise their feeds:
+var alternateFeedTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+}
+
+// Fetches the provided url and confirms that it points to a feed that gofeed can parse. If the url is a
+// regular web page instead of a feed, the page is searched for <link rel="alternate"> feed links and the
+// first advertised feed that parses is used. The returned RssFeed is populated with the feed url and the
+// metadata that the feed declares about itself (title, description, site link, language and image):
+func DiscoverRssFeed(rawUrl string) (rssFeed RssFeed, err error) {
+
+	body, contentType, finalUrl, err := fetchFeedDocument(rawUrl)
+	if err != nil {
+		return rssFeed, err
+	}
+
+	// If the url is already a feed we are done:
+	feed, _, parseErr := ParseFeed(body)
+	if parseErr == nil {
+		return rssFeedFromParsedFeed(finalUrl, feed), nil
+	}
+
+	if !isHtmlDocument(contentType, body) {
+		err = fmt.Errorf("url %s is not a valid rss, atom or json feed: %s", rawUrl, parseErr.Error())
+		return rssFeed, err
+	}
+
+	// The url is a web page so we look for the feeds that it advertises:
+	candidateUrls, err := findAlternateFeedLinks(finalUrl, body)
+	if err != nil {
+		return rssFeed, err
+	}
+	if len(candidateUrls) == 0 {
+		err = fmt.Errorf("url %s is a web page, not a feed, and the page does not advertise any rss, atom or json feeds", rawUrl)
+		return rssFeed, err
+	}
+
+	var candidateErrors []string
+	for _, candidateUrl := range candidateUrls {
+
+		candidateBody, _, candidateFinalUrl, err := fetchFeedDocument(candidateUrl)
+		if err != nil {
+			candidateErrors = append(candidateErrors, err.Error())
+			continue
+		}
+
+		feed, _, err := ParseFeed(candidateBody)
+		if err != nil {
+			candidateErrors = append(candidateErrors, fmt.Sprintf("%s: %s", candidateUrl, err.Error()))
+			continue
+		}
+
+		return rssFeedFromParsedFeed(candidateFinalUrl, feed), nil
+	}
+
+	err = fmt.Errorf(
+		"url %s is a web page, not a feed, and none of the %d feeds it advertises could be parsed: %s",
+		rawUrl,
+		len(candidateUrls),
+		strings.Join(candidateErrors, "; "),
+	)
+	return rssFeed, err
+}
+
+// Fills in any empty fields of a user provided RssFeed with the values discovered from the feed itself.
+// The discovered feed url always replaces the provided url so that website urls are swapped for their feed:
+func MergeDiscoveredRssFeed(provided RssFeed, discovered RssFeed) RssFeed {
+
+	merged := provided
+	merged.Url = discovered.Url
+
+	if merged.Title == "" {
+		merged.Title = discovered.Title
+	}
+	if merged.Description == "" {
+		merged.Description = discovered.Description
+	}
+	if merged.SiteLink == "" {
+		merged.SiteLink = discovered.SiteLink
+	}
+	if merged.Language == "" {
+		merged.Language = discovered.Language
+	}
+	if merged.ImageUrl == "" {
+		merged.ImageUrl = discovered.ImageUrl
+	}
+	merged.FeedType = discovered.FeedType
+	merged.FeedVersion = discovered.FeedVersion
+	if merged.Rights == "" {
+		merged.Rights = discovered.Rights
+	}
+
+	return merged
+}
+
+// Makes the GET request for a feed or web page and returns the body, content type and the url that the
+// request ended up at after redirects:
+func fetchFeedDocument(rawUrl string) (body []byte, contentType string, finalUrl string, err error) {
+
+	resp, err := DefaultFetcher.Get(context.Background(), rawUrl)
+	if err != nil {
+		return body, contentType, finalUrl, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 300 {
+		err = fmt.Errorf("request to %s returned status code: %d", rawUrl, resp.StatusCode)
+		return body, contentType, finalUrl, err
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return body, contentType, finalUrl, err
+	}
+
+	// Feeds and pages that aren't UTF-8 are transcoded so their titles aren't mangled:
+	contentType = resp.Header.Get("Content-Type")
+	body, _, err = DecodeToUtf8(body, contentType)
+	if err != nil {
+		return body, contentType, finalUrl, err
+	}
+
+	return body, contentType, resp.Request.URL.String(), nil
+}
+
+func isHtmlDocument(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return true
+	}
+	return strings.Contains(http.DetectContentType(body), "text/html")
+}
+
+// Extracts the absolute urls of all feeds advertised in the <head> of an html page:
+func findAlternateFeedLinks(pageUrl string, body []byte) ([]string, error) {
+
+	baseUrl, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var feedUrls []string
+	doc.Find("link[rel~='alternate'][href]").Each(func(i int, link *goquery.Selection) {
+
+		linkType, _ := link.Attr("type")
+		linkType = strings.ToLower(strings.TrimSpace(linkType))
+
+		isFeedType := false
+		for _, feedType := range alternateFeedTypes {
+			if linkType == feedType {
+				isFeedType = true
+			}
+		}
+		if !isFeedType {
+			return
+		}
+
+		href, _ := link.Attr("href")
+		feedUrl, err := baseUrl.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return
+		}
+
+		for _, existingUrl := range feedUrls {
+			if existingUrl == feedUrl.String() {
+				return
+			}
+		}
+		feedUrls = append(feedUrls, feedUrl.String())
+	})
+
+	return feedUrls, nil
+}
+
+func rssFeedFromParsedFeed(feedUrl string, feed *ParsedFeed) (rssFeed RssFeed) {
+
+	rssFeed.Url = feedUrl
+	rssFeed.Title = strings.TrimSpace(feed.Title)
+	rssFeed.Description = strings.TrimSpace(feed.Description)
+	rssFeed.SiteLink = feed.Link
+	rssFeed.Language = feed.Language
+	rssFeed.FeedType = feed.FeedType
+	rssFeed.FeedVersion = feed.FeedVersion
+	rssFeed.Rights = strings.TrimSpace(feed.Copyright)
+
+	if feed.Image != nil {
+		rssFeed.ImageUrl = feed.Image.URL
+	}
+
+	return rssFeed
+}
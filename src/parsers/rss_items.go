@@ -0,0 +1,367 @@
+package parsers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Maps every field of a parsed feed item that we store onto an RssEntry. Categories are de-duplicated
+// ignoring case, enclosures and media:content/media:thumbnail elements are collected as media, the
+// dc:creator is kept alongside the item authors and all namespaced extensions are kept as json:
+func RssEntryFromFeedItem(item *gofeed.Item) (rssEntry RssEntry) {
+
+	rssEntry.Guid = item.GUID
+	rssEntry.Url = item.Link
+	rssEntry.CanonicalUrl = CanonicalizeUrl(item.Link)
+	rssEntry.Title = item.Title
+	rssEntry.Description = item.Description
+	rssEntry.Content = item.Content
+	rssEntry.DatePosted = FeedItemTime(item.PublishedParsed, item.Published)
+	rssEntry.DateUpdated = FeedItemTime(item.UpdatedParsed, item.Updated)
+
+	if item.DublinCoreExt != nil && len(item.DublinCoreExt.Creator) > 0 {
+		rssEntry.Creator = strings.Join(item.DublinCoreExt.Creator, ", ")
+	}
+
+	rssEntry.Categories = []string{}
+	seenCategories := map[string]bool{}
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seenCategories[strings.ToLower(category)] {
+			continue
+		}
+		seenCategories[strings.ToLower(category)] = true
+		rssEntry.Categories = append(rssEntry.Categories, category)
+	}
+
+	rssEntry.Media = []RssMedia{}
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		rssEntry.Media = append(rssEntry.Media, RssMedia{
+			Url:    enclosure.URL,
+			Type:   enclosure.Type,
+			Length: length,
+			Source: "enclosure",
+		})
+	}
+	rssEntry.Media = append(rssEntry.Media, rssMediaFromExtensions(item.Extensions)...)
+
+	setRssEntryPodcastFields(&rssEntry, item)
+
+	if item.Image != nil && item.Image.URL != "" {
+		rssEntry.ImageUrl = item.Image.URL
+	} else {
+		for _, media := range rssEntry.Media {
+			if media.Source == "media:thumbnail" {
+				rssEntry.ImageUrl = media.Url
+				break
+			}
+		}
+	}
+
+	if len(item.Extensions) > 0 {
+		extensionsJson, err := json.Marshal(item.Extensions)
+		if err == nil {
+			rssEntry.ExtensionsJson = string(extensionsJson)
+		}
+	}
+
+	return rssEntry
+}
+
+// Maps an item of a parsed feed onto an RssEntry (see RssEntryFromFeedItem) along with the fields that only
+// its format has. gofeed translates Atom entries and JSON Feed items in order so the item at index is the
+// entry or item at the same index of the format specific feed:
+func RssEntryFromParsedFeed(feed *ParsedFeed, index int) (rssEntry RssEntry) {
+
+	rssEntry = RssEntryFromFeedItem(feed.Items[index])
+
+	if feed.Atom != nil && index < len(feed.Atom.Entries) {
+		entry := feed.Atom.Entries[index]
+
+		rssEntry.Links = rssLinksFromAtom(entry.Links)
+		rssEntry.Rights = strings.TrimSpace(entry.Rights)
+		rssEntry.Contributors = []string{}
+		for _, contributor := range entry.Contributors {
+			if contributor == nil {
+				continue
+			}
+			if name := strings.TrimSpace(contributor.Name); name != "" {
+				rssEntry.Contributors = append(rssEntry.Contributors, name)
+			} else if email := strings.TrimSpace(contributor.Email); email != "" {
+				rssEntry.Contributors = append(rssEntry.Contributors, email)
+			}
+		}
+
+		if entry.Source != nil {
+			rssEntry.SourceFeedTitle = strings.TrimSpace(entry.Source.Title)
+			rssEntry.SourceFeedUrl = entry.Source.ID
+			for _, link := range entry.Source.Links {
+				if link != nil && (link.Rel == "" || link.Rel == "alternate") && link.Href != "" {
+					rssEntry.SourceFeedUrl = link.Href
+					break
+				}
+			}
+		}
+	}
+
+	if feed.Json != nil && index < len(feed.Json.Items) {
+		item := feed.Json.Items[index]
+
+		rssEntry.ExternalUrl = item.ExternalURL
+
+		// gofeed stores an attachment's duration as the length of its enclosure so the attachments are
+		// mapped from the JSON Feed item instead:
+		if item.Attachments != nil {
+			media := []RssMedia{}
+			for _, existingMedia := range rssEntry.Media {
+				if existingMedia.Source != "enclosure" {
+					media = append(media, existingMedia)
+				}
+			}
+			for _, attachment := range *item.Attachments {
+				if attachment.URL == "" {
+					continue
+				}
+				media = append(media, RssMedia{
+					Url:      attachment.URL,
+					Type:     attachment.MimeType,
+					Length:   attachment.SizeInBytes,
+					Source:   "attachment",
+					Title:    attachment.Title,
+					Duration: attachment.DurationInSeconds,
+				})
+			}
+			rssEntry.Media = media
+
+			for _, attachment := range *item.Attachments {
+				if rssEntry.Duration == 0 && attachment.DurationInSeconds > 0 {
+					rssEntry.Duration = attachment.DurationInSeconds
+				}
+			}
+		}
+	}
+
+	return rssEntry
+}
+
+func rssLinksFromAtom(atomLinks []*atom.Link) []RssLink {
+	rssLinks := []RssLink{}
+	for _, link := range atomLinks {
+		if link == nil || link.Href == "" {
+			continue
+		}
+		rel := link.Rel
+		if rel == "" {
+			rel = "alternate"
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(link.Length), 10, 64)
+		rssLinks = append(rssLinks, RssLink{
+			Href:     link.Href,
+			Rel:      rel,
+			Type:     link.Type,
+			Hreflang: link.Hreflang,
+			Title:    link.Title,
+			Length:   length,
+		})
+	}
+	return rssLinks
+}
+
+// Collects media:content and media:thumbnail elements from the Media RSS namespace, including the ones
+// nested inside media:group elements:
+func rssMediaFromExtensions(extensions ext.Extensions) (rssMedia []RssMedia) {
+
+	mediaExtensions, ok := extensions["media"]
+	if !ok {
+		return rssMedia
+	}
+
+	var collectMedia func(elements map[string][]ext.Extension)
+	collectMedia = func(elements map[string][]ext.Extension) {
+		for _, elementName := range []string{"content", "thumbnail"} {
+			for _, element := range elements[elementName] {
+				if element.Attrs["url"] == "" {
+					continue
+				}
+				length, _ := strconv.ParseInt(element.Attrs["fileSize"], 10, 64)
+				width, _ := strconv.ParseInt(element.Attrs["width"], 10, 64)
+				height, _ := strconv.ParseInt(element.Attrs["height"], 10, 64)
+				rssMedia = append(rssMedia, RssMedia{
+					Url:    element.Attrs["url"],
+					Type:   element.Attrs["type"],
+					Medium: element.Attrs["medium"],
+					Length: length,
+					Width:  width,
+					Height: height,
+					Source: "media:" + elementName,
+				})
+			}
+		}
+		for _, group := range elements["group"] {
+			collectMedia(group.Children)
+		}
+	}
+	collectMedia(mediaExtensions)
+
+	return rssMedia
+}
+
+// Converts the media of an entry into the list of maps passed as a query parameter to neo4j:
+func rssMediaQueryParams(rssMedia []RssMedia) []map[string]any {
+	mediaParams := []map[string]any{}
+	for _, media := range rssMedia {
+		mediaParams = append(mediaParams, map[string]any{
+			"url":      media.Url,
+			"type":     media.Type,
+			"medium":   media.Medium,
+			"length":   media.Length,
+			"width":    media.Width,
+			"height":   media.Height,
+			"source":   media.Source,
+			"title":    media.Title,
+			"duration": media.Duration,
+		})
+	}
+	return mediaParams
+}
+
+// Returned when an article is looked up by an id that isn't in the database:
+var ErrArticleNotFound = errors.New("unable to find the article in the database")
+
+// Querying the database for an article by its element id along with its categories and media:
+func GetRssArticleById(id string, ctx context.Context, driver neo4j.DriverWithContext) (rssEntry RssEntry, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (article:Article)
+		WHERE elementId(article) = $id
+		OPTIONAL MATCH (article)-[:TAGGED]->(tag:Tag)
+		WITH article, collect(tag.name) AS categories
+		OPTIONAL MATCH (article)-[:HAS_MEDIA]->(media:Media)
+		RETURN article, categories, collect(media) AS media
+		`,
+		map[string]any{"id": id},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return rssEntry, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("%w: %s", ErrArticleNotFound, id)
+		return rssEntry, err
+	}
+
+	articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "article")
+	if err != nil {
+		return rssEntry, err
+	}
+	rssEntry = RssEntryFromNode(articleNode)
+
+	categories, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "categories")
+	if err != nil {
+		return rssEntry, err
+	}
+	for _, category := range categories {
+		if categoryName, ok := category.(string); ok {
+			rssEntry.Categories = append(rssEntry.Categories, categoryName)
+		}
+	}
+
+	mediaNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "media")
+	if err != nil {
+		return rssEntry, err
+	}
+	for _, mediaNode := range mediaNodes {
+		if node, ok := mediaNode.(neo4j.Node); ok {
+			rssEntry.Media = append(rssEntry.Media, RssMediaFromNode(node))
+		}
+	}
+
+	return rssEntry, nil
+}
+
+// Converts a Media node into an RssMedia struct:
+func RssMediaFromNode(node neo4j.Node) (rssMedia RssMedia) {
+
+	nodeProps := node.GetProperties()
+
+	rssMedia.Id = node.ElementId
+
+	if url, ok := nodeProps["url"].(string); ok {
+		rssMedia.Url = url
+	}
+	if mediaType, ok := nodeProps["type"].(string); ok {
+		rssMedia.Type = mediaType
+	}
+	if medium, ok := nodeProps["medium"].(string); ok {
+		rssMedia.Medium = medium
+	}
+	if length, ok := nodeProps["length"].(int64); ok {
+		rssMedia.Length = length
+	}
+	if width, ok := nodeProps["width"].(int64); ok {
+		rssMedia.Width = width
+	}
+	if height, ok := nodeProps["height"].(int64); ok {
+		rssMedia.Height = height
+	}
+	if source, ok := nodeProps["source"].(string); ok {
+		rssMedia.Source = source
+	}
+	if title, ok := nodeProps["title"].(string); ok {
+		rssMedia.Title = title
+	}
+	if duration, ok := nodeProps["duration"].(int64); ok {
+		rssMedia.Duration = duration
+	}
+
+	return rssMedia
+}
+
+// Querying the database for all of the articles posted in the half open range [from, to), newest first:
+func GetRssArticlesByDateRange(from time.Time, to time.Time, ctx context.Context, driver neo4j.DriverWithContext) (rssEntries []RssEntry, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`
+		MATCH (article:Article)
+		WHERE article.date_posted >= $from AND article.date_posted < $to
+		RETURN article
+		ORDER BY article.date_posted DESC
+		`,
+		map[string]any{"from": from.UTC(), "to": to.UTC()},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return rssEntries, err
+	}
+
+	rssEntries = []RssEntry{}
+	for _, record := range result.Records {
+		articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "article")
+		if err != nil {
+			return rssEntries, err
+		}
+		rssEntries = append(rssEntries, RssEntryFromNode(articleNode))
+	}
+
+	return rssEntries, nil
+}
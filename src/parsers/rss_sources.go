@@ -0,0 +1,227 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Feeds in the atom and json formats are also labelled with their format (eg: Rss_Feed:Atom_Feed:Source)
+// alongside their feed_type property, feeds in the rss format are plain Rss_Feed nodes:
+var feedTypeLabels = map[string]string{
+	"atom": "Atom_Feed",
+	"json": "Json_Feed",
+}
+
+// Returns the label of a feed's format, or "" for formats that don't have one:
+func FeedTypeLabel(feedType string) string {
+	return feedTypeLabels[feedType]
+}
+
+// Cypher that labels a node with the format in its feed_type property and removes the labels of the other
+// formats, in case the site has switched formats. Nodes without a feed_type are left unlabelled:
+func feedTypeLabelsClause(node string) string {
+
+	feedTypes := make([]string, 0, len(feedTypeLabels))
+	for feedType := range feedTypeLabels {
+		feedTypes = append(feedTypes, feedType)
+	}
+	sort.Strings(feedTypes)
+
+	var clause strings.Builder
+	for _, feedType := range feedTypes {
+		fmt.Fprintf(&clause, "REMOVE %s:%s\n", node, feedTypeLabels[feedType])
+	}
+	for _, feedType := range feedTypes {
+		fmt.Fprintf(&clause, "FOREACH (_ IN CASE WHEN %s.feed_type = '%s' THEN [1] ELSE [] END | SET %s:%s)\n", node, feedType, node, feedTypeLabels[feedType])
+	}
+
+	return clause.String()
+}
+
+// Creates an Rss_Feed:Source node for the feed if one with the same name does not already exist, labelled
+// with the feed's format (see feedTypeLabels), and connects it to a Tag node for each of the feed's tags.
+// Returns the stored node and whether it was newly created:
+func CreateRssSource(rssFeed RssFeed, ctx context.Context, driver neo4j.DriverWithContext) (insertedRssFeed RssFeed, created bool, err error) {
+
+	tags := rssFeed.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	// The current datetime is fixed for the duration of a query so newly_created is only true for nodes the MERGE created:
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MERGE (rss_feed:Rss_Feed:Source {name: $name})
+		ON CREATE SET
+			rss_feed.url = $url,
+			rss_feed.description = $description,
+			rss_feed.site_link = $site_link,
+			rss_feed.language = $language,
+			rss_feed.image_url = $image_url,
+			rss_feed.scheduled_time = $scheduled_time,
+			rss_feed.etag = $etag,
+			rss_feed.last_updated = $last_updated,
+			rss_feed.ignore_robots = $ignore_robots,
+			rss_feed.feed_type = $feed_type,
+			rss_feed.feed_version = $feed_version,
+			rss_feed.rights = $rights,
+			rss_feed.created = datetime({timezone: 'UTC'})
+		`+feedTypeLabelsClause("rss_feed")+`
+		FOREACH (tag_name IN $tags |
+			MERGE (tag:Tag {name: tag_name})
+			MERGE (rss_feed)-[:TAGGED]->(tag)
+		)
+		WITH rss_feed
+		OPTIONAL MATCH (rss_feed)-[:TAGGED]->(tag:Tag)
+		RETURN rss_feed, collect(tag.name) AS tags, rss_feed.created = datetime({timezone: 'UTC'}) AS newly_created`,
+		map[string]any{
+			"name":           rssFeed.Title,
+			"url":            rssFeed.Url,
+			"description":    rssFeed.Description,
+			"site_link":      rssFeed.SiteLink,
+			"language":       rssFeed.Language,
+			"image_url":      rssFeed.ImageUrl,
+			"scheduled_time": rssFeed.ExecuteTime,
+			"etag":           rssFeed.Etag,
+			"last_updated":   neo4jDateTime(rssFeed.LastUpdate),
+			"ignore_robots":  rssFeed.IgnoreRobots,
+			"feed_type":      rssFeed.FeedType,
+			"feed_version":   rssFeed.FeedVersion,
+			"rights":         rssFeed.Rights,
+			"tags":           tags,
+		},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return insertedRssFeed, created, err
+	}
+
+	fmt.Printf(
+		"Created %v nodes in %+v. \n",
+		result.Summary.Counters().NodesCreated(),
+		result.Summary.ResultAvailableAfter(),
+	)
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("no rss source node returned when creating rss feed %s", rssFeed.Title)
+		return insertedRssFeed, created, err
+	}
+
+	insertedRssFeed, err = rssFeedFromTaggedRecord(result.Records[0])
+	if err != nil {
+		return insertedRssFeed, created, err
+	}
+
+	created, _, err = neo4j.GetRecordValue[bool](result.Records[0], "newly_created")
+
+	return insertedRssFeed, created, err
+}
+
+// Checks whether an Rss_Feed:Source node already exists with either the provided name or url:
+func RssSourceExists(name string, url string, ctx context.Context, driver neo4j.DriverWithContext) (exists bool, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (source:Rss_Feed:Source)
+		WHERE source.name = $name OR source.url = $url
+		RETURN count(source) AS existing`,
+		map[string]any{"name": name, "url": url},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return exists, err
+	}
+
+	existing, _, err := neo4j.GetRecordValue[int64](result.Records[0], "existing")
+	if err != nil {
+		return exists, err
+	}
+
+	return existing > 0, nil
+}
+
+// Returns every Rss_Feed:Source node in the database along with the names of its tags. Only rss feeds are
+// returned, sources of every type are read with GetAllSources:
+func GetAllRssSources(ctx context.Context, driver neo4j.DriverWithContext) (rssFeeds []RssFeed, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (rss_feed:Rss_Feed:Source)
+		OPTIONAL MATCH (rss_feed)-[:TAGGED]->(tag:Tag)
+		RETURN rss_feed, collect(tag.name) AS tags
+		ORDER BY rss_feed.name`,
+		nil,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return rssFeeds, err
+	}
+
+	rssFeeds = []RssFeed{}
+	for _, record := range result.Records {
+		rssFeed, err := rssFeedFromTaggedRecord(record)
+		if err != nil {
+			return rssFeeds, err
+		}
+		rssFeeds = append(rssFeeds, rssFeed)
+	}
+
+	return rssFeeds, nil
+}
+
+// Reads a record of the form `RETURN rss_feed, collect(tag.name) AS tags` into an RssFeed:
+func rssFeedFromTaggedRecord(record *neo4j.Record) (rssFeed RssFeed, err error) {
+
+	node, _, err := neo4j.GetRecordValue[neo4j.Node](record, "rss_feed")
+	if err != nil {
+		return rssFeed, err
+	}
+	rssFeed = RssFeedFromNode(node)
+
+	tags, _, err := neo4j.GetRecordValue[[]any](record, "tags")
+	if err != nil {
+		return rssFeed, err
+	}
+	rssFeed.Tags = []string{}
+	for _, tag := range tags {
+		if tagName, ok := tag.(string); ok {
+			rssFeed.Tags = append(rssFeed.Tags, tagName)
+		}
+	}
+
+	return rssFeed, nil
+}
+
+// Sets whether a source skips the robots.txt check when it is fetched. Only for sources that we have
+// permission to archive:
+func SetRssSourceIgnoreRobots(id string, ignoreRobots bool, ctx context.Context, driver neo4j.DriverWithContext) (rssFeed RssFeed, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (rss_feed:Rss_Feed:Source) WHERE elementId(rss_feed) = $id
+		SET rss_feed.ignore_robots = $ignore_robots
+		WITH rss_feed
+		OPTIONAL MATCH (rss_feed)-[:TAGGED]->(tag:Tag)
+		RETURN rss_feed, collect(tag.name) AS tags`,
+		map[string]any{"id": id, "ignore_robots": ignoreRobots},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return rssFeed, err
+	}
+
+	if len(result.Records) == 0 {
+		err = fmt.Errorf("unable to find rss feed %s in the database", id)
+		return rssFeed, err
+	}
+
+	return rssFeedFromTaggedRecord(result.Records[0])
+}
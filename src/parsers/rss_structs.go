@@ -1,62 +1,234 @@
 package parsers
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
 
 type XmlSchema struct {
 	XmlCategory xml.Attr `xml:"channel"`
 }
 
 type RssEntry struct {
-	Id            string `json:"id"`
-	Url           string `json:"url"`
-	Title         string `json:"title"`
-	Description   string `json:"description"`
-	DatePosted    string `json:"date_posted"`
-	DateExtracted int    `json:"date_extracted"`
-	InStorage     int    `json:"in_storage"`
-	StorageUrl    string `json:"storage_inserted"`
+	Id             string     `json:"id"`
+	Guid           string     `json:"guid"`
+	Url            string     `json:"url"`
+	CanonicalUrl   string     `json:"canonical_url"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Content        string     `json:"content"`
+	Creator        string     `json:"creator"`
+	ImageUrl       string     `json:"image_url"`
+	DatePosted     time.Time  `json:"date_posted"`
+	DateUpdated    time.Time  `json:"date_updated"`
+	DateExtracted  time.Time  `json:"date_extracted"`
+	InStorage      int        `json:"in_storage"`
+	StorageUrl     string     `json:"storage_inserted"`
+	Categories     []string   `json:"categories"`
+	Media          []RssMedia `json:"media"`
+	ExtensionsJson string     `json:"extensions"`
+	Charset        string     `json:"charset"`
+
+	// Fields only some feed formats have. Links holds every Atom <link> (alternate, related, via, replies
+	// etc.) or the links in a newsletter email, ExternalUrl is a JSON Feed item's external_url or an email's
+	// "view in browser" link and SourceFeedTitle/SourceFeedUrl describe the feed an Atom entry was copied
+	// from (its <source>):
+	Links           []RssLink `json:"links"`
+	ExternalUrl     string    `json:"external_url"`
+	Rights          string    `json:"rights"`
+	Contributors    []string  `json:"contributors"`
+	SourceFeedTitle string    `json:"source_feed_title"`
+	SourceFeedUrl   string    `json:"source_feed_url"`
+
+	// Podcast episode fields from the itunes and podcast namespaces. Duration is in seconds:
+	Duration    int64           `json:"duration"`
+	Episode     int64           `json:"episode"`
+	Season      int64           `json:"season"`
+	EpisodeType string          `json:"episode_type"`
+	Explicit    bool            `json:"explicit"`
+	Transcripts []RssTranscript `json:"transcripts"`
+
+	// Metadata read from the article's page (see MergeEntryMetadata). MetadataSources maps each field that
+	// has a value to where it was read from:
+	Section         string            `json:"section"`
+	Keywords        []string          `json:"keywords"`
+	Publisher       string            `json:"publisher"`
+	MetadataSources map[string]string `json:"metadata_sources"`
+}
+
+// An Atom <link> element of an entry:
+type RssLink struct {
+	Href     string `json:"href"`
+	Rel      string `json:"rel"`
+	Type     string `json:"type"`
+	Hreflang string `json:"hreflang"`
+	Title    string `json:"title"`
+	Length   int64  `json:"length"`
+}
+
+// An enclosure or media:* element attached to an rss item, or an attachment of a JSON Feed item. Source
+// records which element it came from (enclosure, media:content, media:thumbnail or attachment):
+type RssMedia struct {
+	Id     string `json:"id"`
+	Url    string `json:"url"`
+	Type   string `json:"type"`
+	Medium string `json:"medium"`
+	Length int64  `json:"length"`
+	Width  int64  `json:"width"`
+	Height int64  `json:"height"`
+	Source string `json:"source"`
+	// Only set for JSON Feed attachments. Duration is in seconds:
+	Title    string `json:"title"`
+	Duration int64  `json:"duration"`
 }
 type RssEntries struct {
 	Entries []RssEntry
 }
 type RssFeed struct {
-	Id          string `json:"id"`
-	Url         string `json:"url"`
-	Title       string `json:"title"`
-	Etag        string `json:"etag"`
-	LastUpdate  string `json:"last_updated"`
-	ExecuteTime string `json:"execute_time"`
+	Id                  string    `json:"id"`
+	Url                 string    `json:"url"`
+	Title               string    `json:"title"`
+	Description         string    `json:"description"`
+	SiteLink            string    `json:"site_link"`
+	Language            string    `json:"language"`
+	ImageUrl            string    `json:"image_url"`
+	Tags                []string  `json:"tags"`
+	Etag                string    `json:"etag"`
+	LastUpdate          time.Time `json:"last_updated"`
+	ExecuteTime         string    `json:"execute_time"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastFailure         time.Time `json:"last_failure"`
+	LastError           string    `json:"last_error"`
+	NextAttempt         time.Time `json:"next_attempt"`
+	Disabled            bool      `json:"disabled"`
+	IgnoreRobots        bool      `json:"ignore_robots"`
+	Charset             string    `json:"charset"`
+	FeedType            string    `json:"feed_type"`
+	FeedVersion         string    `json:"feed_version"`
+	Rights              string    `json:"rights"`
 }
 type RssFeeds struct {
 	Entries []RssFeed
 }
 type RssAuthor struct {
-	Id    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Id             string   `json:"id"`
+	Name           string   `json:"name"`
+	Email          string   `json:"email"`
+	NormalisedName string   `json:"normalised_name"`
+	Aliases        []string `json:"aliases"`
+}
+
+// Machine readable outcome of extracting a feed, an entry or an author. The Message field of each summary
+// holds the human readable explanation:
+type ExtractionStatus string
+
+const (
+	StatusRunning          ExtractionStatus = "running"
+	StatusCompleted        ExtractionStatus = "completed"
+	StatusCreated          ExtractionStatus = "created"
+	StatusUpdated          ExtractionStatus = "updated"
+	StatusLinked           ExtractionStatus = "linked"
+	StatusSkippedExisting  ExtractionStatus = "skipped_existing"
+	StatusSkippedDuplicate ExtractionStatus = "skipped_duplicate"
+	StatusSkippedTooLarge  ExtractionStatus = "skipped_too_large"
+	StatusNotModified      ExtractionStatus = "not_modified"
+	StatusErrorNotFound    ExtractionStatus = "error_not_found"
+	StatusErrorFetch       ExtractionStatus = "error_fetch"
+	StatusErrorRobots      ExtractionStatus = "error_robots"
+	StatusErrorParse       ExtractionStatus = "error_parse"
+	StatusErrorDb          ExtractionStatus = "error_db"
+	StatusErrorStorage     ExtractionStatus = "error_storage"
+)
+
+// Reports whether a status is one of the error_* statuses:
+func (status ExtractionStatus) IsError() bool {
+	return strings.HasPrefix(string(status), "error_")
 }
 
 type RssAuthorExtractionSummary struct {
-	Id     string `json:"id"`
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	Error  string `json:"error"`
+	Id      string           `json:"id"`
+	Name    string           `json:"name"`
+	Status  ExtractionStatus `json:"status"`
+	Message string           `json:"message"`
+	Error   string           `json:"error"`
 }
 
 type RssEntryExtractionSummary struct {
 	Id      string                       `json:"id"`
 	Title   string                       `json:"title"`
 	Url     string                       `json:"url"`
-	Status  string                       `json:"status"`
+	Status  ExtractionStatus             `json:"status"`
+	Message string                       `json:"message"`
 	Error   string                       `json:"error"`
 	Authors []RssAuthorExtractionSummary `json:"authors"`
 }
 
+// The outcome of downloading one of a feed's audio or video media into object storage:
+type RssMediaDownloadSummary struct {
+	Id         string           `json:"id"`
+	Url        string           `json:"url"`
+	Status     ExtractionStatus `json:"status"`
+	Message    string           `json:"message"`
+	Error      string           `json:"error"`
+	Bytes      int64            `json:"bytes"`
+	StorageUrl string           `json:"storage_url"`
+}
+
+// Totals of the entry and author statuses of a feed extraction along with how long it took:
+type RssExtractionCounts struct {
+	ItemsSeen      int   `json:"items_seen"`
+	ItemsCreated   int   `json:"items_created"`
+	ItemsUpdated   int   `json:"items_updated"`
+	ItemsSkipped   int   `json:"items_skipped"`
+	ItemsFailed    int   `json:"items_failed"`
+	AuthorsCreated int   `json:"authors_created"`
+	AuthorsLinked  int   `json:"authors_linked"`
+	DurationMs     int64 `json:"duration_ms"`
+}
+
 type RssFeedExtractionSummary struct {
 	Id         string                      `json:"id"`
 	Title      string                      `json:"title"`
-	Status     string                      `json:"status"`
+	Status     ExtractionStatus            `json:"status"`
+	Message    string                      `json:"message"`
 	Error      string                      `json:"error"`
+	RunId      string                      `json:"run_id"`
+	HttpStatus int                         `json:"http_status"`
+	Etag       string                      `json:"etag"`
+	Counts     RssExtractionCounts         `json:"counts"`
+	Warnings   []string                    `json:"warnings"`
 	RssFeed    RssFeed                     `json:"source_feed"`
+	Media      []RssMediaDownloadSummary   `json:"media_downloads"`
 	RssEntries []RssEntryExtractionSummary `json:"entries"`
 }
+
+// Tallies the statuses of the entries and authors of a feed extraction into its counts:
+func (summary *RssFeedExtractionSummary) CountEntries() {
+
+	durationMs := summary.Counts.DurationMs
+	summary.Counts = RssExtractionCounts{ItemsSeen: len(summary.RssEntries), DurationMs: durationMs}
+
+	for _, entry := range summary.RssEntries {
+		switch {
+		case entry.Status == StatusCreated:
+			summary.Counts.ItemsCreated++
+		case entry.Status == StatusUpdated:
+			summary.Counts.ItemsUpdated++
+		case entry.Status.IsError():
+			summary.Counts.ItemsFailed++
+		default:
+			summary.Counts.ItemsSkipped++
+		}
+
+		for _, author := range entry.Authors {
+			switch author.Status {
+			case StatusCreated:
+				summary.Counts.AuthorsCreated++
+			case StatusLinked:
+				summary.Counts.AuthorsLinked++
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Decides whether a source should be ingested now. Disabled sources are never due and failing sources are
+// not due until their backoff has passed, at which point they are retried straight away. Otherwise a
+// source is due once a day at its scheduled time (a "15:04" time of day in UTC) if it hasn't been attempted
+// since. Sources without a valid scheduled time are only ingested on request:
+func RssFeedIsDue(rssFeed RssFeed, now time.Time) bool {
+
+	if rssFeed.Disabled {
+		return false
+	}
+
+	if !rssFeed.NextAttempt.IsZero() {
+		return !now.Before(rssFeed.NextAttempt)
+	}
+
+	scheduledTime, err := time.Parse("15:04", rssFeed.ExecuteTime)
+	if err != nil {
+		return false
+	}
+
+	now = now.UTC()
+	lastScheduled := time.Date(now.Year(), now.Month(), now.Day(), scheduledTime.Hour(), scheduledTime.Minute(), 0, 0, time.UTC)
+	if lastScheduled.After(now) {
+		lastScheduled = lastScheduled.AddDate(0, 0, -1)
+	}
+
+	lastAttempt := rssFeed.LastSuccess
+	if rssFeed.LastFailure.After(lastAttempt) {
+		lastAttempt = rssFeed.LastFailure
+	}
+
+	return lastAttempt.Before(lastScheduled)
+}
+
+// Decides whether a source of any type should be ingested now from the schedule and health on its node
+// (see RssFeedIsDue):
+func SourceIsDue(source SourceNode, now time.Time) bool {
+	var schedule RssFeed
+	readSourceHealth(source.Properties, &schedule)
+	return RssFeedIsDue(schedule, now)
+}
+
+// Checks every source of every type each interval and ingests the ones that are due (see SourceIsDue), one
+// at a time. Runs until the context is cancelled:
+func RunScheduler(interval time.Duration, ctx context.Context, driver neo4j.DriverWithContext) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+
+			sources, err := GetAllSources(ctx, driver)
+			if err != nil {
+				fmt.Println("Scheduler unable to query the Sources:", err)
+				continue
+			}
+
+			for _, source := range sources {
+				if source.Type == "" || !SourceIsDue(source, now) {
+					continue
+				}
+				summary, _ := IngestSource(source.Type, source.Name, ctx, driver)
+				fmt.Printf(
+					"Scheduled ingestion of the %s source %s finished with status %s. Created %d and updated %d articles in %dms. \n",
+					source.Type,
+					source.Name,
+					summary.Status,
+					summary.Counts.ItemsCreated,
+					summary.Counts.ItemsUpdated,
+					summary.Counts.DurationMs,
+				)
+			}
+		}
+	}
+}
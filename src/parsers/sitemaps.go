@@ -0,0 +1,284 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/temoto/robotstxt"
+	"golang.org/x/net/html/charset"
+)
+
+// The sitemap protocol limits a sitemap to 50MB once uncompressed:
+const maxSitemapBytes = 50 * 1024 * 1024
+
+var ErrSitemapParse = errors.New("unable to parse the sitemap")
+
+// A page listed in a sitemap, or one of the sitemaps listed in a sitemap index. Title, DatePublished and
+// Keywords come from the Google News extension (<news:news>) and ImageUrls from the image extension:
+type SitemapUrl struct {
+	Url           string    `json:"url"`
+	LastModified  time.Time `json:"last_modified"`
+	Title         string    `json:"title"`
+	DatePublished time.Time `json:"date_published"`
+	Keywords      []string  `json:"keywords"`
+	ImageUrls     []string  `json:"image_urls"`
+}
+
+// A parsed sitemap. Sitemap indexes only have Sitemaps, regular sitemaps only have Urls:
+type Sitemap struct {
+	Urls     []SitemapUrl `json:"urls"`
+	Sitemaps []SitemapUrl `json:"sitemaps"`
+}
+
+// The <urlset> and <sitemapindex> documents. Elements are matched on their local name so the namespace
+// prefixes sites pick for the news and image extensions don't matter:
+type sitemapDocument struct {
+	XMLName  xml.Name
+	Urls     []sitemapDocumentUrl `xml:"url"`
+	Sitemaps []sitemapDocumentUrl `xml:"sitemap"`
+}
+
+type sitemapDocumentUrl struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+	News    struct {
+		Title           string `xml:"title"`
+		PublicationDate string `xml:"publication_date"`
+		Keywords        string `xml:"keywords"`
+	} `xml:"news"`
+	Images []struct {
+		Loc string `xml:"loc"`
+	} `xml:"image"`
+}
+
+// Parses a sitemap, sitemap index, plain text sitemap (one url per line) or an rss/atom feed used as a
+// sitemap, all of which the sitemap protocol allows. Gzipped sitemaps are decompressed first:
+func ParseSitemap(body []byte) (sitemap Sitemap, err error) {
+
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return sitemap, fmt.Errorf("%w: %v", ErrSitemapParse, err)
+		}
+		body, err = io.ReadAll(io.LimitReader(gzipReader, maxSitemapBytes+1))
+		if err != nil {
+			return sitemap, fmt.Errorf("%w: unable to decompress the sitemap: %v", ErrSitemapParse, err)
+		}
+		if len(body) > maxSitemapBytes {
+			return sitemap, fmt.Errorf("%w: the sitemap is larger than %d bytes once decompressed", ErrSitemapParse, maxSitemapBytes)
+		}
+	}
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return parseTextSitemap(trimmed), nil
+	}
+
+	var document sitemapDocument
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err = decoder.Decode(&document); err != nil {
+		return sitemap, fmt.Errorf("%w: %v", ErrSitemapParse, err)
+	}
+
+	switch strings.ToLower(document.XMLName.Local) {
+	case "urlset", "sitemapindex":
+	case "rss", "feed", "rdf":
+		return parseFeedSitemap(trimmed)
+	default:
+		return sitemap, fmt.Errorf("%w: unexpected <%s> document", ErrSitemapParse, document.XMLName.Local)
+	}
+
+	for _, documentUrl := range document.Urls {
+		if sitemapUrl, ok := sitemapUrlFromDocument(documentUrl); ok {
+			sitemap.Urls = append(sitemap.Urls, sitemapUrl)
+		}
+	}
+	for _, documentUrl := range document.Sitemaps {
+		if sitemapUrl, ok := sitemapUrlFromDocument(documentUrl); ok {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, sitemapUrl)
+		}
+	}
+
+	return sitemap, nil
+}
+
+func sitemapUrlFromDocument(documentUrl sitemapDocumentUrl) (sitemapUrl SitemapUrl, ok bool) {
+
+	sitemapUrl.Url = strings.TrimSpace(documentUrl.Loc)
+	if sitemapUrl.Url == "" {
+		return sitemapUrl, false
+	}
+
+	sitemapUrl.LastModified, _ = ParseFeedDate(documentUrl.LastMod)
+	sitemapUrl.Title = strings.TrimSpace(documentUrl.News.Title)
+	sitemapUrl.DatePublished, _ = ParseFeedDate(documentUrl.News.PublicationDate)
+	for _, keyword := range strings.Split(documentUrl.News.Keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			sitemapUrl.Keywords = append(sitemapUrl.Keywords, keyword)
+		}
+	}
+	for _, image := range documentUrl.Images {
+		if imageUrl := strings.TrimSpace(image.Loc); imageUrl != "" {
+			sitemapUrl.ImageUrls = append(sitemapUrl.ImageUrls, imageUrl)
+		}
+	}
+
+	return sitemapUrl, true
+}
+
+func parseTextSitemap(body []byte) (sitemap Sitemap) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			sitemap.Urls = append(sitemap.Urls, SitemapUrl{Url: line})
+		}
+	}
+	return sitemap
+}
+
+func parseFeedSitemap(body []byte) (sitemap Sitemap, err error) {
+
+	feed, _, err := ParseFeed(body)
+	if err != nil {
+		return sitemap, fmt.Errorf("%w: %v", ErrSitemapParse, err)
+	}
+
+	for _, item := range feed.Items {
+		if strings.TrimSpace(item.Link) == "" {
+			continue
+		}
+		sitemap.Urls = append(sitemap.Urls, SitemapUrl{
+			Url:           strings.TrimSpace(item.Link),
+			Title:         strings.TrimSpace(item.Title),
+			LastModified:  FeedItemTime(item.UpdatedParsed, item.Updated),
+			DatePublished: FeedItemTime(item.PublishedParsed, item.Published),
+			Keywords:      item.Categories,
+		})
+	}
+
+	return sitemap, nil
+}
+
+// Fetches and parses a sitemap (see ParseSitemap):
+func FetchSitemap(ctx context.Context, sitemapUrl string) (sitemap Sitemap, err error) {
+
+	resp, err := DefaultFetcher.Get(ctx, sitemapUrl)
+	if err != nil {
+		return sitemap, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return sitemap, fmt.Errorf("request to sitemap %s returned status code: %d", sitemapUrl, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return sitemap, err
+	}
+
+	return ParseSitemap(body)
+}
+
+// Finds the sitemaps of the site a page belongs to. Sites list their sitemaps in their robots.txt, sites
+// that don't are assumed to serve one from /sitemap.xml:
+func FindSitemapUrls(ctx context.Context, siteUrl string) (sitemapUrls []string, err error) {
+
+	parsedUrl, err := url.Parse(strings.TrimSpace(siteUrl))
+	if err != nil || parsedUrl.Host == "" {
+		return nil, fmt.Errorf("unable to find the sitemaps of %q, it is not an absolute url", siteUrl)
+	}
+	siteRoot := &url.URL{Scheme: parsedUrl.Scheme, Host: parsedUrl.Host}
+
+	resp, err := DefaultFetcher.Get(ctx, siteRoot.JoinPath("robots.txt").String())
+	if err == nil {
+		robots, robotsErr := robotstxt.FromResponse(resp)
+		resp.Body.Close()
+		if robotsErr == nil {
+			sitemapUrls = append(sitemapUrls, robots.Sitemaps...)
+		}
+	}
+	if len(sitemapUrls) == 0 {
+		sitemapUrls = []string{siteRoot.JoinPath("sitemap.xml").String()}
+	}
+
+	return sitemapUrls, nil
+}
+
+// The placeholder replaced by the page number in a paginated archive url, eg:
+// "https://example.com/news/page/{page}/":
+const archivePagePlaceholder = "{page}"
+
+func ArchivePageUrl(archiveUrl string, page int) string {
+	return strings.ReplaceAll(archiveUrl, archivePagePlaceholder, fmt.Sprint(page))
+}
+
+// Extracts the absolute urls of the article links on an archive page. Links to other sites, to the page
+// itself and links that don't match the pattern are dropped. Each url is only returned once:
+func ExtractArchiveLinks(pageUrl string, body []byte, pattern *regexp.Regexp) ([]string, error) {
+
+	baseUrl, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if baseHref, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if documentBase, err := baseUrl.Parse(strings.TrimSpace(baseHref)); err == nil {
+			baseUrl = documentBase
+		}
+	}
+
+	pageCanonicalUrl := CanonicalizeUrl(pageUrl)
+	seenUrls := map[string]bool{}
+	var links []string
+	doc.Find("a[href]").Each(func(i int, link *goquery.Selection) {
+
+		href, _ := link.Attr("href")
+		linkUrl, err := baseUrl.Parse(strings.TrimSpace(href))
+		if err != nil || (linkUrl.Scheme != "http" && linkUrl.Scheme != "https") {
+			return
+		}
+		if !strings.EqualFold(linkUrl.Hostname(), baseUrl.Hostname()) {
+			return
+		}
+		linkUrl.Fragment = ""
+
+		canonicalUrl := CanonicalizeUrl(linkUrl.String())
+		if canonicalUrl == pageCanonicalUrl || seenUrls[canonicalUrl] {
+			return
+		}
+		if pattern != nil && !pattern.MatchString(linkUrl.String()) {
+			return
+		}
+
+		seenUrls[canonicalUrl] = true
+		links = append(links, linkUrl.String())
+	})
+
+	return links, nil
+}
+
+// Reports whether a request failed because the page doesn't exist, which is how paginated archives end:
+func isMissingPageStatus(statusCode int) bool {
+	return statusCode == http.StatusNotFound || statusCode == http.StatusGone
+}
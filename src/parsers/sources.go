@@ -0,0 +1,551 @@
+package parsers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A type of source that articles are ingested from (eg: rss feeds or newsletters). Every source type goes
+// through the same pipeline (see IngestSource): its Source node is looked up by name, Fetch turns the
+// source into normalised items and the items are deduplicated, written with their authors and have their
+// html archived in the same way. Source and Article nodes carry the type's Label alongside Source/Article:
+type Source interface {
+	Label() string
+	Fetch(source SourceNode, ctx context.Context) (SourceFetch, error)
+}
+
+// Source types that have more to do once an ingestion has been recorded (eg: tracking feed health or
+// downloading media) also implement Finish. The summary can be amended before it is returned:
+type SourceFinisher interface {
+	Finish(source SourceNode, summary *RssFeedExtractionSummary, ctx context.Context, driver neo4j.DriverWithContext)
+}
+
+// The registered source types, by the name used in the api:
+var SourceTypes = map[string]Source{
+	"rss":        rssSource{},
+	"newsletter": newsletterSource{},
+	"manual":     manualSource{},
+}
+
+// Registers a source type under a name. Registering the same name again replaces the type:
+func RegisterSourceType(name string, source Source) {
+	SourceTypes[name] = source
+}
+
+// The names of the registered source types in alphabetical order:
+func SourceTypeNames() []string {
+	names := make([]string, 0, len(SourceTypes))
+	for name := range SourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the name of the source type whose label a node carries:
+func sourceTypeNameForLabels(labels []string) string {
+	for _, name := range SourceTypeNames() {
+		for _, label := range labels {
+			if label == SourceTypes[name].Label() {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// A Source node of any type. Properties holds every property of the node so that each source type can read
+// its own settings from it:
+type SourceNode struct {
+	Id         string         `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Labels     []string       `json:"labels"`
+	Properties map[string]any `json:"properties"`
+	Node       neo4j.Node     `json:"-"`
+}
+
+// Converts a Source node of any type into a SourceNode:
+func SourceNodeFromNode(node neo4j.Node) (source SourceNode) {
+	source.Id = node.GetElementId()
+	source.Labels = node.Labels
+	source.Properties = node.GetProperties()
+	source.Node = node
+	source.Type = sourceTypeNameForLabels(node.Labels)
+	if name, ok := source.Properties["name"].(string); ok {
+		source.Name = name
+	}
+	return source
+}
+
+// An item fetched from a source and normalised into the article it becomes. Authors are the individual
+// people credited with the item (bylines are already split). Page is the html to archive for the article
+// when object storage is configured, if the source type has any:
+type SourceItem struct {
+	Entry   RssEntry
+	Authors []RssAuthor
+	Page    *ArticlePage
+}
+
+// The result of fetching a source. NotModified is set when the source reports that nothing has changed
+// since the last fetch. SourceProperties are set on the Source node once the items have been written and
+// UpdateExisting controls whether existing articles whose title, description or published date have
+// changed are updated (keeping the previous values as a Revision) or left alone:
+type SourceFetch struct {
+	Items            []SourceItem
+	Warnings         []string
+	HttpStatus       int
+	Etag             string
+	NotModified      bool
+	SourceProperties map[string]any
+	UpdateExisting   bool
+}
+
+// An error fetching a source along with the status and human readable message it is reported with:
+type SourceError struct {
+	Status  ExtractionStatus
+	Message string
+	Err     error
+}
+
+func (sourceErr *SourceError) Error() string {
+	return sourceErr.Err.Error()
+}
+
+func (sourceErr *SourceError) Unwrap() error {
+	return sourceErr.Err
+}
+
+func newSourceError(status ExtractionStatus, message string, err error) error {
+	return &SourceError{Status: status, Message: message, Err: err}
+}
+
+// Querying the database for the Source node of a type given its name:
+func GetSourceFromDatabase(typeName string, name string, ctx context.Context, driver neo4j.DriverWithContext) (source SourceNode, err error) {
+
+	sourceType, ok := SourceTypes[typeName]
+	if !ok {
+		return source, fmt.Errorf("unknown source type %q, expected one of %v", typeName, SourceTypeNames())
+	}
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		fmt.Sprintf("MATCH (source:%s:Source {name: $name}) RETURN source", sourceType.Label()),
+		map[string]any{"name": name},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return source, err
+	}
+	if len(result.Records) == 0 {
+		return source, fmt.Errorf("unable to find %s source node in the database for %s", typeName, name)
+	}
+	if len(result.Records) > 1 {
+		return source, fmt.Errorf("more than one %s source returned for %s. %d returned", typeName, name, len(result.Records))
+	}
+
+	node, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "source")
+	if err != nil {
+		return source, err
+	}
+
+	return SourceNodeFromNode(node), nil
+}
+
+// Returns every Source node in the database, of every type, ordered by type and name:
+func GetAllSources(ctx context.Context, driver neo4j.DriverWithContext) (sources []SourceNode, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (source:Source) RETURN source ORDER BY source.name`,
+		nil,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return sources, err
+	}
+
+	sources = []SourceNode{}
+	for _, record := range result.Records {
+		node, _, err := neo4j.GetRecordValue[neo4j.Node](record, "source")
+		if err != nil {
+			return sources, err
+		}
+		sources = append(sources, SourceNodeFromNode(node))
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Type < sources[j].Type })
+
+	return sources, nil
+}
+
+// Ingests the source of a type with the given name. The run is recorded as an Ingest_Run node and the
+// outcome on the source's health (see RecordSourceHealth), the source is fetched and its items written (see
+// WriteSourceItems), then the html of the new articles is archived to object storage when it is configured,
+// their entities are extracted when extraction on ingest is enabled and they are tagged with the topics
+// whose rules match them. An article that can't be archived, have its entities extracted or be tagged
+// doesn't fail the ingestion:
+func IngestSource(typeName string, name string, ctx context.Context, driver neo4j.DriverWithContext) (SummaryResponse RssFeedExtractionSummary, err error) {
+
+	SummaryResponse.Title = name
+	startTime := time.Now()
+	var ingestRun IngestRun
+	var source SourceNode
+	defer func() {
+		SummaryResponse.Counts.DurationMs = time.Since(startTime).Milliseconds()
+		SummaryResponse.CountEntries()
+
+		if ingestRun.Id == "" {
+			return
+		}
+		// The source type finishes first so that what it does (eg: downloading media) is part of the run:
+		if finisher, ok := SourceTypes[typeName].(SourceFinisher); ok {
+			finisher.Finish(source, &SummaryResponse, ctx, driver)
+			SummaryResponse.Counts.DurationMs = time.Since(startTime).Milliseconds()
+		}
+		if healthErr := RecordSourceHealth(source.Id, SummaryResponse, time.Now(), ctx, driver); healthErr != nil {
+			fmt.Println("Unable to record the health of source", source.Name, healthErr)
+		}
+		runErr := FinishIngestRun(ingestRun, SummaryResponse, time.Now(), ctx, driver)
+		if runErr != nil {
+			fmt.Println("Unable to record the outcome of ingest run", ingestRun.Id, runErr)
+		}
+	}()
+
+	sourceType, ok := SourceTypes[typeName]
+	if !ok {
+		err = fmt.Errorf("unknown source type %q, expected one of %v", typeName, SourceTypeNames())
+		SummaryResponse.Error = err.Error()
+		SummaryResponse.Status = StatusErrorNotFound
+		SummaryResponse.Message = "Unknown source type"
+		return
+	}
+
+	// 1) Query the database for the source's node by name:
+	source, err = GetSourceFromDatabase(typeName, name, ctx, driver)
+	if err != nil {
+		SummaryResponse.Error = err.Error()
+		SummaryResponse.Status = StatusErrorNotFound
+		SummaryResponse.Message = "Error in extracting the Source from database"
+		return
+	}
+	SummaryResponse.Id = source.Id
+
+	ingestRun, err = StartIngestRun(source.Id, source.Name, startTime, ctx, driver)
+	if err != nil {
+		SummaryResponse.Error = err.Error()
+		SummaryResponse.Status = StatusErrorDb
+		SummaryResponse.Message = "Unable to record the start of the ingest run for the Source"
+		return
+	}
+	SummaryResponse.RunId = ingestRun.Id
+
+	// 2) Fetching the source's items:
+	fetch, err := sourceType.Fetch(source, ctx)
+	SummaryResponse.HttpStatus = fetch.HttpStatus
+	SummaryResponse.Etag = fetch.Etag
+	SummaryResponse.Warnings = fetch.Warnings
+	if err != nil {
+		SummaryResponse.Error = err.Error()
+		SummaryResponse.Status = StatusErrorFetch
+		SummaryResponse.Message = "Error in fetching the Source"
+		var sourceErr *SourceError
+		if errors.As(err, &sourceErr) {
+			SummaryResponse.Status = sourceErr.Status
+			SummaryResponse.Message = sourceErr.Message
+		}
+		return
+	}
+	if fetch.NotModified {
+		SummaryResponse.Status = StatusNotModified
+		SummaryResponse.Message = fmt.Sprintf("Nothing new found for the %s source. Server returned 304 Not Modified for ETag %s", source.Name, fetch.Etag)
+		return
+	}
+
+	// 3) Writing all of the items, their authors and the source's new properties in a single transaction:
+	EntrySummaryArray, err := WriteSourceItems(source, sourceType.Label(), ingestRun.Id, fetch, ctx, driver)
+	SummaryResponse.RssEntries = EntrySummaryArray
+	if err != nil {
+		SummaryResponse.Error = err.Error()
+		SummaryResponse.Status = StatusErrorDb
+		SummaryResponse.Message = "Unable to write the source's items to the database. The transaction was rolled back and nothing from the source was saved"
+		return
+	}
+
+	// 4) Archiving the html of the new articles:
+	if PageCaptures.Client != nil {
+		for i, entrySummary := range EntrySummaryArray {
+			if entrySummary.Status != StatusCreated || fetch.Items[i].Page == nil || len(fetch.Items[i].Page.Body) == 0 {
+				continue
+			}
+			if _, captureErr := CaptureArticlePage(source.Name, entrySummary.Id, *fetch.Items[i].Page, ctx, driver); captureErr != nil {
+				SummaryResponse.Warnings = append(SummaryResponse.Warnings, fmt.Sprintf("Unable to archive the html of %q: %s", entrySummary.Title, captureErr.Error()))
+			}
+		}
+	}
+
+	// 5) Extracting the entities the new articles mention and tagging them with their topics:
+	for i, entrySummary := range EntrySummaryArray {
+		if entrySummary.Status != StatusCreated {
+			continue
+		}
+		if entityErr := extractNewArticleEntities(entrySummary.Id, fetch.Items[i].Entry, fetch.Items[i].Page, ctx, driver); entityErr != nil {
+			SummaryResponse.Warnings = append(SummaryResponse.Warnings, fmt.Sprintf("Unable to extract the entities of %q: %s", entrySummary.Title, entityErr.Error()))
+		}
+		if topicErr := tagNewArticleTopics(entrySummary.Id, fetch.Items[i].Entry, fetch.Items[i].Page, ctx, driver); topicErr != nil {
+			SummaryResponse.Warnings = append(SummaryResponse.Warnings, fmt.Sprintf("Unable to tag the topics of %q: %s", entrySummary.Title, topicErr.Error()))
+		}
+	}
+
+	SummaryResponse.Status = StatusCompleted
+	SummaryResponse.Message = "Article and Author Ingestion complete for the source"
+
+	return
+}
+
+// Writes the items of a source, their authors and the source's new properties in a single transaction. If
+// the transaction fails nothing is saved and every item that would have been written is reported as
+// failed so it can be picked up on the next ingestion. Summaries are in the same order as the items:
+func WriteSourceItems(source SourceNode, sourceLabel string, runId string, fetch SourceFetch, ctx context.Context, driver neo4j.DriverWithContext) (summaries []RssEntryExtractionSummary, err error) {
+
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	// The summaries are rebuilt on every attempt so a retried transaction doesn't report the attempt before it:
+	var written []bool
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		var txErr error
+		summaries, written, txErr = writeSourceItems(source, sourceLabel, runId, fetch, ctx, tx)
+		return nil, txErr
+	})
+
+	if err != nil {
+		for i := range summaries {
+			if !written[i] {
+				continue
+			}
+			summaries[i].Id = ""
+			summaries[i].Authors = nil
+			summaries[i].Error = err.Error()
+			summaries[i].Status = StatusErrorDb
+			summaries[i].Message = "The write transaction for the source was rolled back. Nothing from this Entry was saved"
+		}
+		return summaries, err
+	}
+
+	return summaries, nil
+}
+
+// Returns the key that identifies an item within its source: its guid, or the canonical form of its url for
+// items without one. Items that have neither (eg: feed items that are only a title and a description) are
+// identified by a hash of their title, published date and description:
+func SourceItemKey(entry RssEntry) string {
+
+	if entry.Guid != "" {
+		return entry.Guid
+	}
+	if entry.CanonicalUrl != "" {
+		return entry.CanonicalUrl
+	}
+	contentHash := sha1.Sum([]byte(entry.Title + "\n" + formatDateTime(entry.DatePosted) + "\n" + entry.Description))
+	return "sha1-" + hex.EncodeToString(contentHash[:])
+}
+
+// The body of the write transaction run by WriteSourceItems. Along with the summary of every item it
+// returns which items were going to be written, so that they can be reported as failed if the transaction
+// is rolled back:
+func writeSourceItems(source SourceNode, sourceLabel string, runId string, fetch SourceFetch, ctx context.Context, tx neo4j.ManagedTransaction) (summaries []RssEntryExtractionSummary, written []bool, err error) {
+
+	items := fetch.Items
+	summaries = make([]RssEntryExtractionSummary, len(items))
+	written = make([]bool, len(items))
+
+	// 1) Dropping the items that appear more than once in the source:
+	seenItems := map[string]bool{}
+	var lookupRows []map[string]any
+	for i, item := range items {
+
+		summaries[i].Title = item.Entry.Title
+		summaries[i].Url = item.Entry.Url
+
+		// Items without a guid or a url are stored with their key as their guid, so that they are found
+		// again by the next ingestion:
+		itemKey := SourceItemKey(item.Entry)
+		if item.Entry.Guid == "" && item.Entry.CanonicalUrl == "" {
+			items[i].Entry.Guid = itemKey
+			item = items[i]
+		}
+		if seenItems[itemKey] {
+			summaries[i].Status = StatusSkippedDuplicate
+			summaries[i].Message = "Entry appears more than once in the source. Skipped the repeated Entry"
+			continue
+		}
+		seenItems[itemKey] = true
+
+		written[i] = true
+		lookupRows = append(lookupRows, existingRssArticleParams(i, item.Entry.Guid, item.Entry.Url))
+	}
+
+	// 2) Finding the articles that have already been ingested:
+	existingEntries := map[int]RssEntry{}
+	err = runBatchedQuery(existingArticlesQuery, "items", lookupRows, map[string]any{"source_id": source.Id}, ctx, tx, func(record *neo4j.Record) error {
+		index, _, err := neo4j.GetRecordValue[int64](record, "index")
+		if err != nil {
+			return err
+		}
+		articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "article")
+		if err != nil {
+			return err
+		}
+		existingEntries[int(index)] = RssEntryFromNode(articleNode)
+		return nil
+	})
+	if err != nil {
+		return summaries, written, err
+	}
+
+	// 3) Existing articles are only updated if the source type allows it and their title, description or
+	// published date have changed since they were ingested. Authors are not re-processed for existing articles:
+	var updateRows []map[string]any
+	var createRows []map[string]any
+	for i, item := range items {
+		if !written[i] {
+			continue
+		}
+
+		existingEntry, exists := existingEntries[i]
+		if !exists {
+			createRows = append(createRows, rssArticleCreateParams(i, item.Entry))
+			continue
+		}
+
+		summaries[i].Id = existingEntry.Id
+		var changedFields []string
+		if fetch.UpdateExisting {
+			changedFields = ChangedRssEntryFields(existingEntry, item.Entry)
+		}
+		if len(changedFields) == 0 {
+			written[i] = false
+			summaries[i].Status = StatusSkippedExisting
+			summaries[i].Message = "Article already exists in the database. Skipped all functions assocaited with this Entry"
+			continue
+		}
+
+		updateRows = append(updateRows, rssArticleUpdateParams(existingEntry, item.Entry))
+		summaries[i].Status = StatusUpdated
+		summaries[i].Message = fmt.Sprintf(
+			"Article already exists in the database with a different %s. Updated the article and recorded the previous values as a Revision",
+			strings.Join(changedFields, ", "),
+		)
+	}
+
+	updated := 0
+	err = runBatchedQuery(updateRssArticlesQuery, "updates", updateRows, nil, ctx, tx, func(record *neo4j.Record) error {
+		updated++
+		return nil
+	})
+	if err != nil {
+		return summaries, written, err
+	}
+	if updated != len(updateRows) {
+		return summaries, written, fmt.Errorf("updated %d of %d existing articles", updated, len(updateRows))
+	}
+
+	// 4) Creating the new articles:
+	var createdIndexes []int
+	err = runBatchedQuery(
+		createArticlesQuery(sourceLabel),
+		"articles",
+		createRows,
+		map[string]any{"source_id": source.Id, "run_id": runId, "downloaded_date": time.Now().UTC()},
+		ctx,
+		tx,
+		func(record *neo4j.Record) error {
+			index, _, err := neo4j.GetRecordValue[int64](record, "index")
+			if err != nil {
+				return err
+			}
+			id, _, err := neo4j.GetRecordValue[string](record, "id")
+			if err != nil {
+				return err
+			}
+			summaries[index].Id = id
+			summaries[index].Status = StatusCreated
+			summaries[index].Message = "Successfully inserted the Article. Check Author for futher information about Author connections."
+			createdIndexes = append(createdIndexes, int(index))
+			return nil
+		},
+	)
+	if err != nil {
+		return summaries, written, err
+	}
+	if len(createdIndexes) != len(createRows) {
+		return summaries, written, fmt.Errorf("created %d of %d new articles, the source %s may have been removed", len(createdIndexes), len(createRows), source.Name)
+	}
+
+	// 5) Connecting the new articles to their authors:
+	var articleAuthors []articleAuthor
+	var authorEntryIndexes []int
+	for _, index := range createdIndexes {
+		for _, author := range items[index].Authors {
+			if strings.TrimSpace(author.Name) == "" {
+				continue
+			}
+			articleAuthors = append(articleAuthors, articleAuthor{
+				ArticleId: summaries[index].Id,
+				Name:      author.Name,
+				Email:     author.Email,
+			})
+			authorEntryIndexes = append(authorEntryIndexes, index)
+		}
+	}
+
+	authorSummaries, err := writeArticleAuthors(articleAuthors, ctx, tx)
+	if err != nil {
+		return summaries, written, err
+	}
+	for i, authorSummary := range authorSummaries {
+		summaries[authorEntryIndexes[i]].Authors = append(summaries[authorEntryIndexes[i]].Authors, authorSummary)
+	}
+
+	// 6) Updating the source with the new properties from the fetch (eg: its ETag and charset), and the
+	// label of its format when it is a feed:
+	sourceProperties := fetch.SourceProperties
+	if sourceProperties == nil {
+		sourceProperties = map[string]any{}
+	}
+	sourceUpdated := 0
+	err = runBatchedQuery(
+		`UNWIND $sources AS source_update
+		MATCH (source:Source) WHERE elementId(source) = source_update.id
+		SET source += source_update.properties
+		`+feedTypeLabelsClause("source")+`
+		RETURN source`,
+		"sources",
+		[]map[string]any{{"id": source.Id, "properties": sourceProperties}},
+		nil,
+		ctx,
+		tx,
+		func(record *neo4j.Record) error {
+			sourceUpdated++
+			return nil
+		},
+	)
+	if err != nil {
+		return summaries, written, err
+	}
+	if sourceUpdated == 0 {
+		return summaries, written, fmt.Errorf("unable to find the source %s to update", source.Name)
+	}
+
+	return summaries, written, nil
+}
@@ -0,0 +1,61 @@
+package parsers
+
+import (
+	"log"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/net/context"
+)
+
+// Creates the bucket if it doesn't exist yet:
+func ensureBucket(ctx context.Context, minioClient *minio.Client, bucketName string) error {
+
+	err := minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+	if err == nil {
+		log.Println("Successfully created: ", bucketName)
+		return nil
+	}
+
+	exists, errBucketExists := minioClient.BucketExists(ctx, bucketName)
+	if errBucketExists == nil && exists {
+		log.Printf("Bucket already exists, skipping bucket creation")
+		return nil
+	}
+
+	log.Println("Error in creating bucket in Minio", bucketName, err)
+	return err
+}
+
+// Uploads a file into the bucket at bucketFilePath, creating the bucket if needed. Used for html pages,
+// images and downloaded media:
+func UploadFileToStatic(ctx context.Context, minioClient *minio.Client, bucketName string, bucketFilePath string, reader *os.File, contentType string) (string, error) {
+
+	err := ensureBucket(ctx, minioClient, bucketName)
+	if err != nil {
+		return bucketFilePath, err
+	}
+
+	// Calculating the size of the byte array to be uploaded:
+	objectStat, err := reader.Stat()
+	if err != nil {
+		log.Println("Error in calculating the statistics for the file", err)
+		return bucketFilePath, err
+	}
+
+	info, err := minioClient.PutObject(
+		ctx,
+		bucketName,
+		bucketFilePath,
+		reader,
+		objectStat.Size(),
+		minio.PutObjectOptions{ContentType: contentType},
+	)
+	if err != nil {
+		log.Println("Unable to insert", bucketFilePath, "into the s3 bucket", err)
+	} else {
+		log.Println("Inserted", bucketFilePath, "of size:", info.Size, "bytes", "successfully into bucket", bucketName)
+	}
+
+	return bucketFilePath, err
+}
@@ -0,0 +1,393 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+var (
+	ErrTopicNotFound = errors.New("unable to find the topic")
+	ErrRetagRunning  = errors.New("the articles are already being re-tagged")
+)
+
+// The number of articles tagged at a time when every article is re-tagged:
+const topicRetagBatchSize = 100
+
+// A topic of our taxonomy along with the number of articles that are about it:
+type Topic struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rule        string `json:"rule"`
+	Articles    int64  `json:"articles"`
+}
+
+// The outcome of re-tagging every article with the topic rules:
+type TopicRetagSummary struct {
+	Rules           int              `json:"rules"`
+	ArticlesScanned int              `json:"articles_scanned"`
+	ArticlesTagged  int              `json:"articles_tagged"`
+	Topics          map[string]int64 `json:"topics"`
+	Warnings        []string         `json:"warnings"`
+	StartTime       time.Time        `json:"start_time"`
+	EndTime         time.Time        `json:"end_time"`
+}
+
+// Only one re-tag of every article runs at a time:
+var topicRetag sync.Mutex
+
+// Replaces the ABOUT relationships that the topic rules made for each of $articles with ones to the topics
+// in its row. Topics that don't exist yet are created with the rule that matched:
+const writeArticleTopicsQuery = `
+UNWIND $articles AS row
+MATCH (article:Article) WHERE elementId(article) = row.article_id
+OPTIONAL MATCH (article)-[old:ABOUT {tagged_by: 'rule'}]->(:Topic)
+DELETE old
+WITH DISTINCT article, row
+UNWIND row.topics AS topicRow
+MERGE (topic:Topic {name: topicRow.name})
+ON CREATE SET
+	topic.description = topicRow.description,
+	topic.rule = topicRow.rule,
+	topic.created = datetime({timezone: 'UTC'})
+CREATE (article)-[:ABOUT {tagged_by: 'rule', date_tagged: datetime({timezone: 'UTC'})}]->(topic)
+RETURN row.article_id AS article_id, topic.name AS topic
+`
+
+// Builds the row of writeArticleTopicsQuery for an article from the rules that match it:
+func articleTopicsRow(articleId string, rules []TopicRule, fields TopicFields) map[string]any {
+
+	topicRows := []map[string]any{}
+	for _, rule := range rules {
+		if rule.Matches(fields) {
+			topicRows = append(topicRows, map[string]any{"name": rule.Name, "description": rule.Description, "rule": rule.Rule})
+		}
+	}
+
+	return map[string]any{"article_id": articleId, "topics": topicRows}
+}
+
+// Tags an article with the topics whose rules match it, replacing the topics it was tagged with before:
+func TagArticleTopics(articleId string, fields TopicFields, ctx context.Context, driver neo4j.DriverWithContext) (topics []string, err error) {
+
+	topics = []string{}
+	rules := CurrentTopicRules()
+	_, err = neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		writeArticleTopicsQuery,
+		map[string]any{"articles": []map[string]any{articleTopicsRow(articleId, rules, fields)}},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return topics, err
+	}
+
+	return MatchTopics(rules, fields), nil
+}
+
+// Tags a new article with its topics when there are topic rules (see TopicTagging):
+func tagNewArticleTopics(articleId string, rssEntry RssEntry, page *ArticlePage, ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	if len(CurrentTopicRules()) == 0 {
+		return nil
+	}
+	_, err := TagArticleTopics(articleId, ArticleTopicFields(rssEntry, page), ctx, driver)
+
+	return err
+}
+
+// Reads the text an article that has already been ingested is tagged from: its captured page, or its
+// content if it hasn't been captured:
+func ingestedArticleTopicFields(rssEntry RssEntry, ctx context.Context) (TopicFields, error) {
+
+	if rssEntry.InStorage == 1 && PageCaptures.Client != nil {
+		body, err := readCapturedPage(rssEntry.StorageUrl, ctx)
+		if err != nil {
+			return ArticleTopicFields(rssEntry, nil), err
+		}
+		return ArticleTopicFields(rssEntry, &ArticlePage{Url: rssEntry.Url, Body: body}), nil
+	}
+
+	return ArticleTopicFields(rssEntry, nil), nil
+}
+
+// Tags an article that has already been ingested with its topics again:
+func RefreshArticleTopics(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (topics []string, err error) {
+
+	rssEntry, err := GetRssArticleById(articleId, ctx, driver)
+	if err != nil {
+		return topics, err
+	}
+	fields, err := ingestedArticleTopicFields(rssEntry, ctx)
+	if err != nil {
+		return topics, err
+	}
+
+	return TagArticleTopics(rssEntry.Id, fields, ctx, driver)
+}
+
+// Reloads the topic rules (see ReloadTopicRules) and tags every article in the database with them again.
+// The topics are updated with their new rules, and topics that no longer have a rule are deleted once no
+// article is about them. An article whose captured page can't be read is tagged from its content:
+func RetagAllArticles(ctx context.Context, driver neo4j.DriverWithContext) (summary TopicRetagSummary, err error) {
+
+	if !topicRetag.TryLock() {
+		return summary, ErrRetagRunning
+	}
+	defer topicRetag.Unlock()
+
+	summary = TopicRetagSummary{Topics: map[string]int64{}, Warnings: []string{}, StartTime: time.Now().UTC()}
+	rules, err := ReloadTopicRules()
+	if err != nil {
+		return summary, err
+	}
+	summary.Rules = len(rules)
+
+	topicRows := []map[string]any{}
+	topicNames := []string{}
+	for _, rule := range rules {
+		topicRows = append(topicRows, map[string]any{"name": rule.Name, "description": rule.Description, "rule": rule.Rule})
+		topicNames = append(topicNames, rule.Name)
+		summary.Topics[rule.Name] = 0
+	}
+	_, err = neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`UNWIND $topics AS topicRow
+		MERGE (topic:Topic {name: topicRow.name})
+		ON CREATE SET topic.created = datetime({timezone: 'UTC'})
+		SET topic.description = topicRow.description, topic.rule = topicRow.rule`,
+		map[string]any{"topics": topicRows},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return summary, err
+	}
+
+	// Articles are streamed by a single read and tagged in batches as they arrive, each batch written in a
+	// transaction of its own. The read is retried from the start on transient errors, which tags the
+	// articles it had already reached again with the same topics:
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	_, err = session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		summary.ArticlesScanned, summary.ArticlesTagged, summary.Warnings = 0, 0, []string{}
+		for _, rule := range rules {
+			summary.Topics[rule.Name] = 0
+		}
+
+		result, err := tx.Run(ctx, `MATCH (article:Article) RETURN article`, nil)
+		if err != nil {
+			return nil, err
+		}
+		articleRows := []map[string]any{}
+		for result.Next(ctx) {
+			articleNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Record(), "article")
+			if err != nil {
+				return nil, err
+			}
+			rssEntry := RssEntryFromNode(articleNode)
+			fields, err := ingestedArticleTopicFields(rssEntry, ctx)
+			if err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("Unable to read the captured page of %s, tagged from its content instead: %s", rssEntry.Id, err.Error()))
+			}
+			articleRows = append(articleRows, articleTopicsRow(rssEntry.Id, rules, fields))
+			if len(articleRows) == topicRetagBatchSize {
+				if err := writeRetaggedArticles(articleRows, &summary, ctx, driver); err != nil {
+					return nil, err
+				}
+				articleRows = []map[string]any{}
+			}
+		}
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, writeRetaggedArticles(articleRows, &summary, ctx, driver)
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	_, err = neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (topic:Topic)
+		WHERE NOT topic.name IN $names AND NOT ()-[:ABOUT]->(topic)
+		DELETE topic`,
+		map[string]any{"names": topicNames},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return summary, err
+	}
+	summary.EndTime = time.Now().UTC()
+
+	return summary, nil
+}
+
+// Writes the topics of a batch of re-tagged articles and counts them in the summary:
+func writeRetaggedArticles(articleRows []map[string]any, summary *TopicRetagSummary, ctx context.Context, driver neo4j.DriverWithContext) error {
+
+	if len(articleRows) == 0 {
+		return nil
+	}
+	summary.ArticlesScanned += len(articleRows)
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		writeArticleTopicsQuery,
+		map[string]any{"articles": articleRows},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return err
+	}
+	taggedArticles := map[string]bool{}
+	for _, record := range result.Records {
+		recordMap := record.AsMap()
+		articleId, _ := recordMap["article_id"].(string)
+		topic, _ := recordMap["topic"].(string)
+		taggedArticles[articleId] = true
+		summary.Topics[topic]++
+	}
+	summary.ArticlesTagged += len(taggedArticles)
+
+	return nil
+}
+
+// Returns the names of the topics an article is about:
+func GetArticleTopics(articleId string, ctx context.Context, driver neo4j.DriverWithContext) (topics []string, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (article:Article) WHERE elementId(article) = $id
+		OPTIONAL MATCH (article)-[:ABOUT]->(topic:Topic)
+		WITH article, topic
+		ORDER BY topic.name
+		RETURN collect(topic.name) AS topics`,
+		map[string]any{"id": articleId},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return topics, err
+	}
+	if len(result.Records) == 0 {
+		return topics, fmt.Errorf("%w: %s", ErrArticleNotFound, articleId)
+	}
+
+	topicNames, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "topics")
+	if err != nil {
+		return topics, err
+	}
+	topics = []string{}
+	for _, value := range topicNames {
+		if name, ok := value.(string); ok {
+			topics = append(topics, name)
+		}
+	}
+
+	return topics, nil
+}
+
+// Returns every topic along with the number of articles about it:
+func GetTopics(ctx context.Context, driver neo4j.DriverWithContext) (topics []Topic, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (topic:Topic)
+		OPTIONAL MATCH (article:Article)-[:ABOUT]->(topic)
+		WITH topic, count(DISTINCT article) AS articles
+		RETURN topic, articles
+		ORDER BY articles DESC, topic.name`,
+		map[string]any{},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return topics, err
+	}
+
+	topics = []Topic{}
+	for _, record := range result.Records {
+		topicNode, _, err := neo4j.GetRecordValue[neo4j.Node](record, "topic")
+		if err != nil {
+			return topics, err
+		}
+		topic := TopicFromNode(topicNode)
+		topic.Articles, _, _ = neo4j.GetRecordValue[int64](record, "articles")
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
+
+// Querying the database for a topic along with a page of the articles about it, the most recently posted
+// first and articles without a date last:
+func GetTopicArticles(name string, limit int, offset int, ctx context.Context, driver neo4j.DriverWithContext) (topic Topic, articles []RssEntry, err error) {
+
+	result, err := neo4j.ExecuteQuery(
+		ctx,
+		driver,
+		`MATCH (topic:Topic {name: $name})
+		OPTIONAL MATCH (article:Article)-[:ABOUT]->(topic)
+		WITH topic, article
+		ORDER BY coalesce(article.date_posted, datetime('0001-01-01')) DESC
+		WITH topic, collect(article) AS articles
+		RETURN topic, size(articles) AS total, articles[$offset..$offset + $limit] AS articles`,
+		map[string]any{"name": name, "limit": limit, "offset": offset},
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return topic, articles, err
+	}
+	if len(result.Records) == 0 {
+		return topic, articles, fmt.Errorf("%w: %s", ErrTopicNotFound, name)
+	}
+
+	topicNode, _, err := neo4j.GetRecordValue[neo4j.Node](result.Records[0], "topic")
+	if err != nil {
+		return topic, articles, err
+	}
+	topic = TopicFromNode(topicNode)
+	topic.Articles, _, _ = neo4j.GetRecordValue[int64](result.Records[0], "total")
+
+	articleNodes, _, err := neo4j.GetRecordValue[[]any](result.Records[0], "articles")
+	if err != nil {
+		return topic, articles, err
+	}
+	articles = []RssEntry{}
+	for _, value := range articleNodes {
+		if articleNode, ok := value.(neo4j.Node); ok {
+			articles = append(articles, RssEntryFromNode(articleNode))
+		}
+	}
+
+	return topic, articles, nil
+}
+
+// Converts a Topic node into a Topic struct:
+func TopicFromNode(node neo4j.Node) (topic Topic) {
+
+	nodeProps := node.GetProperties()
+	topic.Id = node.GetElementId()
+	if name, ok := nodeProps["name"].(string); ok {
+		topic.Name = name
+	}
+	if description, ok := nodeProps["description"].(string); ok {
+		topic.Description = description
+	}
+	if rule, ok := nodeProps["rule"].(string); ok {
+		topic.Rule = rule
+	}
+
+	return topic
+}
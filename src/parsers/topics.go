@@ -0,0 +1,437 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A topic of our taxonomy along with the rule that decides which articles are about it. Rules are boolean
+// expressions of terms (see ParseTopicRule):
+type TopicRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rule        string `json:"rule"`
+	expr        topicExpr
+}
+
+// The parts of an article that topic rules are evaluated against, as plain text:
+type TopicFields struct {
+	Title       string
+	Description string
+	Body        string
+}
+
+// Settings for tagging articles with topics. Rules are read from the file at RulesPath, set in main from
+// the command line flags, and reloaded when articles are re-tagged (see RetagAllArticles):
+type TopicTaggingConfig struct {
+	RulesPath string
+}
+
+var TopicTagging = TopicTaggingConfig{}
+
+// The topic rules in use. Guarded by a lock as they are replaced while articles are being ingested:
+var (
+	topicRules     []TopicRule
+	topicRulesLock sync.RWMutex
+)
+
+// Returns the topic rules that articles are currently tagged with:
+func CurrentTopicRules() []TopicRule {
+	topicRulesLock.RLock()
+	defer topicRulesLock.RUnlock()
+	return topicRules
+}
+
+// Replaces the topic rules that articles are tagged with:
+func SetTopicRules(rules []TopicRule) {
+	topicRulesLock.Lock()
+	defer topicRulesLock.Unlock()
+	topicRules = rules
+}
+
+// Reads the topic rules from TopicTagging.RulesPath and starts using them. The rules in use are kept if
+// the file can't be read or one of its rules is invalid:
+func ReloadTopicRules() ([]TopicRule, error) {
+
+	if TopicTagging.RulesPath == "" {
+		return CurrentTopicRules(), nil
+	}
+	rules, err := LoadTopicRules(TopicTagging.RulesPath)
+	if err != nil {
+		return CurrentTopicRules(), err
+	}
+	SetTopicRules(rules)
+
+	return rules, nil
+}
+
+// Reads topic rules from a json file of the form {"topics": [{"name", "description", "rule"}, ...]}:
+func LoadTopicRules(path string) (rules []TopicRule, err error) {
+
+	rulesBytes, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	var rulesFile struct {
+		Topics []TopicRule `json:"topics"`
+	}
+	if err = json.Unmarshal(rulesBytes, &rulesFile); err != nil {
+		return rules, fmt.Errorf("unable to read the topic rules %s: %w", path, err)
+	}
+
+	seenTopics := map[string]bool{}
+	for _, topic := range rulesFile.Topics {
+		rule, err := ParseTopicRule(topic.Name, topic.Rule)
+		if err != nil {
+			return nil, err
+		}
+		if seenTopics[rule.Name] {
+			return nil, fmt.Errorf("the topic %q has more than one rule", rule.Name)
+		}
+		seenTopics[rule.Name] = true
+		rule.Description = topic.Description
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Parses the rule of a topic. A rule is made of terms combined with AND, OR, NOT and parentheses, where
+// NOT binds tightest and terms next to each other are ANDed:
+//
+//	sanctions            a word, in any case
+//	sanction*            a word starting with sanction (sanctions, sanctioned etc.)
+//	"satellite imagery"  a phrase
+//	/hwasong-?\d+/       a regular expression, in any case
+//	title:ICBM           a term that only matches the title (or description: or body:), also title:(a OR b)
+//
+// eg: nuclear* AND NOT ("nuclear family" OR title:energy)
+func ParseTopicRule(name string, rule string) (topicRule TopicRule, err error) {
+
+	topicRule = TopicRule{Name: strings.TrimSpace(name), Rule: rule}
+	if topicRule.Name == "" {
+		return topicRule, fmt.Errorf("a topic needs a name")
+	}
+
+	tokens, err := tokenizeTopicRule(rule)
+	if err != nil {
+		return topicRule, fmt.Errorf("invalid rule for topic %q: %w", topicRule.Name, err)
+	}
+	parser := topicRuleParser{tokens: tokens}
+	topicRule.expr, err = parser.parseOr("")
+	if err == nil && parser.position < len(parser.tokens) {
+		err = fmt.Errorf("unexpected %q", parser.tokens[parser.position].text)
+	}
+	if err != nil {
+		return topicRule, fmt.Errorf("invalid rule for topic %q: %w", topicRule.Name, err)
+	}
+
+	return topicRule, nil
+}
+
+// Reports whether an article is about the topic:
+func (topicRule TopicRule) Matches(fields TopicFields) bool {
+	return topicRule.expr != nil && topicRule.expr.matches(fields)
+}
+
+// Returns the names of the topics an article is about:
+func MatchTopics(rules []TopicRule, fields TopicFields) []string {
+
+	topics := []string{}
+	for _, rule := range rules {
+		if rule.Matches(fields) {
+			topics = append(topics, rule.Name)
+		}
+	}
+
+	return topics
+}
+
+// The fields of an article that topic rules are evaluated against. The body is the article body of its
+// page, or its content when there is no page (see ArticleText):
+func ArticleTopicFields(rssEntry RssEntry, page *ArticlePage) TopicFields {
+
+	fields := TopicFields{Title: rssEntry.Title, Description: htmlText([]byte(rssEntry.Description), false)}
+	if page != nil && len(page.Body) > 0 {
+		fields.Body = htmlText(page.Body, true)
+	} else {
+		fields.Body = htmlText([]byte(rssEntry.Content), false)
+	}
+
+	return fields
+}
+
+type topicExpr interface {
+	matches(fields TopicFields) bool
+}
+
+// A term of a rule, matched against one field or all of them when field is empty:
+type topicTerm struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+type topicAnd []topicExpr
+type topicOr []topicExpr
+type topicNot struct{ expr topicExpr }
+
+func (term topicTerm) matches(fields TopicFields) bool {
+	switch term.field {
+	case "title":
+		return term.pattern.MatchString(fields.Title)
+	case "description":
+		return term.pattern.MatchString(fields.Description)
+	case "body":
+		return term.pattern.MatchString(fields.Body)
+	}
+	return term.pattern.MatchString(fields.Title) || term.pattern.MatchString(fields.Description) || term.pattern.MatchString(fields.Body)
+}
+
+func (and topicAnd) matches(fields TopicFields) bool {
+	for _, expr := range and {
+		if !expr.matches(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+func (or topicOr) matches(fields TopicFields) bool {
+	for _, expr := range or {
+		if expr.matches(fields) {
+			return true
+		}
+	}
+	return false
+}
+
+func (not topicNot) matches(fields TopicFields) bool {
+	return !not.expr.matches(fields)
+}
+
+// The kinds of token in a rule:
+const (
+	topicTokenWord = iota
+	topicTokenPhrase
+	topicTokenRegex
+	topicTokenField
+	topicTokenOpen
+	topicTokenClose
+	topicTokenAnd
+	topicTokenOr
+	topicTokenNot
+)
+
+type topicToken struct {
+	kind int
+	text string
+}
+
+// The fields a term can be limited to:
+var topicRuleFields = map[string]bool{"title": true, "description": true, "body": true}
+
+func tokenizeTopicRule(rule string) (tokens []topicToken, err error) {
+
+	for i := 0; i < len(rule); {
+		switch char := rule[i]; {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			i++
+		case char == '(':
+			tokens = append(tokens, topicToken{kind: topicTokenOpen, text: "("})
+			i++
+		case char == ')':
+			tokens = append(tokens, topicToken{kind: topicTokenClose, text: ")"})
+			i++
+		case char == '"' || char == '/':
+			// Quotes and slashes can be escaped with a backslash inside phrases and regular expressions:
+			var text strings.Builder
+			end := i + 1
+			for ; end < len(rule) && rule[end] != char; end++ {
+				if rule[end] == '\\' && end+1 < len(rule) && rule[end+1] == char {
+					end++
+				}
+				text.WriteByte(rule[end])
+			}
+			if end >= len(rule) {
+				return tokens, fmt.Errorf("unterminated %c at %d", char, i)
+			}
+			kind := topicTokenPhrase
+			if char == '/' {
+				kind = topicTokenRegex
+			}
+			tokens = append(tokens, topicToken{kind: kind, text: text.String()})
+			i = end + 1
+		default:
+			end := i
+			for end < len(rule) && !strings.ContainsRune(" \t\n\r()\"", rune(rule[end])) {
+				if rule[end] == ':' && topicRuleFields[rule[i:end]] {
+					break
+				}
+				end++
+			}
+			word := rule[i:end]
+			switch {
+			case end < len(rule) && rule[end] == ':':
+				tokens = append(tokens, topicToken{kind: topicTokenField, text: word})
+				end++
+			case word == "AND":
+				tokens = append(tokens, topicToken{kind: topicTokenAnd, text: word})
+			case word == "OR":
+				tokens = append(tokens, topicToken{kind: topicTokenOr, text: word})
+			case word == "NOT":
+				tokens = append(tokens, topicToken{kind: topicTokenNot, text: word})
+			default:
+				tokens = append(tokens, topicToken{kind: topicTokenWord, text: word})
+			}
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type topicRuleParser struct {
+	tokens   []topicToken
+	position int
+}
+
+func (parser *topicRuleParser) peek() (topicToken, bool) {
+	if parser.position >= len(parser.tokens) {
+		return topicToken{}, false
+	}
+	return parser.tokens[parser.position], true
+}
+
+func (parser *topicRuleParser) parseOr(field string) (topicExpr, error) {
+
+	expr, err := parser.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+	or := topicOr{expr}
+	for token, ok := parser.peek(); ok && token.kind == topicTokenOr; token, ok = parser.peek() {
+		parser.position++
+		expr, err = parser.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+
+	return or, nil
+}
+
+func (parser *topicRuleParser) parseAnd(field string) (topicExpr, error) {
+
+	expr, err := parser.parseNot(field)
+	if err != nil {
+		return nil, err
+	}
+	and := topicAnd{expr}
+	for {
+		token, ok := parser.peek()
+		if !ok || token.kind == topicTokenOr || token.kind == topicTokenClose {
+			break
+		}
+		if token.kind == topicTokenAnd {
+			parser.position++
+		}
+		expr, err = parser.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+
+	return and, nil
+}
+
+func (parser *topicRuleParser) parseNot(field string) (topicExpr, error) {
+
+	if token, ok := parser.peek(); ok && token.kind == topicTokenNot {
+		parser.position++
+		expr, err := parser.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		return topicNot{expr: expr}, nil
+	}
+
+	return parser.parseTerm(field)
+}
+
+func (parser *topicRuleParser) parseTerm(field string) (topicExpr, error) {
+
+	token, ok := parser.peek()
+	if !ok {
+		return nil, fmt.Errorf("the rule ends where a term was expected")
+	}
+	parser.position++
+
+	switch token.kind {
+	case topicTokenOpen:
+		expr, err := parser.parseOr(field)
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := parser.peek(); !ok || closing.kind != topicTokenClose {
+			return nil, fmt.Errorf("missing )")
+		}
+		parser.position++
+		return expr, nil
+	case topicTokenField:
+		if field != "" && field != token.text {
+			return nil, fmt.Errorf("%s: inside %s:", token.text, field)
+		}
+		return parser.parseTerm(token.text)
+	case topicTokenWord, topicTokenPhrase:
+		pattern, err := topicTermPattern(token.text)
+		if err != nil {
+			return nil, err
+		}
+		return topicTerm{field: field, pattern: pattern}, nil
+	case topicTokenRegex:
+		pattern, err := regexp.Compile("(?i)" + token.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression /%s/: %w", token.text, err)
+		}
+		return topicTerm{field: field, pattern: pattern}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q where a term was expected", token.text)
+}
+
+// Builds the pattern of a word or phrase. Words match whole words only, in any case and with any spacing
+// between the words of a phrase, and a trailing * matches the rest of a word:
+func topicTermPattern(text string) (*regexp.Regexp, error) {
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty phrase")
+	}
+	prefix := false
+	if last := words[len(words)-1]; strings.HasSuffix(last, "*") {
+		words[len(words)-1] = strings.TrimSuffix(last, "*")
+		prefix = true
+	}
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+
+	pattern := `(?i)(?:^|[^\p{L}\p{N}])` + strings.Join(words, `\s+`)
+	if prefix {
+		pattern += `[\p{L}\p{N}]*`
+	}
+
+	return regexp.Compile(pattern + `(?:[^\p{L}\p{N}]|$)`)
+}
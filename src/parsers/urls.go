@@ -0,0 +1,71 @@
+package parsers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Query parameters that are added by newsletters, social media and analytics tools and that don't change
+// the page being linked to:
+var trackingQueryParams = map[string]bool{
+	"fbclid":   true,
+	"gclid":    true,
+	"dclid":    true,
+	"msclkid":  true,
+	"mc_cid":   true,
+	"mc_eid":   true,
+	"_hsenc":   true,
+	"_hsmi":    true,
+	"mkt_tok":  true,
+	"igshid":   true,
+	"ref_src":  true,
+	"cmpid":    true,
+	"ito":      true,
+	"s_cid":    true,
+	"yclid":    true,
+	"ncid":     true,
+	"sr_share": true,
+}
+
+// Normalises an article url so that links to the same page compare equal. The scheme and host are lower
+// cased, default ports, fragments, trailing slashes and tracking parameters (utm_* and the params listed
+// above) are removed and the remaining query parameters are sorted. Urls that can't be parsed are
+// returned trimmed but otherwise unchanged:
+func CanonicalizeUrl(rawUrl string) string {
+
+	rawUrl = strings.TrimSpace(rawUrl)
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil || parsedUrl.Host == "" {
+		return rawUrl
+	}
+
+	parsedUrl.Scheme = strings.ToLower(parsedUrl.Scheme)
+	parsedUrl.Host = strings.ToLower(parsedUrl.Host)
+	if (parsedUrl.Scheme == "http" && parsedUrl.Port() == "80") || (parsedUrl.Scheme == "https" && parsedUrl.Port() == "443") {
+		parsedUrl.Host = parsedUrl.Hostname()
+	}
+
+	parsedUrl.Fragment = ""
+	parsedUrl.RawFragment = ""
+
+	parsedUrl.Path = strings.TrimRight(parsedUrl.Path, "/")
+	parsedUrl.RawPath = ""
+
+	queryParams := parsedUrl.Query()
+	for param := range queryParams {
+		lowerParam := strings.ToLower(param)
+		if strings.HasPrefix(lowerParam, "utm_") || trackingQueryParams[lowerParam] {
+			queryParams.Del(param)
+		}
+	}
+
+	// url.Values.Encode sorts by key, the values of repeated keys are sorted as well so ordering never matters:
+	for param := range queryParams {
+		sort.Strings(queryParams[param])
+	}
+	parsedUrl.RawQuery = queryParams.Encode()
+	parsedUrl.ForceQuery = false
+
+	return parsedUrl.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"knowledge_base/parsers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRssEntryPodcastFields(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssEntryPodcastFields ------------------------")
+
+	feed := parseTestFeed("../data/rss/podcast_test.rss")
+
+	rssEntry := parsers.RssEntryFromFeedItem(feed.Items[0])
+	assert.Equal(t, int64(3723), rssEntry.Duration)
+	assert.Equal(t, int64(12), rssEntry.Episode)
+	assert.Equal(t, int64(2), rssEntry.Season)
+	assert.Equal(t, "full", rssEntry.EpisodeType)
+	assert.True(t, rssEntry.Explicit)
+	assert.Equal(t, []parsers.RssTranscript{
+		{Url: "https://example.com/podcast/12/transcript.vtt", Type: "text/vtt", Language: "en"},
+		{Url: "https://example.com/podcast/12/transcript.srt", Type: "application/srt", Rel: "captions"},
+	}, rssEntry.Transcripts)
+	assert.Equal(t, "enclosure", rssEntry.Media[0].Source)
+	assert.Equal(t, int64(24000000), rssEntry.Media[0].Length)
+
+	// The podcast namespace is used when the itunes elements are missing:
+	rssEntry = parsers.RssEntryFromFeedItem(feed.Items[1])
+	assert.Equal(t, int64(754), rssEntry.Duration)
+	assert.Equal(t, int64(11), rssEntry.Episode)
+	assert.Equal(t, int64(2), rssEntry.Season)
+	assert.False(t, rssEntry.Explicit)
+	assert.Empty(t, rssEntry.Transcripts)
+
+	assert.Equal(t, int64(0), parsers.ParseItunesDuration(""))
+	assert.Equal(t, int64(0), parsers.ParseItunesDuration("about an hour"))
+	assert.Equal(t, int64(125), parsers.ParseItunesDuration("02:05"))
+	assert.Equal(t, int64(90), parsers.ParseItunesDuration("90.7"))
+}
+
+func TestDownloadMediaFileResumes(t *testing.T) {
+
+	fmt.Println("------------------------ TestDownloadMediaFileResumes ------------------------")
+
+	media := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// The first request is interrupted half way through, the second has to resume from there:
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(media)-1, len(media)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(media)-start))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(media[start:])
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(media)))
+		w.Write(media[:len(media)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	fetcher := testMediaFetcher()
+	partPath := filepath.Join(t.TempDir(), "episode.part")
+
+	size, err := parsers.DownloadMediaFile(context.Background(), fetcher, server.URL+"/episode.mp3", partPath, 1024*1024)
+	assert.Error(t, err)
+	assert.Equal(t, int64(len(media)/2), size)
+
+	size, err = parsers.DownloadMediaFile(context.Background(), fetcher, server.URL+"/episode.mp3", partPath, 1024*1024)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(media)), size)
+	assert.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", len(media)/2)}, ranges)
+
+	downloaded, err := os.ReadFile(partPath)
+	assert.NoError(t, err)
+	assert.Equal(t, media, downloaded)
+}
+
+func TestDownloadMediaFileLimits(t *testing.T) {
+
+	fmt.Println("------------------------ TestDownloadMediaFileLimits ------------------------")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ignores_range.mp3":
+			// Servers that don't support ranges send the whole file again:
+			w.Write([]byte("complete file"))
+		case "/chunked.mp3":
+			// No Content-Length so the limit is only found while downloading:
+			w.Write(bytes.Repeat([]byte("a"), 200))
+			w.(http.Flusher).Flush()
+			w.Write(bytes.Repeat([]byte("a"), 200))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := testMediaFetcher()
+	tempDir := t.TempDir()
+
+	partPath := filepath.Join(tempDir, "ignores_range.part")
+	os.WriteFile(partPath, []byte("stale"), 0666)
+	size, err := parsers.DownloadMediaFile(context.Background(), fetcher, server.URL+"/ignores_range.mp3", partPath, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), size)
+	downloaded, _ := os.ReadFile(partPath)
+	assert.Equal(t, "complete file", string(downloaded))
+
+	partPath = filepath.Join(tempDir, "chunked.part")
+	_, err = parsers.DownloadMediaFile(context.Background(), fetcher, server.URL+"/chunked.mp3", partPath, 300)
+	assert.True(t, errors.Is(err, parsers.ErrBodyTooLarge))
+	_, err = os.Stat(partPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// Media is far larger than the pages the other fetcher tests allow:
+func testMediaFetcher() *parsers.Fetcher {
+	config := testFetcherConfig()
+	config.MaxBodyBytes = 1024 * 1024
+	return parsers.NewFetcher(config)
+}
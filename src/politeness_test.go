@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"knowledge_base/parsers"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolitenessRobots(t *testing.T) {
+
+	fmt.Println("------------------------ TestPolitenessRobots ------------------------")
+
+	var robotsRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	config := testFetcherConfig()
+	config.RespectRobots = true
+	fetcher := parsers.NewFetcher(config)
+
+	resp, err := fetcher.Get(context.Background(), server.URL+"/feed.xml")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = fetcher.Get(context.Background(), server.URL+"/private/feed.xml")
+	assert.True(t, errors.Is(err, parsers.ErrDisallowedByRobots))
+
+	// Sources that we have permission to archive skip the check:
+	resp, err = fetcher.Get(parsers.WithIgnoreRobots(context.Background()), server.URL+"/private/feed.xml")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// robots.txt is only fetched once while it is cached:
+	assert.Equal(t, int32(1), robotsRequests.Load())
+}
+
+func TestPolitenessRobotsFailures(t *testing.T) {
+
+	fmt.Println("------------------------ TestPolitenessRobotsFailures ------------------------")
+
+	var robotsRequests atomic.Int32
+	var robotsStatus atomic.Int32
+	robotsStatus.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			w.WriteHeader(int(robotsStatus.Load()))
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	config := testFetcherConfig()
+	config.RespectRobots = true
+	config.MaxRetries = 0
+	config.RobotsRetryAfter = 0
+	fetcher := parsers.NewFetcher(config)
+
+	// A robots.txt that fails disallows everything until it is fetched again:
+	_, err := fetcher.Get(context.Background(), server.URL+"/feed.xml")
+	assert.True(t, errors.Is(err, parsers.ErrDisallowedByRobots))
+
+	// A missing robots.txt allows everything and is cached:
+	robotsStatus.Store(http.StatusNotFound)
+	resp, err := fetcher.Get(context.Background(), server.URL+"/feed.xml")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	resp, err = fetcher.Get(context.Background(), server.URL+"/private/feed.xml")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), robotsRequests.Load())
+
+	// Cancelling the request that fetches robots.txt doesn't leave the other requests without its rules:
+	robotsStatus.Store(http.StatusOK)
+	cancelledFetcher := parsers.NewFetcher(config)
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cancelledFetcher.Get(cancelledCtx, server.URL+"/feed.xml")
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = cancelledFetcher.Get(context.Background(), server.URL+"/private/feed.xml")
+	assert.True(t, errors.Is(err, parsers.ErrDisallowedByRobots))
+	assert.Equal(t, int32(3), robotsRequests.Load())
+}
+
+func TestPolitenessDomainLimits(t *testing.T) {
+
+	fmt.Println("------------------------ TestPolitenessDomainLimits ------------------------")
+
+	var mu sync.Mutex
+	var active, maxActive int
+	var requestTimes []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 1\n")
+			return
+		}
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		requestTimes = append(requestTimes, time.Now())
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// The Crawl-delay of a second is capped by MaxCrawlDelay:
+	config := testFetcherConfig()
+	config.RespectRobots = true
+	config.DomainDelay = 20 * time.Millisecond
+	config.MaxCrawlDelay = 50 * time.Millisecond
+	config.MaxDomainConcurrency = 1
+	fetcher := parsers.NewFetcher(config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := fetcher.Get(context.Background(), server.URL+"/feed.xml")
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxActive)
+	assert.Len(t, requestTimes, 4)
+	for i := 1; i < len(requestTimes); i++ {
+		gap := requestTimes[i].Sub(requestTimes[i-1])
+		assert.GreaterOrEqual(t, gap, 45*time.Millisecond)
+		assert.Less(t, gap, time.Second)
+	}
+}
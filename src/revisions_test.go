@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevisionWordDiff(t *testing.T) {
+
+	fmt.Println("------------------------ TestRevisionWordDiff ------------------------")
+
+	assert.Equal(t,
+		"North Korea [-Launches-] {+Tests+} Missile",
+		parsers.DiffWords("North Korea Launches Missile", "North Korea Tests Missile"),
+	)
+	assert.Equal(t,
+		"New Satellite Could Provide Internet Access {+to North Korea+}",
+		parsers.DiffWords("New Satellite Could Provide Internet Access", "New Satellite Could Provide Internet Access to North Korea"),
+	)
+	assert.Equal(t, "unchanged headline", parsers.DiffWords("unchanged headline", "unchanged headline"))
+	assert.Equal(t, "{+added+}", parsers.DiffWords("", "added"))
+}
+
+func TestRevisionHistoryChanges(t *testing.T) {
+
+	fmt.Println("------------------------ TestRevisionHistoryChanges ------------------------")
+
+	firstPublished := time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC)
+	correctedPublished := time.Date(2023, 10, 20, 15, 0, 0, 0, time.UTC)
+
+	// An article whose headline was edited twice and whose published date was corrected once:
+	history := parsers.RssEntryRevisionHistory{
+		Article: parsers.RssEntry{
+			Title:       "North Korea Tests New ICBM",
+			Description: "Analysis of the launch",
+			DatePosted:  correctedPublished,
+		},
+		Revisions: []parsers.RssEntryRevision{
+			{Revision: 1, Title: "North Korea Launches Missile", Description: "Analysis of the launch", DatePosted: firstPublished},
+			{Revision: 2, Title: "North Korea Tests Missile", Description: "Analysis of the launch", DatePosted: correctedPublished},
+		},
+	}
+
+	parsers.BuildRevisionChanges(&history)
+
+	assert.Equal(t, 2, len(history.Revisions[0].Changes))
+	assert.Equal(t, "title", history.Revisions[0].Changes[0].Field)
+	assert.Equal(t, "North Korea Tests Missile", history.Revisions[0].Changes[0].Current)
+	assert.Equal(t, "date_posted", history.Revisions[0].Changes[1].Field)
+	assert.Equal(t, "2023-10-20T14:33:10Z", history.Revisions[0].Changes[1].Previous)
+	assert.Equal(t, "2023-10-20T15:00:00Z", history.Revisions[0].Changes[1].Current)
+
+	assert.Equal(t, 1, len(history.Revisions[1].Changes))
+	assert.Equal(t, "North Korea Tests [-Missile-] {+New ICBM+}", history.Revisions[1].Changes[0].Diff)
+
+	// Fields that were filled in from the article's page don't count as changes when the feed doesn't have
+	// them, and keep their values when the article is updated:
+	enrichedEntry := parsers.RssEntry{
+		Title:           "North Korea Tests New ICBM",
+		Description:     "Satellite imagery shows the launch pad",
+		DatePosted:      firstPublished,
+		MetadataSources: map[string]string{"title": parsers.MetadataFromRss, "description": parsers.MetadataFromOpenGraph, "date_published": parsers.MetadataFromJsonLd},
+	}
+	feedEntry := parsers.RssEntry{Title: "North Korea Tests New ICBM"}
+	assert.Empty(t, parsers.ChangedRssEntryFields(enrichedEntry, feedEntry))
+	feedEntry.Title = "North Korea Tests Hwasong-18"
+	assert.Equal(t, []string{"title"}, parsers.ChangedRssEntryFields(enrichedEntry, feedEntry))
+}
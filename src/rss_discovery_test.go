@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test server that serves the 38 North rss feed, a homepage advertising the feed and a page without any feeds:
+func newDiscoveryTestServer() *httptest.Server {
+
+	rssFeedBytes, err := os.ReadFile("../data/rss/38_north_test.rss")
+	if err != nil {
+		log.Fatal("Unable to load the test rss feed", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(rssFeedBytes)
+	})
+	mux.HandleFunc("/homepage", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head>
+			<title>38 North</title>
+			<link rel="stylesheet" href="/style.css">
+			<link rel="alternate" type="application/rss+xml" title="38 North &raquo; Feed" href="/feed/">
+			</head><body><p>Homepage</p></body></html>`)
+	})
+	mux.HandleFunc("/no_feeds", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>No feeds here</title></head><body></body></html>`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestRssFeedDiscoveryFromFeedUrl(t *testing.T) {
+
+	fmt.Println("-------------------- TestRssFeedDiscoveryFromFeedUrl --------------------")
+
+	server := newDiscoveryTestServer()
+	defer server.Close()
+
+	rssFeed, err := parsers.DiscoverRssFeed(server.URL + "/feed/")
+	assert.Nil(t, err)
+	assert.Equal(t, server.URL+"/feed/", rssFeed.Url)
+	assert.Equal(t, "38 North", rssFeed.Title)
+	assert.Equal(t, "Informed analysis of events in and around North Korea", rssFeed.Description)
+	assert.Equal(t, "https://www.38north.org/", rssFeed.SiteLink)
+	assert.Equal(t, "en-US", rssFeed.Language)
+	assert.Equal(t, "rss", rssFeed.FeedType)
+	assert.Equal(t, "2.0", rssFeed.FeedVersion)
+}
+
+func TestRssFeedDiscoveryFromHomepage(t *testing.T) {
+
+	fmt.Println("-------------------- TestRssFeedDiscoveryFromHomepage --------------------")
+
+	server := newDiscoveryTestServer()
+	defer server.Close()
+
+	rssFeed, err := parsers.DiscoverRssFeed(server.URL + "/homepage")
+	assert.Nil(t, err)
+	assert.Equal(t, server.URL+"/feed/", rssFeed.Url)
+	assert.Equal(t, "38 North", rssFeed.Title)
+
+	// User provided values take priority over the discovered metadata but the url is always the feed:
+	merged := parsers.MergeDiscoveredRssFeed(
+		parsers.RssFeed{Title: "38 North Analysis", Url: server.URL + "/homepage", ExecuteTime: "18:00"},
+		rssFeed,
+	)
+	assert.Equal(t, "38 North Analysis", merged.Title)
+	assert.Equal(t, server.URL+"/feed/", merged.Url)
+	assert.Equal(t, "18:00", merged.ExecuteTime)
+	assert.Equal(t, "en-US", merged.Language)
+}
+
+func TestRssFeedDiscoveryRejectsNonFeeds(t *testing.T) {
+
+	fmt.Println("-------------------- TestRssFeedDiscoveryRejectsNonFeeds --------------------")
+
+	server := newDiscoveryTestServer()
+	defer server.Close()
+
+	_, err := parsers.DiscoverRssFeed(server.URL + "/no_feeds")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not advertise any rss, atom or json feeds")
+
+	_, err = parsers.DiscoverRssFeed(server.URL + "/missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
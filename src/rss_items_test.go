@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseTestFeed(path string) *gofeed.Feed {
+
+	feedFile, err := os.Open(path)
+	if err != nil {
+		log.Fatal("Unable to open the test rss feed", err)
+	}
+	defer feedFile.Close()
+
+	feed, err := gofeed.NewParser().Parse(feedFile)
+	if err != nil {
+		log.Fatal("Unable to parse the test rss feed", err)
+	}
+	return feed
+}
+
+func TestRssEntryFromFeedItem(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssEntryFromFeedItem ------------------------")
+
+	feed := parseTestFeed("../data/rss/38_north_test.rss")
+	rssEntry := parsers.RssEntryFromFeedItem(feed.Items[0])
+
+	assert.Equal(t, "https://www.38north.org/?p=29051", rssEntry.Guid)
+	assert.Equal(t, "https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday", rssEntry.CanonicalUrl)
+	assert.Equal(t, "Martyn Williams", rssEntry.Creator)
+	assert.Equal(t, time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC), rssEntry.DatePosted)
+	assert.True(t, rssEntry.DateUpdated.IsZero())
+	assert.Contains(t, rssEntry.Content, "North Korea’s attempts to block foreign information")
+	assert.Contains(t, rssEntry.ExtensionsJson, `"creator"`)
+
+	// "Domestic Affairs" and "domestic affairs" are the same category:
+	assert.Equal(t, "Domestic Affairs", rssEntry.Categories[0])
+	assert.NotContains(t, rssEntry.Categories, "domestic affairs")
+	assert.Contains(t, rssEntry.Categories, "starlink")
+	assert.Empty(t, rssEntry.Media)
+}
+
+func TestRssEntryMediaFromFeedItem(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssEntryMediaFromFeedItem ------------------------")
+
+	feed := parseTestFeed("../data/rss/media_test.rss")
+	rssEntry := parsers.RssEntryFromFeedItem(feed.Items[0])
+
+	assert.Equal(t, []string{"Satellite Imagery", "Sohae"}, rssEntry.Categories)
+	assert.Equal(t, 3, len(rssEntry.Media))
+
+	assert.Equal(t, parsers.RssMedia{
+		Url:    "https://example.com/media/sohae-briefing.mp3",
+		Type:   "audio/mpeg",
+		Length: 2048000,
+		Source: "enclosure",
+	}, rssEntry.Media[0])
+
+	assert.Equal(t, "media:thumbnail", rssEntry.Media[1].Source)
+	assert.Equal(t, int64(300), rssEntry.Media[1].Width)
+
+	// media:content nested in a media:group:
+	assert.Equal(t, "media:content", rssEntry.Media[2].Source)
+	assert.Equal(t, "image", rssEntry.Media[2].Medium)
+	assert.Equal(t, int64(512000), rssEntry.Media[2].Length)
+
+	// Without an item image the media thumbnail is used as the article image:
+	assert.Equal(t, "https://example.com/media/sohae-thumb.jpg", rssEntry.ImageUrl)
+}
+
+func parseTestFeedFormat(path string) *parsers.ParsedFeed {
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Unable to open the test feed", err)
+	}
+
+	feed, _, err := parsers.ParseFeed(body)
+	if err != nil {
+		log.Fatal("Unable to parse the test feed", err)
+	}
+	return feed
+}
+
+func TestRssEntryFromAtomFeed(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssEntryFromAtomFeed ------------------------")
+
+	feed := parseTestFeedFormat("../data/rss/atom_test.xml")
+	assert.Equal(t, "atom", feed.FeedType)
+	assert.Equal(t, "Atom_Feed", parsers.FeedTypeLabel(feed.FeedType))
+	assert.Equal(t, "© 2023 Korea Analysis", feed.Copyright)
+	assert.NotNil(t, feed.Atom)
+	assert.Nil(t, feed.Json)
+
+	rssEntry := parsers.RssEntryFromParsedFeed(feed, 0)
+	assert.Equal(t, "tag:example.org,2023:entry-1", rssEntry.Guid)
+	assert.Equal(t, "https://example.org/2023/10/satellite-launch-analysis/", rssEntry.Url)
+	assert.Equal(t, "CC BY 4.0", rssEntry.Rights)
+	assert.Equal(t, []string{"John Doe", "editor@example.org"}, rssEntry.Contributors)
+	assert.Equal(t, "38 North", rssEntry.SourceFeedTitle)
+	assert.Equal(t, "https://www.38north.org/", rssEntry.SourceFeedUrl)
+
+	rels := []string{}
+	for _, link := range rssEntry.Links {
+		rels = append(rels, link.Rel)
+	}
+	assert.Equal(t, []string{"alternate", "related", "via", "replies", "enclosure"}, rels)
+	assert.Equal(t, "Earlier launch", rssEntry.Links[1].Title)
+	assert.Equal(t, int64(1337), rssEntry.Links[4].Length)
+
+	// Enclosure links are also kept as media:
+	assert.Len(t, rssEntry.Media, 1)
+	assert.Equal(t, "https://example.org/audio/satellite-launch.mp3", rssEntry.Media[0].Url)
+
+	// A link without a rel is an alternate link:
+	rssEntry = parsers.RssEntryFromParsedFeed(feed, 1)
+	assert.Equal(t, []parsers.RssLink{{Href: "https://example.org/2023/10/second-entry/", Rel: "alternate"}}, rssEntry.Links)
+	assert.Empty(t, rssEntry.Contributors)
+	assert.Empty(t, rssEntry.SourceFeedUrl)
+}
+
+func TestRssEntryFromJsonFeed(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssEntryFromJsonFeed ------------------------")
+
+	feed := parseTestFeedFormat("../data/rss/json_feed_test.json")
+	assert.Equal(t, "json", feed.FeedType)
+	assert.Equal(t, "Json_Feed", parsers.FeedTypeLabel(feed.FeedType))
+	assert.NotNil(t, feed.Json)
+	assert.Nil(t, feed.Atom)
+
+	rssEntry := parsers.RssEntryFromParsedFeed(feed, 0)
+	assert.Equal(t, "https://example.net/episodes/42", rssEntry.Url)
+	assert.Equal(t, "https://www.38north.org/2023/10/new-satellite/", rssEntry.ExternalUrl)
+	assert.Equal(t, []string{"Space", "Podcast"}, rssEntry.Categories)
+	assert.Equal(t, []parsers.RssMedia{{
+		Url:      "https://example.net/audio/episode-42.mp3",
+		Type:     "audio/mpeg",
+		Length:   48000000,
+		Source:   "attachment",
+		Title:    "Episode 42",
+		Duration: 2700,
+	}}, rssEntry.Media)
+
+	rssEntry = parsers.RssEntryFromParsedFeed(feed, 1)
+	assert.Empty(t, rssEntry.ExternalUrl)
+	assert.Empty(t, rssEntry.Media)
+
+	// Rss feeds have neither format specific feed:
+	feed = parseTestFeedFormat("../data/rss/38_north_test.rss")
+	assert.Equal(t, "rss", feed.FeedType)
+	assert.Equal(t, "", parsers.FeedTypeLabel(feed.FeedType))
+	assert.Nil(t, feed.Atom)
+	assert.Nil(t, feed.Json)
+	assert.Equal(t, parsers.RssEntryFromFeedItem(feed.Items[0]), parsers.RssEntryFromParsedFeed(feed, 0))
+}
@@ -52,14 +52,14 @@ func TestRssFeedExtraction(t *testing.T) {
 			rss_feed.scheduled_time = $scheduled_time,
 			rss_feed.etag = $etag,
 			rss_feed.last_updated = $last_updated,
-			rss_feed.created = timestamp()
+			rss_feed.created = datetime({timezone: 'UTC'})
 		RETURN rss_feed`,
 		map[string]any{
 			"name":           "38 North",
 			"url":            "http://0.0.0.0:8000/test/rss_feed",
 			"scheduled_time": "18:00",
 			"etag":           "",
-			"last_updated":   "",
+			"last_updated":   nil,
 		},
 		neo4j.EagerResultTransformer,
 		neo4j.ExecuteQueryWithDatabase("neo4j"))
@@ -110,10 +110,10 @@ func TestRssFeedExtraction(t *testing.T) {
 					rssFeed.Etag = ""
 				}
 
-				if lastUpdated, ok := nodeProps["last_updated"].(string); ok {
+				if lastUpdated, ok := nodeProps["last_updated"].(time.Time); ok {
 					rssFeed.LastUpdate = lastUpdated
 				} else {
-					rssFeed.LastUpdate = ""
+					rssFeed.LastUpdate = time.Time{}
 				}
 
 				fmt.Println("Id", node.ElementId)
@@ -171,7 +171,7 @@ func TestRssFeedEntryExtraction(t *testing.T) {
 			article.description = $description,
 			article.date_posted = $date_posted,
 			article.in_static_file_storage = $in_static_file_storage,
-			article.created = timestamp()
+			article.created = datetime({timezone: 'UTC'})
 		WITH article
 		
 		MATCH (source:Rss_Feed:Source {name: $rss_source_name})
@@ -184,9 +184,9 @@ func TestRssFeedEntryExtraction(t *testing.T) {
 			"name":                   name,
 			"url":                    url,
 			"description":            "This is a description of a test article",
-			"date_posted":            "2006-01-02",
+			"date_posted":            time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC),
 			"in_static_file_storage": 0,
-			"downloaded_date":        time.Now().Format("2006-01-02"),
+			"downloaded_date":        time.Now().UTC(),
 			"rss_source_name":        "38 North",
 		},
 		neo4j.EagerResultTransformer,
@@ -196,7 +196,8 @@ func TestRssFeedEntryExtraction(t *testing.T) {
 		log.Fatal("Error in creating the article with rss source connection:", err)
 	}
 
-	article, err := parsers.GetRssArticleFromDatabase(name, url, ctx, driver)
+	// The test article has no guid so it is matched on its url, whichever source it belongs to:
+	article, err := parsers.GetRssArticleFromDatabase("", "", url, ctx, driver)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -207,7 +208,7 @@ func TestRssFeedEntryExtraction(t *testing.T) {
 		result.Summary.ResultAvailableAfter(),
 	)
 
-	if (parsers.RssEntry{}) == article {
+	if article.Id == "" {
 		fmt.Println("Empty data returned. No match found")
 	} else {
 		fmt.Println("Id: ", article.Id)
@@ -287,7 +288,7 @@ func TestRssAuthorExtraction(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	if (parsers.RssAuthor{}) == author {
+	if author.Id == "" {
 		fmt.Println("Empty data returned. No match found")
 	} else {
 		fmt.Println("Id: ", author.Id)
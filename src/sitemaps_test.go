@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"knowledge_base/parsers"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readSitemapFixture(name string) []byte {
+	body, err := os.ReadFile("../data/sitemaps/" + name)
+	if err != nil {
+		log.Fatal("Unable to load the test sitemap", err)
+	}
+	return body
+}
+
+// Backfill tests fetch through the default fetcher so it is swapped for one without politeness delays:
+func useTestDefaultFetcher() func() {
+	defaultFetcher := parsers.DefaultFetcher
+	config := testFetcherConfig()
+	config.MaxBodyBytes = 1024 * 1024
+	parsers.DefaultFetcher = parsers.NewFetcher(config)
+	return func() { parsers.DefaultFetcher = defaultFetcher }
+}
+
+func TestParseSitemap(t *testing.T) {
+
+	fmt.Println("------------------------ TestParseSitemap ------------------------")
+
+	// Sitemap indexes only list other sitemaps:
+	sitemap, err := parsers.ParseSitemap(readSitemapFixture("sitemap_index.xml"))
+	assert.Nil(t, err)
+	assert.Empty(t, sitemap.Urls)
+	assert.Equal(t, 3, len(sitemap.Sitemaps))
+	assert.Equal(t, "https://www.38north.org/post-sitemap1.xml.gz", sitemap.Sitemaps[0].Url)
+	assert.Equal(t, time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC), sitemap.Sitemaps[0].LastModified)
+	assert.Equal(t, time.Date(2023, 10, 21, 0, 0, 0, 0, time.UTC), sitemap.Sitemaps[1].LastModified)
+	assert.True(t, sitemap.Sitemaps[2].LastModified.IsZero())
+
+	// Google News sitemaps give the title, publication date, keywords and images. Urls without a <loc> are dropped:
+	sitemap, err = parsers.ParseSitemap(readSitemapFixture("news_sitemap.xml"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sitemap.Urls))
+	assert.Equal(t, parsers.SitemapUrl{
+		Url:           "https://www.38north.org/2023/10/north-korea-satellite-launch/",
+		LastModified:  time.Date(2023, 10, 21, 0, 0, 0, 0, time.UTC),
+		Title:         "North Korea Prepares Another Satellite Launch",
+		DatePublished: time.Date(2023, 10, 20, 14, 33, 10, 0, time.UTC),
+		Keywords:      []string{"North Korea", "Satellites", "Sohae"},
+		ImageUrls: []string{
+			"https://www.38north.org/wp-content/uploads/2023/10/sohae.jpg",
+			"https://www.38north.org/wp-content/uploads/2023/10/pad.jpg",
+		},
+	}, sitemap.Urls[0])
+	assert.Equal(t, "https://www.38north.org/2023/10/yongbyon-update/", sitemap.Urls[1].Url)
+	assert.Equal(t, "", sitemap.Urls[1].Title)
+
+	// Gzipped sitemaps are decompressed:
+	sitemap, err = parsers.ParseSitemap(readSitemapFixture("post_sitemap.xml.gz"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sitemap.Urls))
+	assert.Equal(t, "https://www.38north.org/2015/03/first-post/", sitemap.Urls[0].Url)
+	assert.Equal(t, time.Date(2015, 4, 11, 8, 15, 0, 0, time.UTC), sitemap.Urls[1].LastModified)
+
+	// Plain text sitemaps list one url per line:
+	sitemap, err = parsers.ParseSitemap(readSitemapFixture("sitemap.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, []parsers.SitemapUrl{
+		{Url: "https://www.38north.org/2023/10/north-korea-satellite-launch/"},
+		{Url: "https://www.38north.org/2023/10/yongbyon-update/"},
+	}, sitemap.Urls)
+
+	// Feeds can be used as sitemaps:
+	rssFeedBytes, err := os.ReadFile("../data/rss/38_north_test.rss")
+	assert.Nil(t, err)
+	sitemap, err = parsers.ParseSitemap(rssFeedBytes)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, sitemap.Urls)
+	assert.NotEqual(t, "", sitemap.Urls[0].Title)
+	assert.False(t, sitemap.Urls[0].DatePublished.IsZero())
+
+	_, err = parsers.ParseSitemap([]byte(`<html><body>Not found</body></html>`))
+	assert.True(t, errors.Is(err, parsers.ErrSitemapParse))
+	_, err = parsers.ParseSitemap([]byte{0x1f, 0x8b, 0x00})
+	assert.True(t, errors.Is(err, parsers.ErrSitemapParse))
+}
+
+func TestFindSitemapUrls(t *testing.T) {
+
+	fmt.Println("------------------------ TestFindSitemapUrls ------------------------")
+
+	defer useTestDefaultFetcher()()
+
+	withRobots := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /wp-admin/\n\nSitemap: https://www.38north.org/sitemap_index.xml\nSitemap: https://www.38north.org/news-sitemap.xml\n")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer withRobots.Close()
+
+	sitemapUrls, err := parsers.FindSitemapUrls(context.Background(), withRobots.URL+"/feed/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://www.38north.org/sitemap_index.xml", "https://www.38north.org/news-sitemap.xml"}, sitemapUrls)
+
+	withoutRobots := httptest.NewServer(http.NotFoundHandler())
+	defer withoutRobots.Close()
+
+	sitemapUrls, err = parsers.FindSitemapUrls(context.Background(), withoutRobots.URL+"/feed/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{withoutRobots.URL + "/sitemap.xml"}, sitemapUrls)
+
+	_, err = parsers.FindSitemapUrls(context.Background(), "/feed/")
+	assert.Error(t, err)
+}
+
+func TestExtractArchiveLinks(t *testing.T) {
+
+	fmt.Println("------------------------ TestExtractArchiveLinks ------------------------")
+
+	pageUrl := "https://www.38north.org/category/analysis/page/2/"
+	assert.Equal(t, pageUrl, parsers.ArchivePageUrl("https://www.38north.org/category/analysis/page/{page}/", 2))
+
+	// Only links to the site's articles are kept, once each:
+	links, err := parsers.ExtractArchiveLinks(pageUrl, readSitemapFixture("archive_page.html"), regexp.MustCompile(`/\d{4}/\d{2}/`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"https://www.38north.org/2023/09/kim-putin-summit/",
+		"https://www.38north.org/2023/08/sohae-expansion/?utm_source=archive",
+		"https://www.38north.org/category/analysis/page/2/2023/08/relative-link/",
+	}, links)
+
+	// Without a pattern every link to the site is kept apart from the page itself:
+	links, err = parsers.ExtractArchiveLinks(pageUrl, readSitemapFixture("archive_page.html"), nil)
+	assert.Nil(t, err)
+	assert.Contains(t, links, "https://www.38north.org/about/")
+	assert.Contains(t, links, "https://www.38north.org/category/analysis/page/3/")
+	assert.NotContains(t, links, pageUrl)
+	assert.Equal(t, 7, len(links))
+}
+
+func TestFetchArticlePage(t *testing.T) {
+
+	fmt.Println("------------------------ TestFetchArticlePage ------------------------")
+
+	defer useTestDefaultFetcher()()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2023/09/kim-putin-summit/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(readSitemapFixture("article_page.html"))
+	}))
+	defer server.Close()
+
+	page, err := parsers.FetchArticlePage(context.Background(), server.URL+"/2023/09/kim-putin-summit/")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "utf-8", page.Charset)
+	assert.Equal(t, "Kim and Putin Meet in Vostochny", page.Title)
+	assert.Equal(t, "The summit at the Vostochny Cosmodrome.", page.Description)
+	assert.Equal(t, "Jenny Town and Martyn Williams", page.Author)
+	assert.Equal(t, "https://www.38north.org/wp-content/uploads/2023/09/vostochny.jpg", page.ImageUrl)
+	assert.Equal(t, "https://www.38north.org/2023/09/kim-putin-summit/", page.CanonicalUrl)
+	assert.Equal(t, time.Date(2023, 9, 14, 12, 0, 0, 0, time.UTC), page.DatePublished)
+	assert.Equal(t, time.Date(2023, 9, 15, 8, 30, 0, 0, time.UTC), page.DateModified)
+	assert.NotEmpty(t, page.Body)
+
+	page, err = parsers.FetchArticlePage(context.Background(), server.URL+"/missing/")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, page.StatusCode)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"knowledge_base/parsers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSource struct{}
+
+func (testSource) Label() string { return "Test_Source" }
+
+func (testSource) Fetch(source parsers.SourceNode, ctx context.Context) (parsers.SourceFetch, error) {
+	return parsers.SourceFetch{}, nil
+}
+
+func TestSourceTypes(t *testing.T) {
+
+	fmt.Println("------------------------ TestSourceTypes ------------------------")
+
+	assert.Equal(t, []string{"manual", "newsletter", "rss"}, parsers.SourceTypeNames())
+	assert.Equal(t, "Rss_Feed", parsers.SourceTypes["rss"].Label())
+	assert.Equal(t, "Newsletter", parsers.SourceTypes["newsletter"].Label())
+
+	parsers.RegisterSourceType("test", testSource{})
+	defer delete(parsers.SourceTypes, "test")
+	assert.Equal(t, []string{"manual", "newsletter", "rss", "test"}, parsers.SourceTypeNames())
+
+	// Source nodes are matched to their type by label:
+	source := parsers.SourceNodeFromNode(neo4j.Node{
+		ElementId: "4:test:1",
+		Labels:    []string{"Source", "Test_Source"},
+		Props:     map[string]any{"name": "Test"},
+	})
+	assert.Equal(t, "4:test:1", source.Id)
+	assert.Equal(t, "Test", source.Name)
+	assert.Equal(t, "test", source.Type)
+}
+
+func TestSourceItemKey(t *testing.T) {
+
+	fmt.Println("------------------------ TestSourceItemKey ------------------------")
+
+	feed := parseTestFeed("../data/rss/no_links_test.rss")
+	firstEntry := parsers.RssEntryFromFeedItem(feed.Items[0])
+	secondEntry := parsers.RssEntryFromFeedItem(feed.Items[1])
+	assert.Equal(t, "", firstEntry.Guid)
+	assert.Equal(t, "", firstEntry.CanonicalUrl)
+
+	// Items without a guid or a link are told apart by their content, and keep their key between ingestions:
+	assert.NotEqual(t, parsers.SourceItemKey(firstEntry), parsers.SourceItemKey(secondEntry))
+	assert.Regexp(t, "^sha1-[0-9a-f]{40}$", parsers.SourceItemKey(firstEntry))
+	assert.Equal(t, parsers.SourceItemKey(firstEntry), parsers.SourceItemKey(parsers.RssEntryFromFeedItem(parseTestFeed("../data/rss/no_links_test.rss").Items[0])))
+
+	// Otherwise items are identified by their guid, then their canonical url:
+	assert.Equal(t, "29051", parsers.SourceItemKey(parsers.RssEntry{Guid: "29051", CanonicalUrl: "https://www.38north.org/"}))
+	assert.Equal(t, "https://www.38north.org/", parsers.SourceItemKey(parsers.RssEntry{CanonicalUrl: "https://www.38north.org/"}))
+}
+
+func TestRssSourceFetch(t *testing.T) {
+
+	fmt.Println("------------------------ TestRssSourceFetch ------------------------")
+
+	defer useTestDefaultFetcher()()
+
+	rssFeedBytes, err := os.ReadFile("../data/rss/38_north_test.rss")
+	assert.Nil(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/feed/":
+			http.NotFound(w, r)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			w.Write(rssFeedBytes)
+		}
+	}))
+	defer server.Close()
+
+	rssSourceNode := func(url string, etag string) parsers.SourceNode {
+		return parsers.SourceNodeFromNode(neo4j.Node{
+			ElementId: "4:rss:1",
+			Labels:    []string{"Rss_Feed", "Source"},
+			Props:     map[string]any{"name": "38 North", "url": url, "etag": etag},
+		})
+	}
+
+	// Feed items are normalised into articles with their bylines split into authors:
+	fetch, err := parsers.SourceTypes["rss"].Fetch(rssSourceNode(server.URL+"/feed/", ""), context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, fetch.HttpStatus)
+	assert.Equal(t, `"v1"`, fetch.Etag)
+	assert.True(t, fetch.UpdateExisting)
+	assert.Equal(t, 8, len(fetch.Items))
+	assert.NotEqual(t, "", fetch.Items[0].Entry.Title)
+	assert.Equal(t, []parsers.RssAuthor{{Name: "Martyn Williams"}}, fetch.Items[0].Authors)
+	assert.Nil(t, fetch.Items[0].Page)
+	assert.Equal(t, `"v1"`, fetch.SourceProperties["etag"])
+	assert.Equal(t, "utf-8", fetch.SourceProperties["charset"])
+	assert.Equal(t, "rss", fetch.SourceProperties["feed_type"])
+
+	fetch, err = parsers.SourceTypes["rss"].Fetch(rssSourceNode(server.URL+"/feed/", `"v1"`), context.Background())
+	assert.Nil(t, err)
+	assert.True(t, fetch.NotModified)
+	assert.Empty(t, fetch.Items)
+
+	// Errors carry the status they are reported with:
+	_, err = parsers.SourceTypes["rss"].Fetch(rssSourceNode(server.URL+"/missing/", ""), context.Background())
+	var sourceErr *parsers.SourceError
+	assert.True(t, errors.As(err, &sourceErr))
+	assert.Equal(t, parsers.StatusErrorFetch, sourceErr.Status)
+}
+
+func TestNewsletterSourceFetch(t *testing.T) {
+
+	fmt.Println("------------------------ TestNewsletterSourceFetch ------------------------")
+
+	newsletterNode := func(path string) parsers.SourceNode {
+		return parsers.SourceNodeFromNode(neo4j.Node{
+			ElementId: "4:newsletter:1",
+			Labels:    []string{"Newsletter", "Source"},
+			Props:     map[string]any{"name": "38 North Weekly", "path": path},
+		})
+	}
+
+	defer func(mailRoot string) { parsers.Newsletters.MailRoot = mailRoot }(parsers.Newsletters.MailRoot)
+	parsers.Newsletters.MailRoot = "../data/newsletters"
+
+	// Senders are the authors of their emails and html bodies are archived:
+	fetch, err := parsers.SourceTypes["newsletter"].Fetch(newsletterNode("newsletters.mbox"), context.Background())
+	assert.Nil(t, err)
+	assert.False(t, fetch.UpdateExisting)
+	assert.Equal(t, 1, len(fetch.Warnings))
+	assert.Equal(t, 2, len(fetch.Items))
+	assert.Equal(t, []parsers.RssAuthor{{Name: "Jenny Town — 38 North", Email: "newsletter@mail.38north.org"}}, fetch.Items[0].Authors)
+	assert.Equal(t, "mid:weekly-2023-10-23@mail.38north.org", fetch.Items[0].Page.Url)
+	assert.Equal(t, "utf-8", fetch.Items[0].Page.Charset)
+	assert.Contains(t, string(fetch.Items[0].Page.Body), "Weekly Briefing")
+	assert.Equal(t, []parsers.RssAuthor{{Name: "digest@example.com", Email: "digest@example.com"}}, fetch.Items[1].Authors)
+	assert.Nil(t, fetch.Items[1].Page)
+	assert.Contains(t, fetch.SourceProperties, "last_ingested")
+
+	_, err = parsers.SourceTypes["newsletter"].Fetch(newsletterNode("missing.mbox"), context.Background())
+	var sourceErr *parsers.SourceError
+	assert.True(t, errors.As(err, &sourceErr))
+	assert.Equal(t, parsers.StatusErrorParse, sourceErr.Status)
+
+	// Mailboxes outside of the mail root are never read:
+	_, err = parsers.SourceTypes["newsletter"].Fetch(newsletterNode("../rss/rss_feed.xml"), context.Background())
+	assert.ErrorIs(t, err, parsers.ErrMailboxOutsideRoot)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicRules(t *testing.T) {
+
+	fmt.Println("------------------------ TestTopicRules ------------------------")
+
+	rules, err := parsers.LoadTopicRules("../data/topics/topics.json")
+	assert.Nil(t, err)
+	assert.Len(t, rules, 6)
+
+	article := parsers.TopicFields{
+		Title:       "New satellite imagery shows activity at Yongbyon",
+		Description: "Commercial imagery from early May suggests the reactor is running.",
+		Body:        "The Treasury also announced sanctions on two shipping firms. Pyongyang has not tested a Hwasong-18 since July.",
+	}
+	assert.Equal(t, []string{"nuclear", "sanctions", "satellite imagery", "missiles"}, parsers.MatchTopics(rules, article))
+
+	// Phrases that are excluded by a rule don't tag the article:
+	assert.Equal(t, []string{}, parsers.MatchTopics(rules, parsers.TopicFields{Title: "A new nuclear power plant opens in France"}))
+	assert.Equal(t, []string{"space launches"}, parsers.MatchTopics(rules, parsers.TopicFields{Body: "North Korea launched a reconnaissance satellite from Sohae"}))
+
+	rule, err := parsers.ParseTopicRule("test", `title:(sanction* OR embargo) NOT body:"ship to ship" /u\.?n\.? panel/`)
+	assert.Nil(t, err)
+	assert.True(t, rule.Matches(parsers.TopicFields{Title: "New Sanctions", Description: "The UN panel reported"}))
+	assert.True(t, rule.Matches(parsers.TopicFields{Title: "EMBARGO", Body: "a U.N. Panel found it"}))
+	// Terms limited to a field don't match the others:
+	assert.False(t, rule.Matches(parsers.TopicFields{Description: "sanctions", Body: "un panel"}))
+	// Phrases match across any whitespace:
+	assert.False(t, rule.Matches(parsers.TopicFields{Title: "sanctions", Body: "UN panel on ship\n to  ship transfers"}))
+	// Words only match whole words, a trailing * matches the rest of one:
+	assert.False(t, rule.Matches(parsers.TopicFields{Title: "unsanctioned", Body: "un panel"}))
+
+	// OR binds looser than AND, which is implied between terms:
+	rule, err = parsers.ParseTopicRule("test", "a b OR c")
+	assert.Nil(t, err)
+	assert.True(t, rule.Matches(parsers.TopicFields{Body: "c"}))
+	assert.True(t, rule.Matches(parsers.TopicFields{Body: "b a"}))
+	assert.False(t, rule.Matches(parsers.TopicFields{Body: "a"}))
+
+	for _, invalidRule := range []string{"", "(nuclear OR", "nuclear AND", "\"unterminated", "/[a-/", "title:body:x", "a OR OR b", "a )"} {
+		_, err = parsers.ParseTopicRule("test", invalidRule)
+		assert.NotNil(t, err, invalidRule)
+	}
+	_, err = parsers.ParseTopicRule(" ", "nuclear")
+	assert.NotNil(t, err)
+}
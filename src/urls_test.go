@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"knowledge_base/parsers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeUrl(t *testing.T) {
+
+	fmt.Println("------------------------ TestCanonicalizeUrl ------------------------")
+
+	articleUrl := "https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday"
+
+	equivalentUrls := []string{
+		"https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday/",
+		"https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday/#comments",
+		"HTTPS://WWW.38North.org:443/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday",
+		"https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday/?utm_source=rss&utm_medium=rss&utm_campaign=new-satellite",
+		"https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday?fbclid=IwAR0abc&mc_cid=123",
+		"  https://www.38north.org/2023/10/new-satellite-could-provide-internet-access-to-north-korea-someday  ",
+	}
+	for _, equivalentUrl := range equivalentUrls {
+		assert.Equal(t, articleUrl, parsers.CanonicalizeUrl(equivalentUrl), equivalentUrl)
+	}
+
+	// Query parameters that identify the page are kept and sorted:
+	assert.Equal(t,
+		"https://example.com/article?id=42&page=2",
+		parsers.CanonicalizeUrl("https://example.com/article/?page=2&utm_content=abc&id=42"),
+	)
+	assert.NotEqual(t,
+		parsers.CanonicalizeUrl("https://example.com/?p=29051"),
+		parsers.CanonicalizeUrl("https://example.com/?p=29052"),
+	)
+
+	// Non-default ports are part of the identity of a page:
+	assert.Equal(t, "http://localhost:8000/test/html_page", parsers.CanonicalizeUrl("http://localhost:8000/test/html_page/"))
+
+	// Values that are not absolute urls are returned as they are:
+	assert.Equal(t, "www.google.com", parsers.CanonicalizeUrl("www.google.com"))
+}